@@ -9,12 +9,14 @@ import (
 	"log"
 	"os"
 	"personal_budget_app/internal/models"
+	"time"
 )
 
 type Service interface {
 	Health() map[string]string
 	GetAllAccounts() ([]*models.Account, error)
 	GetAccount(id uint) (*models.Account, error)
+	AccountExists(id uint) (bool, error)
 	CreateAccount(account *models.Account) error
 	DeleteAccount(id uint) error
 	UpdateAccount(id uint, accountUpdates *models.UpdateAccountRequest) error
@@ -22,30 +24,123 @@ type Service interface {
 	// AuthenticateUser auth
 	AuthenticateUser(email, password string) (bool, error)
 	GetIdByEmail(email string) (uint, error)
+	IsAdmin(accountID uint) (bool, error)
 
 	// AddCard cards
 	AddCard(card *models.Card) error
 	DeleteCard(id uint) error
 	GetCards(accountID uint) ([]*models.Card, error)
 	GetCard(cardID uint) (*models.Card, error)
-
-	// AddTransaction transaction
-	AddTransaction(ts *models.Transaction) error
-	CheckCardBalance(cardID uint, amount float64) (bool, error)
+	FindCards(accountID uint) ([]*models.Card, error)
+
+	// TransferFunds transaction
+	TransferFunds(ts *models.Transaction, quota *models.TransferQuota) error
+	HoldFunds(ts *models.Transaction, expiresAt time.Time, quota *models.TransferQuota) error
+	ConfirmTransfer(id uint) (*models.Transaction, error)
+	CancelTransfer(id uint) (*models.Transaction, error)
+	ExpirePendingTransfers(now time.Time) (int, error)
+	RefundTransaction(originalID uint, amount *models.Money) (*models.Transaction, error)
+	GetTransaction(id uint) (*models.Transaction, error)
+	LoadTags(accountID uint, transactions ...*models.Transaction) error
+
+	// AddManualEntry income and expenses entered by hand
+	AddManualEntry(ts *models.Transaction) error
+	ImportManualEntry(ts *models.Transaction) (bool, error)
+	GetManualEntries(accountID uint, filter *models.TransactionFilter) ([]*models.Transaction, error)
+	DeleteManualEntry(id, accountID uint) error
+
+	// GetTransferLimits limits
+	GetTransferLimits(accountID uint) (*models.TransferLimits, error)
+	SetTransferLimits(limits *models.TransferLimits) error
+	GetTransferUsage(accountID uint, since time.Time, currency string) (models.Money, error)
+	ConvertAmount(m models.Money, currency string) (models.Money, error)
+	GetCardCurrency(cardID uint) (string, error)
+
+	// CreateScheduledTransfer scheduled transfers
+	CreateScheduledTransfer(st *models.ScheduledTransfer) error
+	GetScheduledTransfers(accountID uint) ([]*models.ScheduledTransfer, error)
+	GetScheduledTransfer(id, accountID uint) (*models.ScheduledTransfer, error)
+	SetScheduledTransferStatus(id, accountID uint, status string) (*models.ScheduledTransfer, error)
+	ClaimDueScheduledTransfers(now time.Time, limit int) ([]*models.ScheduledTransferRun, error)
+	ClaimInterruptedScheduledTransferRuns(claimedBefore, now time.Time, limit int) ([]*models.ScheduledTransferRun, error)
+	FinishScheduledTransferRun(run *models.ScheduledTransferRun) error
+
+	// CreateSavingsGoal savings goals
+	CreateSavingsGoal(goal *models.SavingsGoal, contribution *models.ScheduledTransfer) error
+	GetSavingsGoals(accountID uint) ([]*models.SavingsGoal, error)
+	GetSavingsGoal(id, accountID uint) (*models.SavingsGoal, error)
+	SetGoalContribution(id, accountID uint, contribution *models.ScheduledTransfer) (*models.SavingsGoal, error)
+	DeleteSavingsGoal(id, accountID uint) error
+	FindCard(cardID uint) (*models.Card, error)
+	GetCardNetChange(cardID uint, since time.Time) (models.Money, error)
+
+	// CreateBill bills
+	CreateBill(bill *models.Bill) error
+	GetBills(accountID uint) ([]*models.Bill, error)
+	DeleteBill(id, accountID uint) error
+	GetPaymentsTo(accountID uint, cardNumbers []string, from, to time.Time) ([]*models.BillPayment, error)
+
+	// ClaimIdempotencyKey idempotency
+	ClaimIdempotencyKey(key *models.IdempotencyKey) (*models.IdempotencyKey, bool, error)
+	SaveIdempotentResponse(accountID uint, key string, status int, body []byte) error
+	ReleaseIdempotencyKey(accountID uint, key string) error
+
+	// ReconcileLedger ledger
+	ReconcileLedger() (*models.ReconciliationReport, error)
+	GetCardBalanceAt(cardID uint, at time.Time) (models.Money, error)
+	GetStatementLines(cardID uint, from, to time.Time) ([]*models.StatementLine, error)
+
+	// SaveExchangeRates exchange rates
+	SaveExchangeRates(rates []*models.ExchangeRate) error
+	GetExchangeRates() ([]*models.ExchangeRate, error)
 	CheckCardBelongsToUser(cardId, accountId uint) (bool, error)
 
-	SenderUpdateBalance(cardID uint, amount float64) error
-	ReceiverUpdateBalance(cardID uint, amount float64) error
-
 	FindCardIDByCardNumber(cardNumber string) (uint, error)
 
 	// GetAllTransactions get
-	GetAllTransactions(cardId uint) ([]*models.Transaction, error)
-	GetIncomingTransactions(cardId uint) ([]*models.Transaction, error)
-	GetOutgoingTransactions(cardId uint) ([]*models.Transaction, error)
+	GetAllTransactions(cardId uint, filter *models.TransactionFilter, page *models.HistoryPageRequest) (*models.HistoryPage, error)
+	GetIncomingTransactions(cardId uint, filter *models.TransactionFilter, page *models.HistoryPageRequest) (*models.HistoryPage, error)
+	GetOutgoingTransactions(cardId uint, filter *models.TransactionFilter, page *models.HistoryPageRequest) (*models.HistoryPage, error)
+	UpdateTransactionDetails(id, accountID uint, req *models.UpdateTransactionRequest) (*models.Transaction, error)
+	SplitTransaction(id uint, splits []*models.TransactionSplit) (*models.Transaction, error)
+	SearchTransactions(accountID uint, search *models.TransactionSearch) ([]*models.Transaction, error)
+	GetAccountFeed(accountID uint, filter *models.TransactionFilter, page *models.HistoryPageRequest) (*models.HistoryPage, error)
+	ExportTransactions(accountID uint, cardID *uint, transactionType string, filter *models.TransactionFilter, each func(*models.ExportRow) error) error
+	GetTags(accountID uint) ([]*models.TagCount, error)
+
+	// GetCategories categories
+	GetCategories(accountID uint, includeArchived bool) ([]*models.Category, error)
+	GetCategory(id, accountID uint) (*models.Category, error)
+	CategoryVisibleTo(categoryID, accountID uint) (bool, error)
+	CreateCategory(category *models.Category) error
+	UpdateCategory(category *models.Category) error
+	DeleteCategory(id, accountID, fallbackID uint) (int64, error)
+
+	// CreateCategoryRule categorization rules
+	CreateCategoryRule(rule *models.CategoryRule) error
+	GetCategoryRules(accountID uint) ([]*models.CategoryRule, error)
+	GetCategoryRule(id, accountID uint) (*models.CategoryRule, error)
+	UpdateCategoryRule(rule *models.CategoryRule) error
+	DeleteCategoryRule(id, accountID uint) error
+	EachOutgoingTransaction(accountID uint, fn func(batch []*models.Transaction, cardNumbers map[uint]string) error) error
+	ClassifyTransaction(id, accountID uint, categoryID *uint, tags []string) error
+
+	// SetBudget budgets
+	SetBudget(budget *models.Budget) error
+	GetBudgets(accountID uint, month string) ([]*models.Budget, error)
+	GetBudget(accountID, categoryID uint, month string) (*models.Budget, error)
+	DeleteBudget(id, accountID uint) error
+	GetCategorySpending(accountID uint, from, to time.Time) ([]*models.CategorySpending, error)
+	GetMonthlyCategorySpending(accountID uint, from, to time.Time) (map[string][]*models.CategorySpending, error)
+
+	// GetReportLines reports
+	GetReportLines(accountID uint, from, to time.Time) ([]*models.ReportLine, error)
+	GetCardMovements(accountID uint, since time.Time) ([]*models.CardMovement, error)
 
 	// Settings
 	SetDefaultCard(userId, cardId uint) (error)
+	SetTimezone(accountID uint, timezone string) error
+	GetTimezone(accountID uint) (string, error)
 
 	CreatePasswordResetToken(token models.PasswordResetToken) error
 	ValidateToken(token string) (uint, error)
@@ -56,7 +151,8 @@ type Service interface {
 }
 
 type service struct {
-	db *gorm.DB
+	db            *gorm.DB
+	defaultLimits models.TransferLimits
 }
 
 func New() Service {
@@ -73,11 +169,63 @@ func New() Service {
 	}
 
 	// AutoMigrate models
-	err = db.AutoMigrate(&models.Account{}, &models.Card{}, &models.Transaction{}, &models.PasswordResetToken{})
+	err = db.AutoMigrate(&models.Account{}, &models.Card{}, &models.Transaction{}, &models.PasswordResetToken{}, &models.ExchangeRate{}, &models.IdempotencyKey{}, &models.LedgerEntry{},
+		&models.ScheduledTransfer{}, &models.ScheduledTransferRun{},
+		&models.TransferLimits{}, &models.Category{}, &models.Budget{},
+		&models.CategoryRule{}, &models.SavingsGoal{}, &models.TransactionSplit{}, &models.Bill{}, &models.TransactionTag{})
 	if err != nil {
 		log.Fatalf("failed to auto migrate: %v", err)
 	}
 
-	return &service{db: db}
+	if err = migrateMoneyColumns(db); err != nil {
+		log.Fatalf("failed to migrate money columns: %v", err)
+	}
+
+	if err = backfillReceivedAmounts(db); err != nil {
+		log.Fatalf("failed to backfill received amounts: %v", err)
+	}
+
+	if err = backfillAffectsBalance(db); err != nil {
+		log.Fatalf("failed to backfill affects_balance: %v", err)
+	}
+
+	if err = alignMoneyCurrencies(db); err != nil {
+		log.Fatalf("failed to align money currencies: %v", err)
+	}
+
+	if err = backfillLedger(db); err != nil {
+		log.Fatalf("failed to backfill ledger: %v", err)
+	}
+
+	if err = seedCategories(db); err != nil {
+		log.Fatalf("failed to seed categories: %v", err)
+	}
+
+	if err = migrateTransactionTags(db); err != nil {
+		log.Fatalf("failed to migrate transaction tags: %v", err)
+	}
+
+	if err = addTransactionSearch(db); err != nil {
+		log.Fatalf("failed to add transaction search: %v", err)
+	}
+
+	if err = addHistoryIndexes(db); err != nil {
+		log.Fatalf("failed to add history indexes: %v", err)
+	}
+
+	if err = addImportIndex(db); err != nil {
+		log.Fatalf("failed to add import index: %v", err)
+	}
+
+	if err = promoteAdmins(db, os.Getenv("ADMIN_EMAILS")); err != nil {
+		log.Fatalf("failed to promote admins: %v", err)
+	}
+
+	defaultLimits, err := defaultTransferLimits()
+	if err != nil {
+		log.Fatalf("invalid default transfer limits: %v", err)
+	}
+
+	return &service{db: db, defaultLimits: defaultLimits}
 }
 
@@ -0,0 +1,275 @@
+package database
+
+import (
+	"fmt"
+	"gorm.io/gorm"
+	"personal_budget_app/internal/models"
+	"strings"
+)
+
+// moneyColumns lists the float columns replaced by models.Money. AutoMigrate
+// adds the new <prefix>amount/<prefix>currency columns; migrateMoneyColumns
+// then copies the old values over and drops the float column.
+var moneyColumns = []struct {
+	model  interface{}
+	table  string
+	column string
+	prefix string
+}{
+	{&models.Card{}, "cards", "card_balance", "card_balance_"},
+	{&models.Transaction{}, "transactions", "transaction_amount", "transaction_amount_"},
+}
+
+func migrateMoneyColumns(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, c := range moneyColumns {
+			if !tx.Migrator().HasColumn(c.model, c.column) {
+				continue
+			}
+
+			// going through numeric keeps 0.1+0.2 style float noise out of the result
+			query := fmt.Sprintf("UPDATE %s SET %samount = ROUND(%s::numeric * ?)::bigint, %scurrency = ?",
+				c.table, c.prefix, c.column, c.prefix)
+			if err := tx.Exec(query, models.MinorPerMajor, models.DefaultCurrency).Error; err != nil {
+				return fmt.Errorf("migrating %s.%s: %v", c.table, c.column, err)
+			}
+
+			if err := tx.Migrator().DropColumn(c.model, c.column); err != nil {
+				return fmt.Errorf("dropping %s.%s: %v", c.table, c.column, err)
+			}
+
+			fmt.Printf("Migrated %s.%s to minor units\n", c.table, c.column)
+		}
+
+		return nil
+	})
+}
+
+// backfillReceivedAmounts fills the receiver side of transactions recorded
+// before cards had currencies; those always moved the same amount both ways.
+func backfillReceivedAmounts(db *gorm.DB) error {
+	return db.Exec(`UPDATE transactions
+		SET received_amount_amount = transaction_amount_amount,
+			received_amount_currency = transaction_amount_currency,
+			exchange_rate = 1
+		WHERE exchange_rate IS NULL`).Error
+}
+
+// promoteAdmins marks the accounts listed in ADMIN_EMAILS (comma separated)
+// as admins. There is no API for granting the role.
+func promoteAdmins(db *gorm.DB, emails string) error {
+	var list []string
+	for _, email := range strings.Split(emails, ",") {
+		if email = strings.TrimSpace(email); email != "" {
+			list = append(list, email)
+		}
+	}
+
+	if len(list) == 0 {
+		return nil
+	}
+
+	return db.Model(&models.Account{}).Where("email IN ?", list).Update("is_admin", true).Error
+}
+
+// backfillLedger builds the ledger for data recorded before it existed: the
+// entries of every transaction, then an opening entry per card for whatever
+// part of its balance those transactions do not explain. It runs only while
+// the ledger is empty.
+func backfillLedger(db *gorm.DB) error {
+	var count int64
+	if err := db.Model(&models.LedgerEntry{}).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	statements := []string{
+		// sender's card
+		`INSERT INTO ledger_entries (transaction_id, card_id, kind, direction, amount_amount, amount_currency, effective_at, created_at)
+		SELECT id, from_card_id, 'transfer', 'debit', transaction_amount_amount, transaction_amount_currency, transaction_time, NOW()
+		FROM transactions WHERE deleted_at IS NULL`,
+		// the outside world, for cross-currency transfers only
+		`INSERT INTO ledger_entries (transaction_id, card_id, kind, direction, amount_amount, amount_currency, effective_at, created_at)
+		SELECT id, NULL, 'transfer', 'credit', transaction_amount_amount, transaction_amount_currency, transaction_time, NOW()
+		FROM transactions WHERE deleted_at IS NULL AND transaction_amount_currency <> received_amount_currency`,
+		`INSERT INTO ledger_entries (transaction_id, card_id, kind, direction, amount_amount, amount_currency, effective_at, created_at)
+		SELECT id, NULL, 'transfer', 'debit', received_amount_amount, received_amount_currency, transaction_time, NOW()
+		FROM transactions WHERE deleted_at IS NULL AND transaction_amount_currency <> received_amount_currency`,
+		// receiver's card
+		`INSERT INTO ledger_entries (transaction_id, card_id, kind, direction, amount_amount, amount_currency, effective_at, created_at)
+		SELECT id, to_card_id, 'transfer', 'credit', received_amount_amount, received_amount_currency, transaction_time, NOW()
+		FROM transactions WHERE deleted_at IS NULL`,
+	}
+
+	// opening balances: the part of each balance the transfers above do not explain
+	opening := `WITH gaps AS (
+			SELECT c.id, c.created_at, c.card_balance_currency AS currency,
+				c.card_balance_amount - COALESCE(SUM(CASE WHEN e.direction = 'credit' THEN e.amount_amount ELSE -e.amount_amount END), 0) AS gap
+			FROM cards c
+			LEFT JOIN ledger_entries e ON e.card_id = c.id
+			GROUP BY c.id
+		)
+		INSERT INTO ledger_entries (transaction_id, card_id, kind, direction, amount_amount, amount_currency, effective_at, created_at)
+		SELECT NULL, %s, 'opening', CASE WHEN gap > 0 THEN '%s' ELSE '%s' END, ABS(gap), currency, created_at, NOW()
+		FROM gaps WHERE gap <> 0`
+	statements = append(statements,
+		fmt.Sprintf(opening, "NULL", models.LedgerDebit, models.LedgerCredit),
+		fmt.Sprintf(opening, "id", models.LedgerCredit, models.LedgerDebit),
+	)
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, statement := range statements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// seedCategories creates the built-in categories that do not exist yet.
+func seedCategories(db *gorm.DB) error {
+	return seedCategoryTree(db, models.BuiltinCategories, nil)
+}
+
+// seedCategoryTree creates the missing built-in categories under parentID.
+// Built-ins seeded before the tree existed are found by name and moved under
+// their parent.
+func seedCategoryTree(db *gorm.DB, nodes []models.BuiltinCategory, parentID *uint) error {
+	for _, node := range nodes {
+		category := models.Category{Name: node.Name, ParentID: parentID}
+		if err := db.Where("account_id IS NULL AND name = ?", node.Name).FirstOrCreate(&category).Error; err != nil {
+			return err
+		}
+
+		if category.ParentID == nil && parentID != nil {
+			if err := db.Model(&category).Update("parent_id", *parentID).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := seedCategoryTree(db, node.Children, &category.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backfillAffectsBalance marks the transactions recorded before entries made
+// by hand existed: they are all transfers and refunds that moved money.
+func backfillAffectsBalance(db *gorm.DB) error {
+	return db.Exec(`UPDATE transactions SET affects_balance = true WHERE affects_balance IS NULL`).Error
+}
+
+// alignMoneyCurrencies gives Money columns added to existing rows the
+// currency of the row they belong to instead of the column default.
+func alignMoneyCurrencies(db *gorm.DB) error {
+	statements := []string{
+		`UPDATE cards SET reserved_balance_currency = card_balance_currency
+		WHERE reserved_balance_currency <> card_balance_currency AND reserved_balance_amount = 0`,
+		`UPDATE transfer_limits SET confirmation_threshold_currency = min_amount_currency
+		WHERE confirmation_threshold_currency <> min_amount_currency AND confirmation_threshold_amount = 0`,
+	}
+
+	for _, statement := range statements {
+		if err := db.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateTransactionTags moves tags kept on the transaction row, shared by
+// both sides of a transfer, into transaction_tags as the sender's (or the
+// owner's, for entries made by hand). The search column built from the old
+// tags is dropped with them and added back by addTransactionSearch.
+func migrateTransactionTags(db *gorm.DB) error {
+	if !db.Migrator().HasColumn(&models.Transaction{}, "tags") {
+		return nil
+	}
+
+	statements := []string{
+		`INSERT INTO transaction_tags (transaction_id, account_id, tag)
+		SELECT DISTINCT t.id, COALESCE(t.account_id, c.account_id), tag
+		FROM transactions t
+		LEFT JOIN cards c ON c.id = t.from_card_id
+		CROSS JOIN LATERAL jsonb_array_elements_text(CASE WHEN jsonb_typeof(t.tags) = 'array' THEN t.tags ELSE '[]'::jsonb END) AS tag
+		WHERE COALESCE(t.account_id, c.account_id) IS NOT NULL
+		ON CONFLICT DO NOTHING`,
+		`ALTER TABLE transactions DROP COLUMN IF EXISTS search_vector`,
+		`DROP INDEX IF EXISTS idx_transactions_tags`,
+		`ALTER TABLE transactions DROP COLUMN tags`,
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, statement := range statements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// addTransactionSearch adds the full-text search column of transactions and
+// its GIN index. Postgres keeps search_vector up to date itself, so it is not
+// part of the model. Tags are kept per account and searched by prefix, which
+// needs an index with text_pattern_ops in any collation but C.
+func addTransactionSearch(db *gorm.DB) error {
+	statements := []string{
+		`ALTER TABLE transactions ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+			to_tsvector('simple', coalesce(description, '') || ' ' || coalesce(counterparty_name, '') || ' ' || coalesce(external_reference, ''))
+		) STORED`,
+		`CREATE INDEX IF NOT EXISTS idx_transactions_search_vector ON transactions USING GIN (search_vector)`,
+		`CREATE INDEX IF NOT EXISTS idx_transaction_tags_account_tag_prefix ON transaction_tags (account_id, tag text_pattern_ops)`,
+	}
+
+	for _, statement := range statements {
+		if err := db.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addImportIndex keeps an account from importing the same statement entry
+// twice. A FITID is only unique within its bank account, so the index
+// replaces an older one that had two bank accounts' entries collide.
+// Entries made by hand have no FITID and deleted ones can be imported again.
+func addImportIndex(db *gorm.DB) error {
+	statements := []string{
+		`DROP INDEX IF EXISTS idx_transactions_account_fit_id`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_transactions_account_fit_account_fit_id ON transactions (account_id, fit_account, fit_id)
+		WHERE fit_id <> '' AND deleted_at IS NULL`,
+	}
+
+	for _, statement := range statements {
+		if err := db.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addHistoryIndexes adds the indexes card history pages are read from: one
+// per side of a transfer, for each order a page can be sorted in.
+func addHistoryIndexes(db *gorm.DB) error {
+	statements := []string{
+		`CREATE INDEX IF NOT EXISTS idx_transactions_from_card_time ON transactions (from_card_id, transaction_time, id) WHERE deleted_at IS NULL`,
+		`CREATE INDEX IF NOT EXISTS idx_transactions_to_card_time ON transactions (to_card_id, transaction_time, id) WHERE deleted_at IS NULL`,
+		`CREATE INDEX IF NOT EXISTS idx_transactions_from_card_amount ON transactions (from_card_id, transaction_amount_amount, id) WHERE deleted_at IS NULL`,
+		`CREATE INDEX IF NOT EXISTS idx_transactions_to_card_amount ON transactions (to_card_id, received_amount_amount, id) WHERE deleted_at IS NULL`,
+	}
+
+	for _, statement := range statements {
+		if err := db.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -41,6 +41,19 @@ func (s *service) GetAccount(id uint) (*models.Account, error) {
 	return &account, nil  // Return a pointer to the loaded account
 }
 
+// AccountExists reports whether the account is there without loading its
+// cards and transactions the way GetAccount does.
+func (s *service) AccountExists(id uint) (bool, error) {
+	var count int64
+
+	result := s.db.Model(&models.Account{}).Where("id = ?", id).Limit(1).Count(&count)
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return count > 0, nil
+}
+
 func (s *service) CreateAccount(account *models.Account) error {
 	result := s.db.Create(account)
 	if result.Error != nil {
@@ -111,4 +124,15 @@ func (s *service) GetIdByEmail(email string) (uint, error)  {
 	return account.ID, nil
 }
 
+func (s *service) IsAdmin(accountID uint) (bool, error) {
+	var account models.Account
+
+	if err := s.db.Select("is_admin").First(&account, accountID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
 
+	return account.IsAdmin, nil
+}
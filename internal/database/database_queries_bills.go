@@ -0,0 +1,87 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"personal_budget_app/internal/models"
+	"time"
+)
+
+var ErrBillNotFound = errors.New("bill not found")
+
+func (s *service) CreateBill(bill *models.Bill) error {
+	result := s.db.Create(bill)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	fmt.Printf("Successfully created bill (id=%v) for user (id=%v)\n", bill.ID, bill.AccountID)
+	return nil
+}
+
+func (s *service) GetBills(accountID uint) ([]*models.Bill, error) {
+	var bills []*models.Bill
+
+	result := s.db.Where("account_id = ?", accountID).Order("due_day, id").Find(&bills)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return bills, nil
+}
+
+func (s *service) DeleteBill(id, accountID uint) error {
+	result := s.db.Where("account_id = ?", accountID).Delete(&models.Bill{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("%w: id=%v", ErrBillNotFound, id)
+	}
+
+	fmt.Printf("Successfully deleted bill (id=%v)\n", id)
+	return nil
+}
+
+// GetPaymentsTo lists the confirmed transfers in [from, to) from the
+// account's cards to any of cardNumbers, oldest first.
+func (s *service) GetPaymentsTo(accountID uint, cardNumbers []string, from, to time.Time) ([]*models.BillPayment, error) {
+	payments := []*models.BillPayment{}
+	if len(cardNumbers) == 0 {
+		return payments, nil
+	}
+
+	var rows []struct {
+		ID         uint
+		CardNumber string
+		Currency   string
+		Amount     int64
+		At         time.Time
+	}
+
+	result := s.db.Raw(`SELECT t.id, tc.card_number, t.transaction_amount_currency AS currency,
+			t.transaction_amount_amount AS amount, t.transaction_time AS at
+		FROM transactions t
+		JOIN cards fc ON fc.id = t.from_card_id
+		JOIN cards tc ON tc.id = t.to_card_id
+		WHERE fc.account_id = ? AND tc.card_number IN ?
+			AND t.kind = ? AND t.status = ? AND t.deleted_at IS NULL
+			AND t.transaction_time >= ? AND t.transaction_time < ?
+		ORDER BY t.transaction_time, t.id`,
+		accountID, cardNumbers, models.TransactionKindTransfer, models.TransactionConfirmed, from, to).
+		Scan(&rows)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	for _, row := range rows {
+		payments = append(payments, &models.BillPayment{
+			TransactionID: row.ID,
+			CardNumber:    row.CardNumber,
+			Amount:        models.NewMoney(row.Amount, row.Currency),
+			At:            row.At,
+		})
+	}
+
+	return payments, nil
+}
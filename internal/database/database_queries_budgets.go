@@ -0,0 +1,190 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"personal_budget_app/internal/models"
+	"time"
+)
+
+var ErrBudgetNotFound = errors.New("budget not found")
+
+// SetBudget creates the account's budget for the category and month, or
+// replaces the one already there.
+func (s *service) SetBudget(budget *models.Budget) error {
+	result := s.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "account_id"}, {Name: "category_id"}, {Name: "month"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"planned_amount", "planned_currency", "rollover", "updated_at", "deleted_at",
+		}),
+	}).Create(budget)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	fmt.Printf("Successfully set budget for category (id=%v) in %v for user (id=%v)\n", budget.CategoryID, budget.Month, budget.AccountID)
+	return nil
+}
+
+// GetBudgets lists the account's budgets for a month with their categories.
+func (s *service) GetBudgets(accountID uint, month string) ([]*models.Budget, error) {
+	var budgets []*models.Budget
+
+	result := s.db.Preload("Category").Where("account_id = ? AND month = ?", accountID, month).Order("category_id").Find(&budgets)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return budgets, nil
+}
+
+func (s *service) GetBudget(accountID, categoryID uint, month string) (*models.Budget, error) {
+	var budget models.Budget
+
+	result := s.db.Where("account_id = ? AND category_id = ? AND month = ?", accountID, categoryID, month).First(&budget)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: category=%v month=%v", ErrBudgetNotFound, categoryID, month)
+		}
+		return nil, result.Error
+	}
+
+	return &budget, nil
+}
+
+// DeleteBudget removes a budget of the given account. The row is removed
+// outright so the month can be budgeted again.
+func (s *service) DeleteBudget(id, accountID uint) error {
+	result := s.db.Unscoped().Where("account_id = ?", accountID).Delete(&models.Budget{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("%w: id=%v", ErrBudgetNotFound, id)
+	}
+
+	fmt.Printf("Successfully deleted budget (id=%v)\n", id)
+	return nil
+}
+
+// spendingLinesSQL selects every amount the account spent as (category_id,
+// counterparty, currency, amount, transaction_time) rows: confirmed transfers
+// from its cards to cards it does not own, less refunds of them, which count
+// against the category of the transfer they give back, and expenses entered
+// by hand. A split transaction gives one row per line, and a refund of it is
+// shared out between the lines in proportion, rounded down, with the minor
+// units left over going one each to the lines that lost the most to the
+// rounding, so the shares add up to the refund. Moves between the account's own
+// cards are not spending. The counterparty is the name the sender gave, or
+// else the receiving card's number.
+const spendingLinesSQL = `
+	SELECT CASE WHEN sp.id IS NULL THEN t.category_id ELSE sp.category_id END AS category_id,
+		COALESCE(NULLIF(t.counterparty_name, ''), tc.card_number) AS counterparty,
+		t.transaction_amount_currency AS currency, COALESCE(sp.amount_amount, t.transaction_amount_amount) AS amount,
+		t.transaction_time
+	FROM transactions t
+	JOIN cards fc ON fc.id = t.from_card_id
+	JOIN cards tc ON tc.id = t.to_card_id
+	LEFT JOIN transaction_splits sp ON sp.transaction_id = t.id
+	WHERE fc.account_id = @account AND tc.account_id <> @account
+		AND t.kind = @transfer AND t.status = @confirmed AND t.deleted_at IS NULL
+		AND t.transaction_time >= @from AND t.transaction_time < @to
+	UNION ALL
+	SELECT category_id, counterparty, currency, -(share + CASE WHEN place <= leftover THEN 1 ELSE 0 END), transaction_time
+	FROM (
+		SELECT CASE WHEN sp.id IS NULL THEN o.category_id ELSE sp.category_id END AS category_id,
+			COALESCE(NULLIF(o.counterparty_name, ''), tc.card_number) AS counterparty,
+			t.received_amount_currency AS currency, t.transaction_time,
+			COALESCE(t.received_amount_amount * sp.amount_amount / o.transaction_amount_amount, t.received_amount_amount) AS share,
+			t.received_amount_amount - SUM(COALESCE(t.received_amount_amount * sp.amount_amount / o.transaction_amount_amount,
+				t.received_amount_amount)) OVER (PARTITION BY t.id) AS leftover,
+			ROW_NUMBER() OVER (PARTITION BY t.id
+				ORDER BY t.received_amount_amount * sp.amount_amount % o.transaction_amount_amount DESC, sp.id) AS place
+		FROM transactions t
+		JOIN transactions o ON o.id = t.reversal_of_id
+		JOIN cards fc ON fc.id = o.from_card_id
+		JOIN cards tc ON tc.id = o.to_card_id
+		LEFT JOIN transaction_splits sp ON sp.transaction_id = o.id
+		WHERE fc.account_id = @account AND tc.account_id <> @account
+			AND t.kind = @refund AND t.deleted_at IS NULL
+			AND t.transaction_time >= @from AND t.transaction_time < @to
+	) refunds
+	UNION ALL
+	SELECT CASE WHEN sp.id IS NULL THEN t.category_id ELSE sp.category_id END, t.counterparty_name,
+		t.transaction_amount_currency, COALESCE(sp.amount_amount, t.transaction_amount_amount), t.transaction_time
+	FROM transactions t
+	LEFT JOIN transaction_splits sp ON sp.transaction_id = t.id
+	WHERE t.account_id = @account AND t.kind = @expense AND t.deleted_at IS NULL
+		AND t.transaction_time >= @from AND t.transaction_time < @to`
+
+func spendingLinesArgs(accountID uint, from, to time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"account":   accountID,
+		"from":      from,
+		"to":        to,
+		"transfer":  models.TransactionKindTransfer,
+		"refund":    models.TransactionKindRefund,
+		"expense":   models.TransactionKindExpense,
+		"income":    models.TransactionKindIncome,
+		"confirmed": models.TransactionConfirmed,
+	}
+}
+
+// GetCategorySpending sums what the account spent in [from, to) per category
+// and currency.
+func (s *service) GetCategorySpending(accountID uint, from, to time.Time) ([]*models.CategorySpending, error) {
+	var rows []struct {
+		CategoryID *uint
+		Currency   string
+		Amount     int64
+	}
+
+	result := s.db.Raw(`SELECT category_id, currency, SUM(amount) AS amount FROM (`+spendingLinesSQL+`) lines
+		GROUP BY category_id, currency ORDER BY category_id, currency`, spendingLinesArgs(accountID, from, to)).
+		Scan(&rows)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	spending := make([]*models.CategorySpending, 0, len(rows))
+	for _, row := range rows {
+		spending = append(spending, &models.CategorySpending{
+			CategoryID: row.CategoryID,
+			Spent:      models.NewMoney(row.Amount, row.Currency),
+		})
+	}
+
+	return spending, nil
+}
+
+// GetMonthlyCategorySpending is GetCategorySpending for every month in
+// [from, to) at once, keyed by month (YYYY-MM). Months are counted in UTC,
+// as budget months are; months with no spending are left out.
+func (s *service) GetMonthlyCategorySpending(accountID uint, from, to time.Time) (map[string][]*models.CategorySpending, error) {
+	var rows []struct {
+		Month      string
+		CategoryID *uint
+		Currency   string
+		Amount     int64
+	}
+
+	result := s.db.Raw(`SELECT to_char(transaction_time AT TIME ZONE 'UTC', 'YYYY-MM') AS month, category_id, currency, SUM(amount) AS amount
+		FROM (`+spendingLinesSQL+`) lines
+		GROUP BY month, category_id, currency ORDER BY month, category_id, currency`, spendingLinesArgs(accountID, from, to)).
+		Scan(&rows)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	spending := make(map[string][]*models.CategorySpending)
+	for _, row := range rows {
+		spending[row.Month] = append(spending[row.Month], &models.CategorySpending{
+			CategoryID: row.CategoryID,
+			Spent:      models.NewMoney(row.Amount, row.Currency),
+		})
+	}
+
+	return spending, nil
+}
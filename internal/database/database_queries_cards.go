@@ -8,22 +8,32 @@ import (
 )
 
 func (s *service) AddCard(card *models.Card) error {
-	// Check the current number of cards for the account
-	var count int64
-	result := s.db.Model(&models.Card{}).Where("account_id = ?", card.AccountID).Count(&count)
-	if result.Error != nil {
-		return result.Error
-	}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// Check the current number of cards for the account
+		var count int64
+		result := tx.Model(&models.Card{}).Where("account_id = ?", card.AccountID).Count(&count)
+		if result.Error != nil {
+			return result.Error
+		}
 
-	// Check if the account has already reached the maximum number of cards
-	if count >= 3 {
-		return fmt.Errorf("maximum number of cards (3) for account (id=%v) reached", card.AccountID)
-	}
+		// Check if the account has already reached the maximum number of cards
+		if count >= 3 {
+			return fmt.Errorf("maximum number of cards (3) for account (id=%v) reached", card.AccountID)
+		}
 
-	// If not, proceed with adding the new card
-	result = s.db.Create(card)
-	if result.Error != nil {
-		return result.Error
+		// If not, proceed with adding the new card
+		if err := tx.Create(card).Error; err != nil {
+			return err
+		}
+
+		// The starting balance enters the ledger as money from outside
+		if entries := models.NewOpeningEntries(card); len(entries) > 0 {
+			return tx.Create(entries).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
 	fmt.Printf("Successfully created card (id=%v) for user (id=%v)\n", card.ID, card.AccountID)
@@ -61,6 +71,18 @@ func (s *service) GetCards(accountID uint) ([]*models.Card, error) {
 	return cards, nil
 }
 
+// FindCards lists the account's cards without their transaction history.
+func (s *service) FindCards(accountID uint) ([]*models.Card, error) {
+	var cards []*models.Card
+
+	result := s.db.Where("account_id = ?", accountID).Order("id").Find(&cards)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return cards, nil
+}
+
 func (s *service) GetCard(cardID uint) (*models.Card, error) {
 	var card *models.Card  // Use a non-pointer Card struct here to avoid nil pointer dereference issues
 
@@ -0,0 +1,170 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"personal_budget_app/internal/models"
+)
+
+var ErrCategoryNotFound = errors.New("category not found")
+
+// GetCategories lists the built-in categories and the account's own,
+// leaving out archived ones unless includeArchived is set.
+func (s *service) GetCategories(accountID uint, includeArchived bool) ([]*models.Category, error) {
+	var categories []*models.Category
+
+	query := s.db.Where("account_id IS NULL OR account_id = ?", accountID)
+	if !includeArchived {
+		query = query.Where("archived = ?", false)
+	}
+
+	result := query.Order("id").Find(&categories)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return categories, nil
+}
+
+// GetCategory loads a category the account can see: built in or its own.
+func (s *service) GetCategory(id, accountID uint) (*models.Category, error) {
+	var category models.Category
+
+	result := s.db.Where("account_id IS NULL OR account_id = ?", accountID).First(&category, id)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: id=%v", ErrCategoryNotFound, id)
+		}
+		return nil, result.Error
+	}
+
+	return &category, nil
+}
+
+// CategoryVisibleTo reports whether the account may file transactions under
+// the category: it is built in or the account's own, and not archived.
+func (s *service) CategoryVisibleTo(categoryID, accountID uint) (bool, error) {
+	var count int64
+
+	result := s.db.Model(&models.Category{}).
+		Where("id = ? AND (account_id IS NULL OR account_id = ?) AND archived = ?", categoryID, accountID, false).
+		Count(&count)
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return count > 0, nil
+}
+
+func (s *service) CreateCategory(category *models.Category) error {
+	result := s.db.Create(category)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	fmt.Printf("Successfully created category (id=%v) for user (id=%v)\n", category.ID, *category.AccountID)
+	return nil
+}
+
+func (s *service) UpdateCategory(category *models.Category) error {
+	result := s.db.Model(category).Select("name", "parent_id", "archived", "updated_at").Updates(category)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	fmt.Printf("Successfully updated category (id=%v)\n", category.ID)
+	return nil
+}
+
+// DeleteCategory deletes one of the account's own categories. Its
+// transactions, split lines and the rules filing into it move to fallbackID, its
+// subcategories move up to its parent, and its budgets move to fallbackID. In
+// months where fallbackID has a budget already, that budget takes on the
+// planned amount too, as it now gets the spending that went with it.
+// It returns how many transactions were reassigned.
+func (s *service) DeleteCategory(id, accountID, fallbackID uint) (int64, error) {
+	var moved int64
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var category models.Category
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("account_id = ?", accountID).First(&category, id)
+		if result.Error != nil {
+			if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("%w: id=%v", ErrCategoryNotFound, id)
+			}
+			return result.Error
+		}
+
+		result = tx.Model(&models.Transaction{}).Where("category_id = ?", id).Update("category_id", fallbackID)
+		if result.Error != nil {
+			return result.Error
+		}
+		moved = result.RowsAffected
+
+		if err := tx.Model(&models.TransactionSplit{}).Where("category_id = ?", id).Update("category_id", fallbackID).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.Category{}).Where("parent_id = ?", id).Update("parent_id", category.ParentID).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.CategoryRule{}).Where("category_id = ?", id).Update("category_id", fallbackID).Error; err != nil {
+			return err
+		}
+
+		if err := mergeBudgets(tx, id, fallbackID); err != nil {
+			return err
+		}
+
+		return tx.Delete(&category).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	fmt.Printf("Successfully deleted category (id=%v), %v transactions moved to category (id=%v)\n", id, moved, fallbackID)
+	return moved, nil
+}
+
+// mergeBudgets moves the budgets of category id to fallbackID. A month
+// fallbackID already has a budget for keeps that budget, with the planned
+// amount of the moved one added at the stored exchange rate.
+func mergeBudgets(tx *gorm.DB, id, fallbackID uint) error {
+	var budgets []*models.Budget
+	if err := tx.Unscoped().Where("category_id = ?", id).Find(&budgets).Error; err != nil {
+		return err
+	}
+
+	for _, budget := range budgets {
+		var target models.Budget
+		result := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("account_id = ? AND category_id = ? AND month = ?", budget.AccountID, fallbackID, budget.Month).
+			First(&target)
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			if err := tx.Unscoped().Model(budget).Update("category_id", fallbackID).Error; err != nil {
+				return err
+			}
+			continue
+		}
+		if result.Error != nil {
+			return result.Error
+		}
+
+		rate, err := findRate(tx, budget.Planned.Currency, target.Planned.Currency)
+		if err != nil {
+			return err
+		}
+		planned := target.Planned.Add(models.ConvertMoney(budget.Planned, rate, target.Planned.Currency))
+		if err := tx.Unscoped().Model(&target).UpdateColumn("planned_amount", planned.Amount).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Delete(budget).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,196 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"personal_budget_app/internal/models"
+)
+
+var ErrEntryNotFound = errors.New("entry not found")
+
+// uniqueViolation is the Postgres error code for a broken unique index.
+const uniqueViolation = "23505"
+
+// AddManualEntry records an income or expense entered by hand. When the
+// entry affects its card's balance the card is locked, the balance changes
+// and the ledger gets the matching entries, all in one database transaction;
+// otherwise the entry is only recorded.
+func (s *service) AddManualEntry(ts *models.Transaction) error {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		return addManualEntry(tx, ts)
+	})
+	if err != nil {
+		fmt.Printf("Error adding %v entry for user (id=%v): %v\n", ts.Kind, *ts.AccountID, err)
+		return err
+	}
+
+	fmt.Printf("Successfully added %v entry (id=%v) for user (id=%v)\n", ts.Kind, ts.ID, *ts.AccountID)
+	return nil
+}
+
+func addManualEntry(tx *gorm.DB, ts *models.Transaction) error {
+	if !ts.AffectsBalance {
+		if ts.TransactionAmount.Currency == "" {
+			ts.TransactionAmount.Currency = models.DefaultCurrency
+			if cardID := ts.ManualCardID(); cardID != nil {
+				var card models.Card
+				if err := tx.Select("card_balance_currency").First(&card, *cardID).Error; err != nil {
+					return err
+				}
+				ts.TransactionAmount.Currency = card.CardBalance.Currency
+			}
+		}
+		ts.ReceivedAmount = ts.TransactionAmount
+		if err := tx.Create(ts).Error; err != nil {
+			return err
+		}
+		return setTags(tx, ts.ID, *ts.AccountID, ts.Tags)
+	}
+
+	cardID := *ts.ManualCardID()
+	cards, err := lockCards(tx, cardID)
+	if err != nil {
+		return err
+	}
+	card := cards[cardID]
+
+	// an amount without a currency is taken in the card's currency
+	if ts.TransactionAmount.Currency == "" {
+		ts.TransactionAmount.Currency = card.CardBalance.Currency
+	}
+	if !card.CardBalance.SameCurrency(ts.TransactionAmount) {
+		return fmt.Errorf("%w: amount is in %v but the card holds %v",
+			ErrCurrencyMismatch, ts.TransactionAmount.Currency, card.CardBalance.Currency)
+	}
+	ts.ReceivedAmount = ts.TransactionAmount
+
+	change := ts.TransactionAmount.Amount
+	if ts.Kind == models.TransactionKindExpense {
+		if card.Available().Cmp(ts.TransactionAmount) < 0 {
+			return ErrInsufficientFunds
+		}
+		change = -change
+	}
+
+	if err := tx.Create(ts).Error; err != nil {
+		return err
+	}
+	if err := setTags(tx, ts.ID, *ts.AccountID, ts.Tags); err != nil {
+		return err
+	}
+
+	if err := tx.Model(&models.Card{}).Where("id = ?", cardID).
+		UpdateColumn("card_balance_amount", gorm.Expr("card_balance_amount + ?", change)).Error; err != nil {
+		return err
+	}
+
+	return tx.Create(models.NewManualEntries(ts, false)).Error
+}
+
+// ImportManualEntry adds an entry read from a bank statement unless the
+// account already has one with the same FITID from the same bank account,
+// and reports whether it did. Entries imported before bank accounts were
+// recorded have none and match any. The unique index on (account_id,
+// fit_account, fit_id) settles two imports racing for the same entry.
+func (s *service) ImportManualEntry(ts *models.Transaction) (bool, error) {
+	imported := false
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.Transaction{}).Where("account_id = ? AND fit_account IN ? AND fit_id = ?", *ts.AccountID, []string{ts.FITAccount, ""}, ts.FITID).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return nil
+		}
+
+		imported = true
+		return addManualEntry(tx, ts)
+	})
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+		return false, nil
+	}
+	if err != nil {
+		fmt.Printf("Error importing %v entry (fitId=%v) for user (id=%v): %v\n", ts.Kind, ts.FITID, *ts.AccountID, err)
+		return false, err
+	}
+
+	if imported {
+		fmt.Printf("Successfully imported %v entry (id=%v) for user (id=%v)\n", ts.Kind, ts.ID, *ts.AccountID)
+	}
+	return imported, nil
+}
+
+// GetManualEntries lists the income and expenses the account entered by hand,
+// newest first.
+func (s *service) GetManualEntries(accountID uint, filter *models.TransactionFilter) ([]*models.Transaction, error) {
+	var entries []*models.Transaction
+
+	result := applyTransactionFilter(s.db.Preload("Splits").Where("account_id = ? AND kind IN ?", accountID,
+		[]string{models.TransactionKindIncome, models.TransactionKindExpense}), accountID, filter).
+		Order("transaction_time DESC, id DESC").
+		Find(&entries)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if err := s.LoadTags(accountID, entries...); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// DeleteManualEntry deletes an entry the account made by hand. If it changed
+// a card's balance the change is undone and the ledger gets reversing
+// entries; ledger entries themselves are never removed.
+func (s *service) DeleteManualEntry(id, accountID uint) error {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var ts models.Transaction
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("account_id = ? AND kind IN ?", accountID, []string{models.TransactionKindIncome, models.TransactionKindExpense}).
+			First(&ts, id)
+		if result.Error != nil {
+			if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("%w: id=%v", ErrEntryNotFound, id)
+			}
+			return result.Error
+		}
+
+		if ts.AffectsBalance {
+			cardID := *ts.ManualCardID()
+			cards, err := lockCards(tx, cardID)
+			if err != nil {
+				return err
+			}
+
+			change := -ts.TransactionAmount.Amount
+			if ts.Kind == models.TransactionKindExpense {
+				change = -change
+			} else if cards[cardID].Available().Cmp(ts.TransactionAmount) < 0 {
+				// the income was already spent
+				return ErrInsufficientFunds
+			}
+
+			if err := tx.Model(&models.Card{}).Where("id = ?", cardID).
+				UpdateColumn("card_balance_amount", gorm.Expr("card_balance_amount + ?", change)).Error; err != nil {
+				return err
+			}
+
+			if err := tx.Create(models.NewManualEntries(&ts, true)).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Delete(&ts).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Successfully deleted entry (id=%v)\n", id)
+	return nil
+}
@@ -0,0 +1,78 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"math/big"
+	"personal_budget_app/internal/models"
+)
+
+var ErrExchangeRateNotFound = errors.New("exchange rate not found")
+
+// SaveExchangeRates inserts the given rates, replacing any existing rate for
+// the same currency pair. Either every rate is saved or none is.
+func (s *service) SaveExchangeRates(rates []*models.ExchangeRate) error {
+	if len(rates) == 0 {
+		return nil
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "base_currency"}, {Name: "quote_currency"}},
+			DoUpdates: clause.AssignmentColumns([]string{"rate", "updated_at", "deleted_at"}),
+		}).Create(&rates).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Successfully loaded %v exchange rates\n", len(rates))
+	return nil
+}
+
+func (s *service) GetExchangeRates() ([]*models.ExchangeRate, error) {
+	var rates []*models.ExchangeRate
+
+	result := s.db.Order("base_currency, quote_currency").Find(&rates)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return rates, nil
+}
+
+// findRate returns how many units of `to` one unit of `from` buys. A stored
+// rate for the opposite pair is inverted when there is no direct one.
+func findRate(tx *gorm.DB, from, to string) (*big.Rat, error) {
+	if from == to {
+		return big.NewRat(1, 1), nil
+	}
+
+	var rates []*models.ExchangeRate
+	result := tx.Where("(base_currency = ? AND quote_currency = ?) OR (base_currency = ? AND quote_currency = ?)",
+		from, to, to, from).Find(&rates)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	var inverse *models.ExchangeRate
+	for _, rate := range rates {
+		if rate.BaseCurrency == from {
+			return models.ParseRate(rate.Rate)
+		}
+		inverse = rate
+	}
+
+	if inverse == nil {
+		return nil, fmt.Errorf("%w: %v -> %v", ErrExchangeRateNotFound, from, to)
+	}
+
+	r, err := models.ParseRate(inverse.Rate)
+	if err != nil {
+		return nil, err
+	}
+
+	return models.RoundRate(r.Inv(r)), nil
+}
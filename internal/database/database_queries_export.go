@@ -0,0 +1,78 @@
+package database
+
+import (
+	"gorm.io/gorm"
+	"personal_budget_app/internal/models"
+)
+
+// exportColumnsSQL adds the card numbers, category names and tags an export
+// shows to each transaction. Split transactions list the categories of their
+// lines; the tags are the ones of the account given as its one parameter.
+const exportColumnsSQL = `transactions.*,
+	COALESCE((SELECT card_number FROM cards WHERE cards.id = transactions.from_card_id), '') AS from_card_number,
+	COALESCE((SELECT card_number FROM cards WHERE cards.id = transactions.to_card_id), '') AS to_card_number,
+	COALESCE((SELECT string_agg(categories.name, ', ' ORDER BY transaction_splits.id)
+			FROM transaction_splits JOIN categories ON categories.id = transaction_splits.category_id
+			WHERE transaction_splits.transaction_id = transactions.id),
+		(SELECT name FROM categories WHERE categories.id = transactions.category_id), '') AS category_name,
+	COALESCE((SELECT json_agg(transaction_tags.tag ORDER BY transaction_tags.tag)
+			FROM transaction_tags
+			WHERE transaction_tags.transaction_id = transactions.id AND transaction_tags.account_id = ?), '[]') AS tag_list`
+
+// exportRow is a row selected with exportColumnsSQL.
+type exportRow struct {
+	models.Transaction
+	FromCardNumber string
+	ToCardNumber   string
+	CategoryName   string
+	TagList        []string `gorm:"serializer:json"`
+}
+
+// ExportTransactions calls each with the transactions of one of the account's
+// cards of the given type (incoming, outgoing or both), or with all of the
+// account's transactions when cardID is nil, oldest first. Rows are read one
+// at a time, so an export of any size is never held in memory. Direction and
+// Amount are left for the caller.
+func (s *service) ExportTransactions(accountID uint, cardID *uint, transactionType string, filter *models.TransactionFilter, each func(*models.ExportRow) error) error {
+	var branches []historyBranch
+	if cardID != nil {
+		branches, _ = cardScope(s.db, *cardID, transactionType)
+	} else {
+		owned, err := s.ownedCardIDs(accountID)
+		if err != nil {
+			return err
+		}
+		branches, _ = accountScope(s.db, accountID, owned)
+	}
+
+	rows, err := unionBranches(s.db, branches, func(branch historyBranch) *gorm.DB {
+		return applyAmountFilter(applyTransactionFilter(branch.db, accountID, filter), branch.side, filter)
+	}).
+		Select(exportColumnsSQL, accountID).
+		Order("transaction_time, id").
+		Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var row exportRow
+		if err := s.db.ScanRows(rows, &row); err != nil {
+			return err
+		}
+
+		ts := row.Transaction
+		ts.Tags = row.TagList
+		if err := each(&models.ExportRow{
+			Transaction:    &ts,
+			FromCardNumber: row.FromCardNumber,
+			ToCardNumber:   row.ToCardNumber,
+			Category:       row.CategoryName,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
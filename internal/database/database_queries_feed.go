@@ -0,0 +1,26 @@
+package database
+
+import "personal_budget_app/internal/models"
+
+// GetAccountFeed reads one page of the transactions of all of the account's
+// cards and its entries, each once however many of its cards it touches,
+// with the account's own tags.
+// Amounts go by the account's side: what left it when one of its cards sent
+// the money, otherwise what arrived.
+func (s *service) GetAccountFeed(accountID uint, filter *models.TransactionFilter, page *models.HistoryPageRequest) (*models.HistoryPage, error) {
+	owned, err := s.ownedCardIDs(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	branches, side := accountScope(s.db, accountID, owned)
+	history, err := cardHistory(s.db, branches, side, accountID, filter, page)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.LoadTags(accountID, history.Transactions...); err != nil {
+		return nil, err
+	}
+
+	return history, nil
+}
@@ -0,0 +1,68 @@
+package database
+
+import (
+	"personal_budget_app/internal/models"
+	"time"
+)
+
+// GetCardMovements lists the money that moved between the account's cards
+// and the outside world since `since`, oldest first: confirmed transfers to
+// and from other people's cards, and entries made by hand that changed a
+// card's balance. Amounts are in the card's currency. Moves between the
+// account's own cards and refunds are left out.
+func (s *service) GetCardMovements(accountID uint, since time.Time) ([]*models.CardMovement, error) {
+	var rows []struct {
+		CardID       uint
+		Incoming     bool
+		Counterparty string
+		Currency     string
+		Amount       int64
+		At           time.Time
+	}
+
+	result := s.db.Raw(`
+		SELECT t.from_card_id AS card_id, false AS incoming, tc.card_number AS counterparty,
+			t.transaction_amount_currency AS currency, t.transaction_amount_amount AS amount, t.transaction_time AS at
+		FROM transactions t
+		JOIN cards fc ON fc.id = t.from_card_id
+		JOIN cards tc ON tc.id = t.to_card_id
+		WHERE fc.account_id = @account AND tc.account_id <> @account
+			AND t.kind = @transfer AND t.status = @confirmed AND t.deleted_at IS NULL AND t.transaction_time >= @since
+		UNION ALL
+		SELECT t.to_card_id, true, fc.card_number, t.received_amount_currency, t.received_amount_amount, t.transaction_time
+		FROM transactions t
+		JOIN cards fc ON fc.id = t.from_card_id
+		JOIN cards tc ON tc.id = t.to_card_id
+		WHERE tc.account_id = @account AND fc.account_id <> @account
+			AND t.kind = @transfer AND t.status = @confirmed AND t.deleted_at IS NULL AND t.transaction_time >= @since
+		UNION ALL
+		SELECT COALESCE(t.to_card_id, t.from_card_id), t.kind = @income, COALESCE(NULLIF(t.counterparty_name, ''), t.description, ''),
+			t.transaction_amount_currency, t.transaction_amount_amount, t.transaction_time
+		FROM transactions t
+		WHERE t.account_id = @account AND t.kind IN (@income, @expense) AND t.affects_balance
+			AND t.deleted_at IS NULL AND t.transaction_time >= @since
+		ORDER BY at`, map[string]interface{}{
+		"account":   accountID,
+		"since":     since,
+		"transfer":  models.TransactionKindTransfer,
+		"income":    models.TransactionKindIncome,
+		"expense":   models.TransactionKindExpense,
+		"confirmed": models.TransactionConfirmed,
+	}).Scan(&rows)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	movements := make([]*models.CardMovement, 0, len(rows))
+	for _, row := range rows {
+		movements = append(movements, &models.CardMovement{
+			CardID:       row.CardID,
+			Incoming:     row.Incoming,
+			Counterparty: row.Counterparty,
+			Amount:       models.NewMoney(row.Amount, row.Currency),
+			At:           row.At,
+		})
+	}
+
+	return movements, nil
+}
@@ -0,0 +1,171 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"personal_budget_app/internal/models"
+	"time"
+)
+
+var ErrGoalNotFound = errors.New("savings goal not found")
+
+// CreateSavingsGoal saves a goal together with the scheduled transfer that
+// pays into it, if there is one.
+func (s *service) CreateSavingsGoal(goal *models.SavingsGoal, contribution *models.ScheduledTransfer) error {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if contribution != nil {
+			if err := tx.Create(contribution).Error; err != nil {
+				return err
+			}
+			goal.ContributionID = &contribution.ID
+			goal.Contribution = contribution
+		}
+
+		return tx.Omit("Contribution").Create(goal).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Successfully created savings goal (id=%v) for user (id=%v)\n", goal.ID, goal.AccountID)
+	return nil
+}
+
+func (s *service) GetSavingsGoals(accountID uint) ([]*models.SavingsGoal, error) {
+	var goals []*models.SavingsGoal
+
+	result := s.db.Preload("Contribution").Where("account_id = ?", accountID).Order("deadline, id").Find(&goals)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return goals, nil
+}
+
+func (s *service) GetSavingsGoal(id, accountID uint) (*models.SavingsGoal, error) {
+	var goal models.SavingsGoal
+
+	result := s.db.Preload("Contribution").Where("account_id = ?", accountID).First(&goal, id)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: id=%v", ErrGoalNotFound, id)
+		}
+		return nil, result.Error
+	}
+
+	return &goal, nil
+}
+
+// SetGoalContribution replaces the automatic contribution of a goal. The old
+// scheduled transfer is cancelled, keeping its run history; a nil
+// contribution just stops paying into the goal.
+func (s *service) SetGoalContribution(id, accountID uint, contribution *models.ScheduledTransfer) (*models.SavingsGoal, error) {
+	var goal models.SavingsGoal
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("account_id = ?", accountID).First(&goal, id)
+		if result.Error != nil {
+			if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("%w: id=%v", ErrGoalNotFound, id)
+			}
+			return result.Error
+		}
+
+		if err := cancelContribution(tx, goal.ContributionID); err != nil {
+			return err
+		}
+
+		goal.ContributionID = nil
+		if contribution != nil {
+			if err := tx.Create(contribution).Error; err != nil {
+				return err
+			}
+			goal.ContributionID = &contribution.ID
+			goal.Contribution = contribution
+		}
+
+		return tx.Model(&goal).Update("contribution_id", goal.ContributionID).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &goal, nil
+}
+
+// DeleteSavingsGoal deletes a goal and cancels its automatic contribution.
+// The money stays on the card.
+func (s *service) DeleteSavingsGoal(id, accountID uint) error {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var goal models.SavingsGoal
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("account_id = ?", accountID).First(&goal, id)
+		if result.Error != nil {
+			if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("%w: id=%v", ErrGoalNotFound, id)
+			}
+			return result.Error
+		}
+
+		if err := cancelContribution(tx, goal.ContributionID); err != nil {
+			return err
+		}
+
+		return tx.Delete(&goal).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Successfully deleted savings goal (id=%v)\n", id)
+	return nil
+}
+
+func cancelContribution(tx *gorm.DB, scheduleID *uint) error {
+	if scheduleID == nil {
+		return nil
+	}
+
+	return tx.Model(&models.ScheduledTransfer{}).
+		Where("id = ? AND status IN ?", *scheduleID, []string{models.ScheduleActive, models.SchedulePaused}).
+		Updates(map[string]interface{}{"status": models.ScheduleCancelled, "next_run_at": nil}).Error
+}
+
+// FindCard loads a card without its transaction history.
+func (s *service) FindCard(cardID uint) (*models.Card, error) {
+	var card models.Card
+
+	result := s.db.First(&card, cardID)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: id=%v", ErrCardNotFound, cardID)
+		}
+		return nil, result.Error
+	}
+
+	return &card, nil
+}
+
+// GetCardNetChange is how much a card's balance rose (or fell, if negative)
+// since `since`, according to its ledger.
+func (s *service) GetCardNetChange(cardID uint, since time.Time) (models.Money, error) {
+	var row struct {
+		Currency string
+		Net      int64
+	}
+
+	result := s.db.Raw(`SELECT c.card_balance_currency AS currency, COALESCE(SUM(`+ledgerNet+`), 0) AS net
+		FROM cards c
+		LEFT JOIN ledger_entries e ON e.card_id = c.id AND e.effective_at >= ?
+		WHERE c.id = ?
+		GROUP BY c.id`, since, cardID).Scan(&row)
+	if result.Error != nil {
+		return models.Money{}, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return models.Money{}, fmt.Errorf("%w: id=%v", ErrCardNotFound, cardID)
+	}
+
+	return models.NewMoney(row.Net, row.Currency), nil
+}
@@ -0,0 +1,55 @@
+package database
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"personal_budget_app/internal/models"
+	"time"
+)
+
+// ClaimIdempotencyKey stores key unless the account already holds a live
+// record with the same key. It returns true when key was stored and the
+// caller should run the request; otherwise it returns the existing record.
+func (s *service) ClaimIdempotencyKey(key *models.IdempotencyKey) (*models.IdempotencyKey, bool, error) {
+	var existing *models.IdempotencyKey
+	claimed := false
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// an expired key is free to be used again
+		result := tx.Where("account_id = ? AND key = ? AND expires_at <= ?", key.AccountID, key.Key, time.Now()).
+			Delete(&models.IdempotencyKey{})
+		if result.Error != nil {
+			return result.Error
+		}
+
+		result = tx.Clauses(clause.OnConflict{DoNothing: true}).Create(key)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 1 {
+			claimed = true
+			return nil
+		}
+
+		existing = new(models.IdempotencyKey)
+		return tx.Where("account_id = ? AND key = ?", key.AccountID, key.Key).First(existing).Error
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	return existing, claimed, nil
+}
+
+func (s *service) SaveIdempotentResponse(accountID uint, key string, status int, body []byte) error {
+	result := s.db.Model(&models.IdempotencyKey{}).Where("account_id = ? AND key = ?", accountID, key).
+		Updates(map[string]interface{}{"response_status": status, "response_body": body})
+	return result.Error
+}
+
+// ReleaseIdempotencyKey forgets a key whose request failed on our side, so the
+// client's retry is processed instead of replaying the error.
+func (s *service) ReleaseIdempotencyKey(accountID uint, key string) error {
+	result := s.db.Where("account_id = ? AND key = ?", accountID, key).Delete(&models.IdempotencyKey{})
+	return result.Error
+}
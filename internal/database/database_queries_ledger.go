@@ -0,0 +1,76 @@
+package database
+
+import (
+	"fmt"
+	"personal_budget_app/internal/models"
+	"time"
+)
+
+// ledgerNet is the signed value of an entry: credits add, debits subtract.
+const ledgerNet = "CASE WHEN e.direction = 'credit' THEN e.amount_amount ELSE -e.amount_amount END"
+
+// ReconcileLedger compares every card's stored balance with the sum of its
+// ledger entries and checks that each transaction's entries net to zero per
+// currency. It only reads; fixing a drift is left to a person.
+func (s *service) ReconcileLedger() (*models.ReconciliationReport, error) {
+	report := &models.ReconciliationReport{
+		CheckedAt:              time.Now(),
+		Drifts:                 []*models.CardDrift{},
+		UnbalancedTransactions: []*models.UnbalancedTransaction{},
+	}
+
+	var drifts []struct {
+		CardID       uint
+		StoredAmount int64
+		LedgerAmount int64
+		Currency     string
+	}
+	result := s.db.Raw(`SELECT c.id AS card_id, c.card_balance_amount AS stored_amount,
+			COALESCE(SUM(` + ledgerNet + `), 0) AS ledger_amount, c.card_balance_currency AS currency
+		FROM cards c
+		LEFT JOIN ledger_entries e ON e.card_id = c.id
+		WHERE c.deleted_at IS NULL
+		GROUP BY c.id
+		HAVING c.card_balance_amount <> COALESCE(SUM(` + ledgerNet + `), 0)
+		ORDER BY c.id`).Scan(&drifts)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	for _, d := range drifts {
+		report.Drifts = append(report.Drifts, &models.CardDrift{
+			CardID:        d.CardID,
+			StoredBalance: models.NewMoney(d.StoredAmount, d.Currency),
+			LedgerBalance: models.NewMoney(d.LedgerAmount, d.Currency),
+		})
+	}
+
+	var unbalanced []struct {
+		TransactionID uint
+		Imbalance     int64
+		Currency      string
+	}
+	result = s.db.Raw(`SELECT e.transaction_id, SUM(` + ledgerNet + `) AS imbalance, e.amount_currency AS currency
+		FROM ledger_entries e
+		WHERE e.transaction_id IS NOT NULL
+		GROUP BY e.transaction_id, e.amount_currency
+		HAVING SUM(` + ledgerNet + `) <> 0
+		ORDER BY e.transaction_id`).Scan(&unbalanced)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	for _, u := range unbalanced {
+		report.UnbalancedTransactions = append(report.UnbalancedTransactions, &models.UnbalancedTransaction{
+			TransactionID: u.TransactionID,
+			Imbalance:     models.NewMoney(u.Imbalance, u.Currency),
+		})
+	}
+
+	if len(report.Drifts) > 0 || len(report.UnbalancedTransactions) > 0 {
+		fmt.Printf("Ledger reconciliation: %v drifted cards, %v unbalanced transactions\n",
+			len(report.Drifts), len(report.UnbalancedTransactions))
+	}
+
+	return report, nil
+}
@@ -0,0 +1,221 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"os"
+	"personal_budget_app/internal/models"
+	"time"
+)
+
+// defaultTransferLimits reads the limits for accounts without their own from
+// TRANSFER_LIMITS_CURRENCY, TRANSFER_MIN_AMOUNT, TRANSFER_MAX_AMOUNT,
+// TRANSFER_DAILY_LIMIT and TRANSFER_MONTHLY_LIMIT.
+func defaultTransferLimits() (models.TransferLimits, error) {
+	currency := os.Getenv("TRANSFER_LIMITS_CURRENCY")
+	if currency == "" {
+		currency = models.DefaultCurrency
+	}
+
+	env := func(name, fallback string) (models.Money, error) {
+		value := os.Getenv(name)
+		if value == "" {
+			value = fallback
+		}
+
+		m, err := models.ParseMoney(value, currency)
+		if err != nil {
+			return models.Money{}, fmt.Errorf("%v: %v", name, err)
+		}
+		return m, nil
+	}
+
+	limits := models.TransferLimits{ConfirmationThreshold: models.NewMoney(0, currency)}
+	var err error
+	if limits.MinAmount, err = env("TRANSFER_MIN_AMOUNT", "100"); err != nil {
+		return limits, err
+	}
+	if limits.MaxAmount, err = env("TRANSFER_MAX_AMOUNT", "100000"); err != nil {
+		return limits, err
+	}
+	if limits.DailyLimit, err = env("TRANSFER_DAILY_LIMIT", "500000"); err != nil {
+		return limits, err
+	}
+	if limits.MonthlyLimit, err = env("TRANSFER_MONTHLY_LIMIT", "3000000"); err != nil {
+		return limits, err
+	}
+
+	return limits, limits.Validate()
+}
+
+// GetTransferLimits returns the account's own limits, or a copy of the
+// defaults (with no ID) when it has none.
+func (s *service) GetTransferLimits(accountID uint) (*models.TransferLimits, error) {
+	var limits models.TransferLimits
+
+	result := s.db.Where("account_id = ?", accountID).First(&limits)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			defaults := s.defaultLimits
+			defaults.AccountID = accountID
+			return &defaults, nil
+		}
+		return nil, result.Error
+	}
+
+	return &limits, nil
+}
+
+func (s *service) SetTransferLimits(limits *models.TransferLimits) error {
+	if err := limits.Validate(); err != nil {
+		return err
+	}
+
+	// a row loaded earlier is saved in place; defaults become the account's own row
+	if limits.ID != 0 {
+		if err := s.db.Save(limits).Error; err != nil {
+			return err
+		}
+		fmt.Printf("Successfully updated transfer limits for user (id=%v)\n", limits.AccountID)
+		return nil
+	}
+
+	result := s.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "account_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"min_amount_amount", "min_amount_currency", "max_amount_amount", "max_amount_currency",
+			"daily_limit_amount", "daily_limit_currency", "monthly_limit_amount", "monthly_limit_currency",
+			"confirmation_threshold_amount", "confirmation_threshold_currency",
+			"updated_at", "deleted_at",
+		}),
+	}).Create(limits)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	fmt.Printf("Successfully updated transfer limits for user (id=%v)\n", limits.AccountID)
+	return nil
+}
+
+// TransferLimitError is a transfer that would take its account past its
+// daily or monthly limit.
+type TransferLimitError struct {
+	Monthly   bool
+	Limit     models.Money
+	Remaining models.Money
+}
+
+func (e *TransferLimitError) Error() string {
+	if e.Monthly {
+		return fmt.Sprintf("Monthly transfer limit of %v reached, %v left this month", e.Limit, e.Remaining)
+	}
+	return fmt.Sprintf("Daily transfer limit of %v reached, %v left today", e.Limit, e.Remaining)
+}
+
+// GetTransferUsage sums, in the given currency, what the account has sent to
+// cards it does not own since `since`, pending transfers included. Moves
+// between its own cards and refunds do not count towards the limits.
+func (s *service) GetTransferUsage(accountID uint, since time.Time, currency string) (models.Money, error) {
+	return transferUsage(s.db, accountID, since, currency)
+}
+
+func transferUsage(tx *gorm.DB, accountID uint, since time.Time, currency string) (models.Money, error) {
+	var sums []struct {
+		Currency string
+		Total    int64
+	}
+
+	result := tx.Table("transactions t").
+		Select("t.transaction_amount_currency AS currency, SUM(t.transaction_amount_amount) AS total").
+		Joins("JOIN cards fc ON fc.id = t.from_card_id").
+		Joins("JOIN cards tc ON tc.id = t.to_card_id").
+		Where("fc.account_id = ? AND tc.account_id <> ?", accountID, accountID).
+		Where("t.kind = ? AND t.transaction_time >= ? AND t.deleted_at IS NULL", models.TransactionKindTransfer, since).
+		Where("t.status IN ?", []string{models.TransactionPending, models.TransactionConfirmed}).
+		Group("t.transaction_amount_currency").
+		Scan(&sums)
+	if result.Error != nil {
+		return models.Money{}, result.Error
+	}
+
+	total := models.NewMoney(0, currency)
+	for _, sum := range sums {
+		rate, err := findRate(tx, sum.Currency, currency)
+		if err != nil {
+			return models.Money{}, err
+		}
+		total = total.Add(models.ConvertMoney(models.NewMoney(sum.Total, sum.Currency), rate, currency))
+	}
+
+	return total, nil
+}
+
+// checkTransferQuota fails with a TransferLimitError when ts would take the
+// sending account past the daily or monthly limit of quota. The account row
+// is locked, after the cards, so transfers from any of its cards are checked
+// one at a time against totals that include each other. Moves between the account's
+// own cards are not limited.
+func checkTransferQuota(tx *gorm.DB, ts *models.Transaction, from, to *models.Card, quota *models.TransferQuota) error {
+	if quota == nil || from.AccountID == to.AccountID {
+		return nil
+	}
+
+	var account models.Account
+	result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&account, from.AccountID)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	limits := quota.Limits
+	rate, err := findRate(tx, ts.TransactionAmount.Currency, limits.DailyLimit.Currency)
+	if err != nil {
+		return err
+	}
+	amount := models.ConvertMoney(ts.TransactionAmount, rate, limits.DailyLimit.Currency)
+
+	usedToday, err := transferUsage(tx, from.AccountID, quota.StartOfDay, limits.DailyLimit.Currency)
+	if err != nil {
+		return err
+	}
+	usedThisMonth, err := transferUsage(tx, from.AccountID, quota.StartOfMonth, limits.DailyLimit.Currency)
+	if err != nil {
+		return err
+	}
+
+	status := models.NewTransferLimitsStatus(limits, usedToday, usedThisMonth)
+	if amount.Cmp(status.RemainingToday) > 0 {
+		return &TransferLimitError{Limit: limits.DailyLimit, Remaining: status.RemainingToday}
+	}
+	if amount.Cmp(status.RemainingThisMonth) > 0 {
+		return &TransferLimitError{Monthly: true, Limit: limits.MonthlyLimit, Remaining: status.RemainingThisMonth}
+	}
+
+	return nil
+}
+
+// ConvertAmount converts m into currency at the stored exchange rate.
+func (s *service) ConvertAmount(m models.Money, currency string) (models.Money, error) {
+	rate, err := findRate(s.db, m.Currency, currency)
+	if err != nil {
+		return models.Money{}, err
+	}
+
+	return models.ConvertMoney(m, rate, currency), nil
+}
+
+// GetCardCurrency returns the currency a card holds its balance in.
+func (s *service) GetCardCurrency(cardID uint) (string, error) {
+	var card models.Card
+
+	result := s.db.Select("id", "card_balance_currency").First(&card, cardID)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return "", fmt.Errorf("%w: id=%v", ErrCardNotFound, cardID)
+		}
+		return "", result.Error
+	}
+
+	return card.CardBalance.Currency, nil
+}
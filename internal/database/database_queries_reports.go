@@ -0,0 +1,81 @@
+package database
+
+import (
+	"personal_budget_app/internal/models"
+	"time"
+)
+
+// incomeLinesSQL selects every amount the account earned, in the same
+// columns as spendingLinesSQL: confirmed transfers from cards it does not own
+// to its cards, less what it refunded of them, and income entered by hand.
+// Incoming transfers carry the sender's category, not the account's, so they
+// count as uncategorized; the counterparty is the sending card's number.
+// Split income gives one row per line.
+const incomeLinesSQL = `
+	SELECT CAST(NULL AS bigint) AS category_id, fc.card_number AS counterparty,
+		t.received_amount_currency AS currency, t.received_amount_amount AS amount, t.transaction_time
+	FROM transactions t
+	JOIN cards fc ON fc.id = t.from_card_id
+	JOIN cards tc ON tc.id = t.to_card_id
+	WHERE tc.account_id = @account AND fc.account_id <> @account
+		AND t.kind = @transfer AND t.status = @confirmed AND t.deleted_at IS NULL
+		AND t.transaction_time >= @from AND t.transaction_time < @to
+	UNION ALL
+	SELECT NULL, fc.card_number, t.transaction_amount_currency, -t.transaction_amount_amount, t.transaction_time
+	FROM transactions t
+	JOIN transactions o ON o.id = t.reversal_of_id
+	JOIN cards fc ON fc.id = o.from_card_id
+	JOIN cards tc ON tc.id = o.to_card_id
+	WHERE tc.account_id = @account AND fc.account_id <> @account
+		AND t.kind = @refund AND t.deleted_at IS NULL
+		AND t.transaction_time >= @from AND t.transaction_time < @to
+	UNION ALL
+	SELECT CASE WHEN sp.id IS NULL THEN t.category_id ELSE sp.category_id END, t.counterparty_name,
+		t.transaction_amount_currency, COALESCE(sp.amount_amount, t.transaction_amount_amount), t.transaction_time
+	FROM transactions t
+	LEFT JOIN transaction_splits sp ON sp.transaction_id = t.id
+	WHERE t.account_id = @account AND t.kind = @income AND t.deleted_at IS NULL
+		AND t.transaction_time >= @from AND t.transaction_time < @to`
+
+// GetReportLines sums the account's income and expenses in [from, to) across
+// all of its cards, per category, counterparty and currency.
+func (s *service) GetReportLines(accountID uint, from, to time.Time) ([]*models.ReportLine, error) {
+	var rows []struct {
+		Kind         string
+		CategoryID   *uint
+		Counterparty string
+		Currency     string
+		Amount       int64
+	}
+
+	result := s.db.Raw(`SELECT CAST(@incomeKind AS text) AS kind, category_id, COALESCE(counterparty, '') AS counterparty, currency, SUM(amount) AS amount
+		FROM (`+incomeLinesSQL+`) income
+		GROUP BY category_id, counterparty, currency
+		UNION ALL
+		SELECT CAST(@expenseKind AS text), category_id, COALESCE(counterparty, ''), currency, SUM(amount)
+		FROM (`+spendingLinesSQL+`) spending
+		GROUP BY category_id, counterparty, currency`, reportLinesArgs(accountID, from, to)).
+		Scan(&rows)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	lines := make([]*models.ReportLine, 0, len(rows))
+	for _, row := range rows {
+		lines = append(lines, &models.ReportLine{
+			Kind:         row.Kind,
+			CategoryID:   row.CategoryID,
+			Counterparty: row.Counterparty,
+			Amount:       models.NewMoney(row.Amount, row.Currency),
+		})
+	}
+
+	return lines, nil
+}
+
+func reportLinesArgs(accountID uint, from, to time.Time) map[string]interface{} {
+	args := spendingLinesArgs(accountID, from, to)
+	args["incomeKind"] = models.ReportIncome
+	args["expenseKind"] = models.ReportExpense
+	return args
+}
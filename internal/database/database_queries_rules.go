@@ -0,0 +1,123 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"personal_budget_app/internal/models"
+)
+
+var ErrRuleNotFound = errors.New("rule not found")
+
+// ruleBatchSize is how many transactions re-applying rules loads at a time.
+const ruleBatchSize = 500
+
+func (s *service) CreateCategoryRule(rule *models.CategoryRule) error {
+	result := s.db.Create(rule)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	fmt.Printf("Successfully created rule (id=%v) for user (id=%v)\n", rule.ID, rule.AccountID)
+	return nil
+}
+
+// GetCategoryRules lists the account's rules in the order they run.
+func (s *service) GetCategoryRules(accountID uint) ([]*models.CategoryRule, error) {
+	var rules []*models.CategoryRule
+
+	result := s.db.Where("account_id = ?", accountID).Order("priority, id").Find(&rules)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return rules, nil
+}
+
+func (s *service) GetCategoryRule(id, accountID uint) (*models.CategoryRule, error) {
+	var rule models.CategoryRule
+
+	result := s.db.Where("account_id = ?", accountID).First(&rule, id)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: id=%v", ErrRuleNotFound, id)
+		}
+		return nil, result.Error
+	}
+
+	return &rule, nil
+}
+
+func (s *service) UpdateCategoryRule(rule *models.CategoryRule) error {
+	result := s.db.Save(rule)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	fmt.Printf("Successfully updated rule (id=%v)\n", rule.ID)
+	return nil
+}
+
+func (s *service) DeleteCategoryRule(id, accountID uint) error {
+	result := s.db.Where("account_id = ?", accountID).Delete(&models.CategoryRule{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("%w: id=%v", ErrRuleNotFound, id)
+	}
+
+	fmt.Printf("Successfully deleted rule (id=%v)\n", id)
+	return nil
+}
+
+// EachOutgoingTransaction calls fn with the account's outgoing transfers and
+// expenses entered by hand in batches, oldest first, with the account's tags
+// and together with the numbers of the cards the transfers went to.
+func (s *service) EachOutgoingTransaction(accountID uint, fn func(batch []*models.Transaction, cardNumbers map[uint]string) error) error {
+	var batch []*models.Transaction
+
+	result := s.db.Where("(kind = ? AND from_card_id IN (?)) OR (kind = ? AND account_id = ?)", models.TransactionKindTransfer,
+		s.db.Model(&models.Card{}).Unscoped().Select("id").Where("account_id = ?", accountID),
+		models.TransactionKindExpense, accountID).
+		FindInBatches(&batch, ruleBatchSize, func(tx *gorm.DB, _ int) error {
+			if err := loadTags(s.db, accountID, batch); err != nil {
+				return err
+			}
+
+			cardIDs := make([]uint, 0, len(batch))
+			for _, ts := range batch {
+				if ts.ToCardID != nil {
+					cardIDs = append(cardIDs, *ts.ToCardID)
+				}
+			}
+
+			var cards []*models.Card
+			if err := s.db.Unscoped().Select("id", "card_number").Where("id IN ?", cardIDs).Find(&cards).Error; err != nil {
+				return err
+			}
+
+			cardNumbers := make(map[uint]string, len(cards))
+			for _, card := range cards {
+				cardNumbers[card.ID] = card.CardNumber
+			}
+
+			return fn(batch, cardNumbers)
+		})
+
+	return result.Error
+}
+
+// ClassifyTransaction sets the category of a transaction and the tags the
+// account put on it.
+func (s *service) ClassifyTransaction(id, accountID uint, categoryID *uint, tags []string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		ts := &models.Transaction{CategoryID: categoryID}
+		ts.ID = id
+
+		if err := tx.Model(ts).Select("category_id").Updates(ts).Error; err != nil {
+			return err
+		}
+		return setTags(tx, id, accountID, tags)
+	})
+}
@@ -0,0 +1,214 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"personal_budget_app/internal/models"
+	"time"
+)
+
+var ErrScheduleNotFound = errors.New("scheduled transfer not found")
+
+func (s *service) CreateScheduledTransfer(st *models.ScheduledTransfer) error {
+	result := s.db.Create(st)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	fmt.Printf("Successfully created scheduled transfer (id=%v) for user (id=%v)\n", st.ID, st.AccountID)
+	return nil
+}
+
+func (s *service) GetScheduledTransfers(accountID uint) ([]*models.ScheduledTransfer, error) {
+	var schedules []*models.ScheduledTransfer
+
+	result := s.db.Where("account_id = ?", accountID).Order("id").Find(&schedules)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return schedules, nil
+}
+
+// GetScheduledTransfer loads a schedule of the given account with its runs,
+// newest first.
+func (s *service) GetScheduledTransfer(id, accountID uint) (*models.ScheduledTransfer, error) {
+	var st models.ScheduledTransfer
+
+	result := s.db.Preload("Runs", func(db *gorm.DB) *gorm.DB {
+		return db.Order("run_at DESC").Limit(100)
+	}).Where("account_id = ?", accountID).First(&st, id)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: id=%v", ErrScheduleNotFound, id)
+		}
+		return nil, result.Error
+	}
+
+	return &st, nil
+}
+
+// SetScheduledTransferStatus pauses, resumes or cancels a schedule of the
+// given account. Resuming picks the next run after now, so runs missed while
+// paused are skipped rather than fired all at once.
+func (s *service) SetScheduledTransferStatus(id, accountID uint, status string) (*models.ScheduledTransfer, error) {
+	var st models.ScheduledTransfer
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("account_id = ?", accountID).First(&st, id)
+		if result.Error != nil {
+			if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("%w: id=%v", ErrScheduleNotFound, id)
+			}
+			return result.Error
+		}
+
+		switch {
+		case st.Status == status:
+			return nil
+		case st.Status == models.ScheduleCancelled || st.Status == models.ScheduleCompleted:
+			return fmt.Errorf("scheduled transfer (id=%v) is %v", id, st.Status)
+		}
+
+		st.Status = status
+		if status == models.ScheduleActive {
+			next, err := st.NextRun(time.Now())
+			if err != nil {
+				return err
+			}
+			st.NextRunAt = next
+			if next == nil {
+				st.Status = models.ScheduleCompleted
+			}
+		}
+
+		return tx.Model(&st).Select("status", "next_run_at").Updates(&st).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &st, nil
+}
+
+// ClaimDueScheduledTransfers hands out the active schedules due at now and
+// moves each one on to its next run before returning, so a schedule is run
+// at most once per due date even with several servers polling. Rows another
+// poller is claiming are skipped. Each schedule comes back as a claimed run
+// of its due date, written in the same database transaction, for the caller
+// to finish with FinishScheduledTransferRun.
+func (s *service) ClaimDueScheduledTransfers(now time.Time, limit int) ([]*models.ScheduledTransferRun, error) {
+	var runs []*models.ScheduledTransferRun
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var due []*models.ScheduledTransfer
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_run_at <= ?", models.ScheduleActive, now).
+			Order("next_run_at").Limit(limit).Find(&due)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		for _, st := range due {
+			next, err := st.NextRun(now)
+			if err != nil {
+				return err
+			}
+
+			updates := map[string]interface{}{"next_run_at": next, "last_run_at": now}
+			if next == nil {
+				updates["status"] = models.ScheduleCompleted
+			}
+
+			if err := tx.Model(&models.ScheduledTransfer{}).Where("id = ?", st.ID).Updates(updates).Error; err != nil {
+				return err
+			}
+
+			run := &models.ScheduledTransferRun{
+				ScheduleID:   st.ID,
+				ScheduledFor: *st.NextRunAt,
+				RunAt:        now,
+				Status:       models.RunClaimed,
+			}
+			if err := tx.Create(run).Error; err != nil {
+				return err
+			}
+			run.Schedule = st
+
+			runs = append(runs, run)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return runs, nil
+}
+
+// ClaimInterruptedScheduledTransferRuns hands out again the runs claimed
+// before claimedBefore and never finished, as when the server stopped midway.
+// A run whose transfer was made after all is only marked succeeded, and one
+// whose schedule was cancelled or paused since is marked failed; the rest
+// come back claimed anew at now.
+func (s *service) ClaimInterruptedScheduledTransferRuns(claimedBefore, now time.Time, limit int) ([]*models.ScheduledTransferRun, error) {
+	var runs []*models.ScheduledTransferRun
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var stale []*models.ScheduledTransferRun
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND run_at < ?", models.RunClaimed, claimedBefore).
+			Order("run_at").Limit(limit).Find(&stale)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		for _, run := range stale {
+			var made models.Transaction
+			result := tx.Unscoped().Select("id").Where("scheduled_run_id = ?", run.ID).Limit(1).Find(&made)
+			if result.Error != nil {
+				return result.Error
+			}
+
+			var st models.ScheduledTransfer
+			result = tx.Unscoped().Limit(1).Find(&st, run.ScheduleID)
+			if result.Error != nil {
+				return result.Error
+			}
+
+			updates := map[string]interface{}{"run_at": now}
+			switch {
+			case made.ID != 0:
+				updates["status"] = models.RunSucceeded
+				updates["transaction_id"] = made.ID
+			case result.RowsAffected == 0 || st.DeletedAt.Valid ||
+				(st.Status != models.ScheduleActive && st.Status != models.ScheduleCompleted):
+				updates["status"] = models.RunFailed
+				updates["error"] = "interrupted, and the schedule is no longer active"
+			default:
+				run.RunAt = now
+				run.Schedule = &st
+				runs = append(runs, run)
+			}
+
+			if err := tx.Model(&models.ScheduledTransferRun{}).Where("id = ?", run.ID).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return runs, nil
+}
+
+// FinishScheduledTransferRun records how a claimed run went.
+func (s *service) FinishScheduledTransferRun(run *models.ScheduledTransferRun) error {
+	return s.db.Model(run).Select("status", "error", "transaction_id").Updates(run).Error
+}
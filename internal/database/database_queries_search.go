@@ -0,0 +1,165 @@
+package database
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"personal_budget_app/internal/models"
+	"strings"
+	"unicode"
+)
+
+// minCardDigits is how many digits of a card number a search needs before
+// card numbers are matched at all.
+const minCardDigits = 4
+
+// ownedTransactions narrows db to the transactions touching one of the
+// account's cards and the entries it made by hand.
+func ownedTransactions(db *gorm.DB, accountID uint) *gorm.DB {
+	return db.Where(`(transactions.from_card_id IN (SELECT id FROM cards WHERE account_id = ?)
+		OR transactions.to_card_id IN (SELECT id FROM cards WHERE account_id = ?)
+		OR transactions.account_id = ?)`, accountID, accountID, accountID)
+}
+
+// SearchTransactions looks through all of the account's transactions. Every
+// word of the query has to start a word of the description, counterparty
+// name or external reference, or else every word has to start one of the
+// account's own tags; a query made only of digits also matches either card
+// number. Best matches come first, then the newest. Each way of matching
+// has an index of its own: the GIN index on search_vector and the one on
+// transaction_tags (account_id, tag).
+func (s *service) SearchTransactions(accountID uint, search *models.TransactionSearch) ([]*models.Transaction, error) {
+	var transactions []*models.Transaction
+
+	words, digits := searchTerms(search.Query)
+	if len(words) == 0 {
+		return transactions, nil
+	}
+
+	terms := make([]string, 0, len(words))
+	for _, word := range words {
+		terms = append(terms, "'"+word+"':*")
+	}
+	tsquery := strings.Join(terms, " & ")
+
+	// every word starts one of the account's tags; not correlated with the
+	// row, so under the OR each subquery still runs once, off the index
+	args := []interface{}{tsquery}
+	tagged := make([]string, 0, len(words))
+	for _, word := range words {
+		tagged = append(tagged, "transactions.id IN (SELECT transaction_id FROM transaction_tags WHERE account_id = ? AND tag LIKE ?)")
+		args = append(args, accountID, word+"%")
+	}
+
+	matches := "transactions.search_vector @@ to_tsquery('simple', ?) OR (" + strings.Join(tagged, " AND ") + ")"
+	if len(digits) >= minCardDigits {
+		pattern := "%" + digits + "%"
+		matches += ` OR transactions.from_card_id IN (SELECT id FROM cards WHERE regexp_replace(card_number, '\D', '', 'g') LIKE ?)
+			OR transactions.to_card_id IN (SELECT id FROM cards WHERE regexp_replace(card_number, '\D', '', 'g') LIKE ?)`
+		args = append(args, pattern, pattern)
+	}
+
+	// ranked after filtering, so ts_rank only runs on the rows that match
+	result := ownedTransactions(s.db.Preload("Refunds").Preload("Splits"), accountID).
+		Where("("+matches+")", args...).
+		Clauses(clause.OrderBy{Expression: clause.Expr{
+			SQL:  "ts_rank(transactions.search_vector, to_tsquery('simple', ?)) DESC, transactions.transaction_time DESC, transactions.id DESC",
+			Vars: []interface{}{tsquery},
+		}}).
+		Limit(search.Limit).
+		Find(&transactions)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if err := loadTags(s.db, accountID, transactions); err != nil {
+		return nil, err
+	}
+	return transactions, nil
+}
+
+// searchTerms splits a query into words, lower-cased and stripped of
+// anything but letters and digits. A query of nothing but digits, spaces
+// and dashes is also returned as digits to match card numbers.
+func searchTerms(query string) ([]string, string) {
+	words := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	var digits strings.Builder
+	for _, r := range query {
+		switch {
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		case r == ' ' || r == '-':
+		default:
+			return words, ""
+		}
+	}
+
+	return words, digits.String()
+}
+
+// GetTags lists the tags the account put on its transactions, most used
+// first.
+func (s *service) GetTags(accountID uint) ([]*models.TagCount, error) {
+	tags := []*models.TagCount{}
+
+	result := s.db.Table("transaction_tags").
+		Select("transaction_tags.tag, COUNT(*) AS count").
+		Joins("JOIN transactions ON transactions.id = transaction_tags.transaction_id AND transactions.deleted_at IS NULL").
+		Where("transaction_tags.account_id = ?", accountID).
+		Group("transaction_tags.tag").
+		Order("count DESC, transaction_tags.tag").
+		Scan(&tags)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return tags, nil
+}
+
+// LoadTags fills in the account's own tags on transactions.
+func (s *service) LoadTags(accountID uint, transactions ...*models.Transaction) error {
+	return loadTags(s.db, accountID, transactions)
+}
+
+func loadTags(db *gorm.DB, accountID uint, transactions []*models.Transaction) error {
+	if len(transactions) == 0 {
+		return nil
+	}
+
+	byID := make(map[uint]*models.Transaction, len(transactions))
+	ids := make([]uint, 0, len(transactions))
+	for _, ts := range transactions {
+		ts.Tags = nil
+		byID[ts.ID] = ts
+		ids = append(ids, ts.ID)
+	}
+
+	var rows []*models.TransactionTag
+	result := db.Where("account_id = ? AND transaction_id IN ?", accountID, ids).
+		Order("transaction_id, tag").
+		Find(&rows)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	for _, row := range rows {
+		ts := byID[row.TransactionID]
+		ts.Tags = append(ts.Tags, row.Tag)
+	}
+	return nil
+}
+
+// setTags replaces the account's tags on a transaction with tags.
+func setTags(tx *gorm.DB, transactionID, accountID uint, tags []string) error {
+	if err := tx.Where("transaction_id = ? AND account_id = ?", transactionID, accountID).
+		Delete(&models.TransactionTag{}).Error; err != nil {
+		return err
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+
+	return tx.Create(models.NewTransactionTags(transactionID, accountID, tags)).Error
+}
@@ -79,3 +79,28 @@ func (s *service) CheckCurrentPassword(accountID uint, currentPassword string) (
 	result := functionalities.CheckPassword(account.Password, currentPassword)
 	return result, nil
 }
+
+func (s *service) SetTimezone(accountID uint, timezone string) error {
+	result := s.db.Model(&models.Account{}).Where("id = ?", accountID).Update("timezone", timezone)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	fmt.Printf("Successfully set timezone %v for user (id=%v)\n", timezone, accountID)
+	return nil
+}
+
+// GetTimezone returns the account's IANA timezone name.
+func (s *service) GetTimezone(accountID uint) (string, error) {
+	var account models.Account
+
+	result := s.db.Select("timezone").First(&account, accountID)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return "", fmt.Errorf("account with id=%v not found", accountID)
+		}
+		return "", result.Error
+	}
+
+	return account.Timezone, nil
+}
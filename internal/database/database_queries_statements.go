@@ -0,0 +1,86 @@
+package database
+
+import (
+	"fmt"
+	"personal_budget_app/internal/models"
+	"time"
+)
+
+// GetCardBalanceAt is the card's balance just before at, according to its
+// ledger.
+func (s *service) GetCardBalanceAt(cardID uint, at time.Time) (models.Money, error) {
+	var row struct {
+		Currency string
+		Balance  int64
+	}
+
+	result := s.db.Raw(`SELECT c.card_balance_currency AS currency, COALESCE(SUM(`+ledgerNet+`), 0) AS balance
+		FROM cards c
+		LEFT JOIN ledger_entries e ON e.card_id = c.id AND e.effective_at < ?
+		WHERE c.id = ?
+		GROUP BY c.id`, at, cardID).Scan(&row)
+	if result.Error != nil {
+		return models.Money{}, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return models.Money{}, fmt.Errorf("%w: id=%v", ErrCardNotFound, cardID)
+	}
+
+	return models.NewMoney(row.Balance, row.Currency), nil
+}
+
+// GetStatementLines lists the ledger entries of the card in [from, to),
+// oldest first, with the description and counterparty of the transaction
+// behind each. Transfers name the other card; entries made by hand the
+// counterparty typed in. Deleted entries are still listed, as the ledger
+// keeps them and their reversal.
+func (s *service) GetStatementLines(cardID uint, from, to time.Time) ([]*models.StatementLine, error) {
+	var rows []struct {
+		At            time.Time
+		Kind          string
+		TransactionID *uint
+		Description   string
+		Counterparty  string
+		Deleted       bool
+		Currency      string
+		Amount        int64
+	}
+
+	result := s.db.Raw(`SELECT e.effective_at AS at, e.kind, e.transaction_id,
+			COALESCE(t.description, '') AS description,
+			COALESCE(CASE WHEN t.kind IN (@transfer, @refund)
+				THEN CASE WHEN e.direction = 'credit' THEN fc.card_number ELSE tc.card_number END
+				ELSE t.counterparty_name END, '') AS counterparty,
+			t.deleted_at IS NOT NULL AS deleted,
+			e.amount_currency AS currency, `+ledgerNet+` AS amount
+		FROM ledger_entries e
+		LEFT JOIN transactions t ON t.id = e.transaction_id
+		LEFT JOIN cards fc ON fc.id = t.from_card_id
+		LEFT JOIN cards tc ON tc.id = t.to_card_id
+		WHERE e.card_id = @card AND e.effective_at >= @from AND e.effective_at < @to
+		ORDER BY e.effective_at, e.id`, map[string]interface{}{
+		"card":     cardID,
+		"from":     from,
+		"to":       to,
+		"transfer": models.TransactionKindTransfer,
+		"refund":   models.TransactionKindRefund,
+	}).Scan(&rows)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	lines := make([]*models.StatementLine, 0, len(rows))
+	for _, row := range rows {
+		lines = append(lines, &models.StatementLine{
+			At:            row.At,
+			Kind:          row.Kind,
+			TransactionID: row.TransactionID,
+			Description:   row.Description,
+			Counterparty:  row.Counterparty,
+			Deleted:       row.Deleted,
+			Amount:        models.NewMoney(row.Amount, row.Currency),
+		})
+	}
+
+	return lines, nil
+}
@@ -4,101 +4,445 @@ import (
 	"errors"
 	"fmt"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"math/big"
 	"personal_budget_app/internal/models"
+	"strings"
+	"time"
 )
 
-func (s *service) AddTransaction(ts *models.Transaction) error {
-	var card models.Card
-	result := s.db.Where("id = ?", ts.ToCardID).First(&card)
+var (
+	ErrCardNotFound      = errors.New("card not found")
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	ErrCurrencyMismatch  = errors.New("currency mismatch")
+
+	ErrTransactionNotFound   = errors.New("transaction not found")
+	ErrNotRefundable         = errors.New("transaction cannot be refunded")
+	ErrRefundExceedsOriginal = errors.New("refund exceeds the amount left to refund")
+	ErrInvalidRefundAmount   = errors.New("refund amount must be positive")
+	ErrNotPending            = errors.New("transaction is not pending")
+	ErrTransferExpired       = errors.New("confirmation window has passed")
+)
 
-	if result.Error != nil {
-		fmt.Printf("Error: Destination card (id=%v) does not exist: %v\n", ts.ToCardID, result.Error)
-		return result.Error
+// TransferFunds records ts and moves its amount from the sender card to the
+// receiver card inside a single database transaction. Both cards are locked
+// with SELECT ... FOR UPDATE before the balance check, so concurrent
+// transfers cannot overdraw a card and a failure halfway leaves nothing behind.
+// When the cards hold different currencies the receiver is credited the
+// amount converted at the stored exchange rate, and ts keeps both amounts
+// and the rate used. The matching ledger entries are written in the same
+// database transaction, as are the sender's tags. A transfer to someone
+// else's card is checked against quota there too; nil skips the check.
+func (s *service) TransferFunds(ts *models.Transaction, quota *models.TransferQuota) error {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		from, err := prepareTransfer(tx, ts, quota)
+		if err != nil {
+			return err
+		}
+
+		if err := applyTransfer(tx, ts); err != nil {
+			return err
+		}
+
+		return setTags(tx, ts.ID, from.AccountID, ts.Tags)
+	})
+	if err != nil {
+		fmt.Printf("Error transferring funds [%v --> %v]: %v\n", *ts.FromCardID, *ts.ToCardID, err)
+		return err
 	}
 
+	fmt.Printf("Successfully added transaction (id=%v): [%v --> %v];\n", ts.ID, *ts.FromCardID, *ts.ToCardID)
 
-	result = s.db.Create(ts)
-	if result.Error != nil {
-		fmt.Printf("Error adding transaction for card (id=%v): %v\n", ts.FromCardID, result.Error)
-		return result.Error
+	return nil
+}
+
+// HoldFunds records ts as a pending transfer and reserves its amount on the
+// sender card until expiresAt. Nothing moves until ConfirmTransfer; the
+// reserved funds cannot be spent by other transfers in the meantime, and
+// count towards the account's limits, checked against quota as in TransferFunds.
+func (s *service) HoldFunds(ts *models.Transaction, expiresAt time.Time, quota *models.TransferQuota) error {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		from, err := prepareTransfer(tx, ts, quota)
+		if err != nil {
+			return err
+		}
+
+		ts.Status = models.TransactionPending
+		ts.ExpiresAt = &expiresAt
+		if err := tx.Create(ts).Error; err != nil {
+			return err
+		}
+		if err := setTags(tx, ts.ID, from.AccountID, ts.Tags); err != nil {
+			return err
+		}
+
+		return tx.Model(&models.Card{}).Where("id = ?", *ts.FromCardID).
+			UpdateColumn("reserved_balance_amount", gorm.Expr("reserved_balance_amount + ?", ts.TransactionAmount.Amount)).Error
+	})
+	if err != nil {
+		fmt.Printf("Error holding funds [%v --> %v]: %v\n", *ts.FromCardID, *ts.ToCardID, err)
+		return err
 	}
 
-	fmt.Printf("Successfully added transaction (id=%v): [%v --> %v];\n", ts.ID, ts.FromCardID, ts.ToCardID)
+	fmt.Printf("Successfully added pending transaction (id=%v): [%v --> %v];\n", ts.ID, *ts.FromCardID, *ts.ToCardID)
 
 	return nil
 }
 
-func (s *service) FindCardIDByCardNumber(cardNumber string) (uint, error) {
-	var card models.Card
-	result := s.db.Where("card_number = ?", cardNumber).First(&card)
-	if result.Error != nil {
-		return 0, result.Error
+// ConfirmTransfer settles a pending transfer: the hold is released and the
+// money moves at the amounts fixed when it was created. A transfer whose
+// window has passed is expired instead and ErrTransferExpired is returned.
+func (s *service) ConfirmTransfer(id uint) (*models.Transaction, error) {
+	var ts *models.Transaction
+	expired := false
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if ts, err = lockPendingTransfer(tx, id); err != nil {
+			return err
+		}
+
+		if _, err := lockCards(tx, *ts.FromCardID, *ts.ToCardID); err != nil {
+			return err
+		}
+
+		if !ts.ExpiresAt.After(time.Now()) {
+			expired = true
+			return releaseHold(tx, ts, models.TransactionExpired)
+		}
+
+		if err := releaseHold(tx, ts, models.TransactionConfirmed); err != nil {
+			return err
+		}
+
+		// the money moves now, so that is when the transfer happened
+		ts.TransactionTime = time.Now()
+		if err := tx.Model(ts).UpdateColumn("transaction_time", ts.TransactionTime).Error; err != nil {
+			return err
+		}
+
+		return moveFunds(tx, ts)
+	})
+	if err != nil {
+		return nil, err
 	}
-	return card.ID, nil
+	if expired {
+		return nil, fmt.Errorf("%w: id=%v", ErrTransferExpired, id)
+	}
+
+	fmt.Printf("Successfully confirmed transaction (id=%v): [%v --> %v];\n", ts.ID, *ts.FromCardID, *ts.ToCardID)
+
+	return ts, nil
 }
 
+// CancelTransfer drops a pending transfer and releases its hold.
+func (s *service) CancelTransfer(id uint) (*models.Transaction, error) {
+	var ts *models.Transaction
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if ts, err = lockPendingTransfer(tx, id); err != nil {
+			return err
+		}
 
+		return releaseHold(tx, ts, models.TransactionCancelled)
+	})
+	if err != nil {
+		return nil, err
+	}
 
-func (s *service) CheckCardBalance(cardID uint, amount float64) (bool, error) {
-	var card models.Card
+	return ts, nil
+}
+
+// ExpirePendingTransfers releases the holds of pending transfers whose
+// window closed before now and returns how many it expired. Rows being
+// confirmed at the same moment are skipped and picked up next time. The
+// sender cards are locked in id order before their reserved balances change,
+// as lockCards does for every other transfer, so this cannot deadlock
+// against them.
+func (s *service) ExpirePendingTransfers(now time.Time) (int, error) {
+	var due []*models.Transaction
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND expires_at <= ?", models.TransactionPending, now).
+			Find(&due)
+		if result.Error != nil {
+			return result.Error
+		}
+		if len(due) == 0 {
+			return nil
+		}
 
-	// Retrieve the card by ID
-	result := s.db.First(&card, "id = ?", cardID)
+		cardIDs := make([]uint, 0, len(due))
+		for _, ts := range due {
+			cardIDs = append(cardIDs, *ts.FromCardID)
+		}
+		// not lockCards: a card deleted since must not hold up the others
+		var cards []*models.Card
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").
+			Where("id IN ?", cardIDs).Order("id").Find(&cards).Error; err != nil {
+			return err
+		}
+
+		for _, ts := range due {
+			if err := releaseHold(tx, ts, models.TransactionExpired); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if len(due) > 0 {
+		fmt.Printf("Expired %v pending transactions\n", len(due))
+	}
+
+	return len(due), nil
+}
+
+func lockPendingTransfer(tx *gorm.DB, id uint) (*models.Transaction, error) {
+	var ts models.Transaction
+
+	result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&ts, id)
 	if result.Error != nil {
-		fmt.Printf("Error retrieving card (id=%v): %v\n", cardID, result.Error)
-		return false, result.Error
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: id=%v", ErrTransactionNotFound, id)
+		}
+		return nil, result.Error
 	}
 
-	// Check if the card has enough balance
-	if card.CardBalance < amount {
-		return false, nil // Not enough balance, but no error occurred
+	if ts.Status != models.TransactionPending {
+		return nil, fmt.Errorf("%w: transaction (id=%v) is %v", ErrNotPending, id, ts.Status)
 	}
 
-	return true, nil // Sufficient balance
+	return &ts, nil
 }
 
-func (s *service) SenderUpdateBalance(cardID uint, amount float64) error {
-	var card models.Card
+// releaseHold gives the reserved amount of a pending transfer back to the
+// sender card and moves the transfer to its final status.
+func releaseHold(tx *gorm.DB, ts *models.Transaction, status string) error {
+	if err := tx.Model(&models.Card{}).Where("id = ?", *ts.FromCardID).
+		UpdateColumn("reserved_balance_amount", gorm.Expr("reserved_balance_amount - ?", ts.TransactionAmount.Amount)).Error; err != nil {
+		return err
+	}
+
+	ts.Status = status
+	return tx.Model(ts).UpdateColumn("status", status).Error
+}
+
+// prepareTransfer locks both cards of ts, fills in the received amount and
+// rate, and checks the sender can afford it from its available balance and
+// within quota. It returns the sender's card.
+func prepareTransfer(tx *gorm.DB, ts *models.Transaction, quota *models.TransferQuota) (*models.Card, error) {
+	cards, err := lockCards(tx, *ts.FromCardID, *ts.ToCardID)
+	if err != nil {
+		return nil, err
+	}
+
+	from, to := cards[*ts.FromCardID], cards[*ts.ToCardID]
+
+	// an amount without a currency is taken in the sender card's currency
+	if ts.TransactionAmount.Currency == "" {
+		ts.TransactionAmount.Currency = from.CardBalance.Currency
+	}
+	if !from.CardBalance.SameCurrency(ts.TransactionAmount) {
+		return nil, fmt.Errorf("%w: amount is in %v but the card holds %v",
+			ErrCurrencyMismatch, ts.TransactionAmount.Currency, from.CardBalance.Currency)
+	}
+
+	rate, err := findRate(tx, from.CardBalance.Currency, to.CardBalance.Currency)
+	if err != nil {
+		return nil, err
+	}
+	ts.ReceivedAmount = models.ConvertMoney(ts.TransactionAmount, rate, to.CardBalance.Currency)
+	ts.ExchangeRate = models.FormatRate(rate)
+
+	if from.Available().Cmp(ts.TransactionAmount) < 0 {
+		return nil, ErrInsufficientFunds
+	}
+
+	return from, checkTransferQuota(tx, ts, from, to, quota)
+}
+
+// RefundTransaction sends money back along a confirmed transfer as a new
+// refund transaction linked to it. amount is in the original sender's
+// currency; nil refunds whatever is left. The original row stays locked while
+// earlier refunds are summed, so concurrent refunds can never return more
+// than was sent. The receiver gives back the same share of what it got, at
+// the original rate, and the last refund takes exactly the remainder so
+// rounding never leaves a tiyn behind.
+func (s *service) RefundTransaction(originalID uint, amount *models.Money) (*models.Transaction, error) {
+	var refund *models.Transaction
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var original models.Transaction
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&original, originalID)
+		if result.Error != nil {
+			if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("%w: id=%v", ErrTransactionNotFound, originalID)
+			}
+			return result.Error
+		}
+
+		if original.Kind != models.TransactionKindTransfer {
+			return fmt.Errorf("%w: transaction (id=%v) is a %v", ErrNotRefundable, originalID, original.Kind)
+		}
+		if original.Status != models.TransactionConfirmed {
+			return fmt.Errorf("%w: transaction (id=%v) is %v", ErrNotRefundable, originalID, original.Status)
+		}
+
+		// what went back so far, on both sides of the original
+		var refunded struct {
+			Returned int64
+			Taken    int64
+		}
+		result = tx.Model(&models.Transaction{}).
+			Select("COALESCE(SUM(received_amount_amount), 0) AS returned, COALESCE(SUM(transaction_amount_amount), 0) AS taken").
+			Where("reversal_of_id = ?", originalID).Scan(&refunded)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		remaining := original.TransactionAmount.Sub(models.NewMoney(refunded.Returned, ""))
+		returned := remaining
+		if amount != nil {
+			returned = *amount
+			if returned.Currency == "" {
+				returned.Currency = original.TransactionAmount.Currency
+			}
+		}
+
+		if !returned.SameCurrency(original.TransactionAmount) {
+			return fmt.Errorf("%w: refund is in %v but the transfer was in %v",
+				ErrCurrencyMismatch, returned.Currency, original.TransactionAmount.Currency)
+		}
+		if returned.Amount <= 0 {
+			if remaining.Amount <= 0 {
+				return fmt.Errorf("%w: transaction (id=%v) is already fully refunded", ErrRefundExceedsOriginal, originalID)
+			}
+			return ErrInvalidRefundAmount
+		}
+		if returned.Cmp(remaining) > 0 {
+			return fmt.Errorf("%w: %v left of %v", ErrRefundExceedsOriginal, remaining, original.TransactionAmount)
+		}
+
+		takenLeft := original.ReceivedAmount.Sub(models.NewMoney(refunded.Taken, ""))
+		taken := takenLeft
+		if returned.Cmp(remaining) < 0 {
+			share := new(big.Rat).SetFrac64(original.ReceivedAmount.Amount, original.TransactionAmount.Amount)
+			taken = models.ConvertMoney(returned, share, original.ReceivedAmount.Currency)
+			if taken.Cmp(takenLeft) > 0 {
+				taken = takenLeft
+			}
+		}
+
+		cards, err := lockCards(tx, *original.ToCardID, *original.FromCardID)
+		if err != nil {
+			return err
+		}
+		if cards[*original.ToCardID].Available().Cmp(taken) < 0 {
+			return ErrInsufficientFunds
+		}
+
+		refund = models.NewTransaction(taken, *original.ToCardID, *original.FromCardID)
+		refund.Kind = models.TransactionKindRefund
+		refund.ReversalOfID = &original.ID
+		refund.ReceivedAmount = returned
+		refund.ExchangeRate = "1"
+		if taken.Amount != 0 {
+			refund.ExchangeRate = models.FormatRate(new(big.Rat).SetFrac64(returned.Amount, taken.Amount))
+		}
+
+		return applyTransfer(tx, refund)
+	})
+	if err != nil {
+		fmt.Printf("Error refunding transaction (id=%v): %v\n", originalID, err)
+		return nil, err
+	}
+
+	fmt.Printf("Successfully refunded transaction (id=%v) with transaction (id=%v)\n", originalID, refund.ID)
+
+	return refund, nil
+}
+
+func (s *service) GetTransaction(id uint) (*models.Transaction, error) {
+	var ts models.Transaction
 
-	// Retrieve the card by ID
-	result := s.db.First(&card, "id = ?", cardID)
+	result := s.db.Preload("Refunds").Preload("Splits").First(&ts, id)
 	if result.Error != nil {
-		fmt.Printf("Error retrieving card (id=%v): %v\n", cardID, result.Error)
-		return result.Error
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: id=%v", ErrTransactionNotFound, id)
+		}
+		return nil, result.Error
 	}
 
-	card.CardBalance -= amount
+	return &ts, nil
+}
 
-	updateResult := s.db.Save(&card)
-	if updateResult.Error != nil {
-		fmt.Printf("Error updating card balance (id=%v): %v\n", cardID, updateResult.Error)
-		return updateResult.Error
+// applyTransfer records ts, moves the money between its cards and writes the
+// ledger entries. The caller holds the card locks and has already checked
+// the sender's balance and filled in both amounts.
+func applyTransfer(tx *gorm.DB, ts *models.Transaction) error {
+	if err := tx.Create(ts).Error; err != nil {
+		return err
 	}
 
-	fmt.Printf("Successfully updated card balance (id=%v)\n", cardID)
-	return nil
+	return moveFunds(tx, ts)
 }
 
-func (s *service) ReceiverUpdateBalance(cardID uint, amount float64) error {
-	var card models.Card
+// moveFunds changes both card balances for a recorded transfer and writes
+// its ledger entries.
+func moveFunds(tx *gorm.DB, ts *models.Transaction) error {
+	// sender's card (-)
+	if err := tx.Model(&models.Card{}).Where("id = ?", *ts.FromCardID).
+		UpdateColumn("card_balance_amount", gorm.Expr("card_balance_amount - ?", ts.TransactionAmount.Amount)).Error; err != nil {
+		return err
+	}
+
+	// receiver's card (+)
+	if err := tx.Model(&models.Card{}).Where("id = ?", *ts.ToCardID).
+		UpdateColumn("card_balance_amount", gorm.Expr("card_balance_amount + ?", ts.ReceivedAmount.Amount)).Error; err != nil {
+		return err
+	}
+
+	return tx.Create(models.NewTransferEntries(ts)).Error
+}
+
+// lockCards loads the given cards with a row lock held until tx ends. Rows
+// are locked in id order so two transfers between the same pair of cards in
+// opposite directions cannot deadlock.
+func lockCards(tx *gorm.DB, ids ...uint) (map[uint]*models.Card, error) {
+	var cards []*models.Card
 
-	// Retrieve the card by ID
-	result := s.db.First(&card, "id = ?", cardID)
+	result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id IN ?", ids).Order("id").Find(&cards)
 	if result.Error != nil {
-		fmt.Printf("Error retrieving card (id=%v): %v\n", cardID, result.Error)
-		return result.Error
+		return nil, result.Error
 	}
 
-	card.CardBalance += amount
+	byID := make(map[uint]*models.Card, len(cards))
+	for _, card := range cards {
+		byID[card.ID] = card
+	}
 
-	updateResult := s.db.Save(&card)
-	if updateResult.Error != nil {
-		fmt.Printf("Error updating card balance (id=%v): %v\n", cardID, updateResult.Error)
-		return updateResult.Error
+	for _, id := range ids {
+		if _, ok := byID[id]; !ok {
+			return nil, fmt.Errorf("%w: id=%v", ErrCardNotFound, id)
+		}
 	}
 
-	fmt.Printf("Successfully updated card balance (id=%v)\n", cardID)
-	return nil
+	return byID, nil
+}
+
+func (s *service) FindCardIDByCardNumber(cardNumber string) (uint, error) {
+	var card models.Card
+	result := s.db.Where("card_number = ?", cardNumber).First(&card)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	return card.ID, nil
 }
 
 func (s *service) CheckCardBelongsToUser(cardId, accountId uint) (bool, error) {
@@ -122,32 +466,339 @@ func (s *service) CheckCardBelongsToUser(cardId, accountId uint) (bool, error) {
 
 
 // get
-func (s *service) GetIncomingTransactions(cardId uint) ([]*models.Transaction, error) {
-	var transactions []*models.Transaction
+func (s *service) GetIncomingTransactions(cardId uint, filter *models.TransactionFilter, page *models.HistoryPageRequest) (*models.HistoryPage, error) {
 	// Query for transactions where the card is the recipient
-	result := s.db.Where("to_card_id = ?", cardId).Find(&transactions)
-	if result.Error != nil {
-		return nil, result.Error
-	}
-	return transactions, nil
+	return s.cardTransactions(cardId, "incoming", filter, page)
 }
 
-func (s *service) GetOutgoingTransactions(cardId uint) ([]*models.Transaction, error) {
-	var transactions []*models.Transaction
+func (s *service) GetOutgoingTransactions(cardId uint, filter *models.TransactionFilter, page *models.HistoryPageRequest) (*models.HistoryPage, error) {
 	// Query for transactions where the card is the sender
-	result := s.db.Where("from_card_id = ?", cardId).Find(&transactions)
-	if result.Error != nil {
-		return nil, result.Error
+	return s.cardTransactions(cardId, "outgoing", filter, page)
+}
+
+func (s *service) GetAllTransactions(cardId uint, filter *models.TransactionFilter, page *models.HistoryPageRequest) (*models.HistoryPage, error) {
+	// Query for all transactions related to the card, either as sender or recipient
+	return s.cardTransactions(cardId, "", filter, page)
+}
+
+// cardTransactions reads a page of the card's history, with the tags of the
+// account holding the card.
+func (s *service) cardTransactions(cardID uint, transactionType string, filter *models.TransactionFilter, page *models.HistoryPageRequest) (*models.HistoryPage, error) {
+	card, err := s.FindCard(cardID)
+	if err != nil {
+		return nil, err
+	}
+
+	branches, side := cardScope(s.db, cardID, transactionType)
+	history, err := cardHistory(s.db, branches, side, card.AccountID, filter, page)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.LoadTags(card.AccountID, history.Transactions...); err != nil {
+		return nil, err
+	}
+
+	return history, nil
+}
+
+// historySide is the amount of a transaction as one card, or one account,
+// saw it: what left it, or what arrived on it. expr reads it in SQL and of
+// from a loaded row.
+type historySide struct {
+	expr clause.Expr
+	of   func(*models.Transaction) int64
+}
+
+// historyBranch is one part of a history read on its own: the rows db
+// selects, with the amounts side gives them.
+type historyBranch struct {
+	db   *gorm.DB
+	side historySide
+}
+
+// cardScope narrows db to what the card received ("incoming"), sent
+// ("outgoing") or both (anything else). Each side has an index of its own,
+// so both are read as two branches, rather than with an OR neither index
+// can serve; the side returned orders them together.
+func cardScope(db *gorm.DB, cardID uint, transactionType string) ([]historyBranch, historySide) {
+	outgoing := historyBranch{
+		db: db.Model(&models.Transaction{}).Where("from_card_id = ?", cardID),
+		side: historySide{
+			expr: clause.Expr{SQL: "transaction_amount_amount"},
+			of:   func(ts *models.Transaction) int64 { return ts.TransactionAmount.Amount },
+		},
+	}
+	incoming := historyBranch{
+		db: db.Model(&models.Transaction{}).Where("to_card_id = ?", cardID),
+		side: historySide{
+			expr: clause.Expr{SQL: "received_amount_amount"},
+			of:   func(ts *models.Transaction) int64 { return ts.ReceivedAmount.Amount },
+		},
+	}
+
+	switch transactionType {
+	case "incoming":
+		return []historyBranch{incoming}, incoming.side
+	case "outgoing":
+		return []historyBranch{outgoing}, outgoing.side
+	}
+
+	// a transfer from the card to itself is read once, as outgoing
+	incoming.db = incoming.db.Where("from_card_id IS DISTINCT FROM ?", cardID)
+
+	return []historyBranch{outgoing, incoming}, historySide{
+		expr: clause.Expr{SQL: "CASE WHEN from_card_id = ? THEN transaction_amount_amount ELSE received_amount_amount END", Vars: []interface{}{cardID}},
+		of: func(ts *models.Transaction) int64 {
+			if ts.FromCardID != nil && *ts.FromCardID == cardID {
+				return ts.TransactionAmount.Amount
+			}
+			return ts.ReceivedAmount.Amount
+		},
+	}
+}
+
+// accountScope narrows db to the account's transactions, the side of them
+// going by whether one of owned, the account's cards, sent the money.
+func accountScope(db *gorm.DB, accountID uint, owned map[uint]bool) ([]historyBranch, historySide) {
+	side := historySide{
+		expr: clause.Expr{SQL: "CASE WHEN from_card_id IN (SELECT id FROM cards WHERE account_id = ?) THEN transaction_amount_amount ELSE received_amount_amount END", Vars: []interface{}{accountID}},
+		of: func(ts *models.Transaction) int64 {
+			if ts.FromCardID != nil && owned[*ts.FromCardID] {
+				return ts.TransactionAmount.Amount
+			}
+			return ts.ReceivedAmount.Amount
+		},
+	}
+
+	return []historyBranch{{db: ownedTransactions(db.Model(&models.Transaction{}), accountID), side: side}}, side
+}
+
+// unionBranches reads branches, each narrowed by narrow, as one table named
+// transactions. A single branch is read as it is.
+func unionBranches(db *gorm.DB, branches []historyBranch, narrow func(historyBranch) *gorm.DB) *gorm.DB {
+	if len(branches) == 1 {
+		return narrow(branches[0])
+	}
+
+	parts := make([]string, len(branches))
+	vars := make([]interface{}, len(branches))
+	for i, branch := range branches {
+		parts[i] = "(?)"
+		vars[i] = narrow(branch)
+	}
+	return db.Table("("+strings.Join(parts, " UNION ALL ")+") AS transactions", vars...)
+}
+
+// ownedCardIDs is the set of the account's card ids.
+func (s *service) ownedCardIDs(accountID uint) (map[uint]bool, error) {
+	var cardIDs []uint
+	if err := s.db.Model(&models.Card{}).Where("account_id = ?", accountID).Pluck("id", &cardIDs).Error; err != nil {
+		return nil, err
+	}
+
+	owned := make(map[uint]bool, len(cardIDs))
+	for _, id := range cardIDs {
+		owned[id] = true
+	}
+	return owned, nil
+}
+
+// applyAmountFilter applies the amount bounds of filter to side.
+func applyAmountFilter(db *gorm.DB, side historySide, filter *models.TransactionFilter) *gorm.DB {
+	if filter != nil && filter.MinAmount != nil {
+		db = db.Where("? >= ?", side.expr, *filter.MinAmount)
 	}
-	return transactions, nil
+	if filter != nil && filter.MaxAmount != nil {
+		db = db.Where("? <= ?", side.expr, *filter.MaxAmount)
+	}
+	return db
 }
 
-func (s *service) GetAllTransactions(cardId uint) ([]*models.Transaction, error) {
+// cardHistory reads one page of the transactions branches select, filtered
+// and sorted by side, tags matched against the ones accountID put on them.
+// The filters, the cursor and the page size go into every branch, so each
+// reads no more than a page off its index before they are merged. One more
+// row than the page holds is read to tell whether another page follows.
+func cardHistory(db *gorm.DB, branches []historyBranch, side historySide, accountID uint, filter *models.TransactionFilter, page *models.HistoryPageRequest) (*models.HistoryPage, error) {
+	direction, after := "DESC", "<"
+	if page.Ascending {
+		direction, after = "ASC", ">"
+	}
+	sortBy := func(side historySide) clause.Expr {
+		if page.SortBy == models.HistorySortAmount {
+			return side.expr
+		}
+		return clause.Expr{SQL: "transaction_time"}
+	}
+	order := func(side historySide) clause.OrderBy {
+		return clause.OrderBy{Expression: clause.Expr{
+			SQL:  "? " + direction + ", id " + direction,
+			Vars: []interface{}{sortBy(side)},
+		}}
+	}
+
+	query := unionBranches(db, branches, func(branch historyBranch) *gorm.DB {
+		q := applyAmountFilter(applyTransactionFilter(branch.db, accountID, filter), branch.side, filter)
+		if c := page.After; c != nil {
+			var value interface{}
+			if page.SortBy == models.HistorySortAmount {
+				value = *c.Amount
+			} else {
+				value = *c.Time
+			}
+			q = q.Where("(?, id) "+after+" (?, ?)", sortBy(branch.side), value, c.ID)
+		}
+		return q.Clauses(order(branch.side)).Limit(page.Limit + 1)
+	})
+	if len(branches) > 1 {
+		query = query.Clauses(order(side)).Limit(page.Limit + 1)
+	}
+
 	var transactions []*models.Transaction
-	// Query for all transactions related to the card, either as sender or recipient
-	result := s.db.Where("from_card_id = ? OR to_card_id = ?", cardId, cardId).Find(&transactions)
+	result := query.Preload("Refunds").Preload("Splits").Find(&transactions)
 	if result.Error != nil {
 		return nil, result.Error
 	}
-	return transactions, nil
+
+	history := &models.HistoryPage{Transactions: transactions}
+	if len(transactions) > page.Limit {
+		history.Transactions = transactions[:page.Limit]
+		last := history.Transactions[page.Limit-1]
+
+		cursor := &models.HistoryCursor{SortBy: page.SortBy, Ascending: page.Ascending, ID: last.ID}
+		if page.SortBy == models.HistorySortAmount {
+			lastAmount := side.of(last)
+			cursor.Amount = &lastAmount
+		} else {
+			cursor.Time = &last.TransactionTime
+		}
+		history.NextCursor = cursor.Encode()
+	}
+	if history.Transactions == nil {
+		history.Transactions = []*models.Transaction{}
+	}
+
+	return history, nil
+}
+
+// applyTransactionFilter applies filter to db; tags are matched against the
+// ones accountID put on the transactions.
+func applyTransactionFilter(db *gorm.DB, accountID uint, filter *models.TransactionFilter) *gorm.DB {
+	if filter == nil {
+		return db
+	}
+
+	if filter.Kind != "" {
+		db = db.Where("kind = ?", filter.Kind)
+	}
+	if filter.CategoryID != nil {
+		db = db.Where("(category_id = ? OR id IN (SELECT transaction_id FROM transaction_splits WHERE category_id = ?))",
+			*filter.CategoryID, *filter.CategoryID)
+	}
+	if filter.Description != "" {
+		db = db.Where("description ILIKE ?", "%"+escapeLike(filter.Description)+"%")
+	}
+	if filter.ExternalReference != "" {
+		db = db.Where("external_reference = ?", filter.ExternalReference)
+	}
+	if filter.CounterpartyName != "" {
+		db = db.Where("counterparty_name ILIKE ?", "%"+escapeLike(filter.CounterpartyName)+"%")
+	}
+	if filter.Tag != "" {
+		db = db.Where("EXISTS (SELECT 1 FROM transaction_tags WHERE transaction_tags.transaction_id = transactions.id AND transaction_tags.account_id = ? AND transaction_tags.tag = ?)",
+			accountID, filter.Tag)
+	}
+	if filter.From != nil {
+		db = db.Where("transaction_time >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		db = db.Where("transaction_time < ?", *filter.To)
+	}
+	if filter.CounterpartyCardNumber != "" {
+		db = db.Where("(from_card_id IN (SELECT id FROM cards WHERE card_number = ?) OR to_card_id IN (SELECT id FROM cards WHERE card_number = ?))",
+			filter.CounterpartyCardNumber, filter.CounterpartyCardNumber)
+	}
+
+	return db
+}
+
+// escapeLike makes s match literally inside a LIKE pattern.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// UpdateTransactionDetails changes the description and category of a
+// transaction and the tags accountID put on it, and returns it with those
+// tags. Amounts and cards can never be edited.
+func (s *service) UpdateTransactionDetails(id, accountID uint, req *models.UpdateTransactionRequest) (*models.Transaction, error) {
+	updates := map[string]interface{}{}
+	if req.Description != nil {
+		updates["description"] = *req.Description
+	}
+	if req.CategoryID != nil {
+		if *req.CategoryID == 0 {
+			updates["category_id"] = nil
+		} else {
+			updates["category_id"] = *req.CategoryID
+		}
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if len(updates) > 0 {
+			result := tx.Model(&models.Transaction{}).Where("id = ?", id).Updates(updates)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return fmt.Errorf("%w: id=%v", ErrTransactionNotFound, id)
+			}
+		}
+
+		if req.Tags == nil {
+			return nil
+		}
+		return setTags(tx, id, accountID, models.NormalizeTags(*req.Tags))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ts, err := s.GetTransaction(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.LoadTags(accountID, ts); err != nil {
+		return nil, err
+	}
+
+	return ts, nil
+}
+
+// SplitTransaction replaces the lines a transaction is split into; with no
+// splits the transaction is whole again.
+func (s *service) SplitTransaction(id uint, splits []*models.TransactionSplit) (*models.Transaction, error) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var ts models.Transaction
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&ts, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("%w: id=%v", ErrTransactionNotFound, id)
+			}
+			return err
+		}
+
+		if err := tx.Where("transaction_id = ?", id).Delete(&models.TransactionSplit{}).Error; err != nil {
+			return err
+		}
+		if len(splits) == 0 {
+			return nil
+		}
+
+		return tx.Create(splits).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("Successfully split transaction (id=%v) into %v lines\n", id, len(splits))
+	return s.GetTransaction(id)
 }
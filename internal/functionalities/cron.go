@@ -0,0 +1,162 @@
+package functionalities
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CronSchedule is a parsed five-field cron rule: minute, hour, day of month,
+// month and day of week (0 or 7 is Sunday). Fields accept *, single values,
+// ranges (1-5), lists (1,15) and steps (*/2, 1-10/3).
+type CronSchedule struct {
+	minutes  [60]bool
+	hours    [24]bool
+	days     [32]bool
+	months   [13]bool
+	weekdays [7]bool
+
+	// cron runs on either day field matching when both are restricted
+	anyDay     bool
+	anyWeekday bool
+}
+
+type cronField struct {
+	name     string
+	min, max int
+}
+
+var cronFields = []cronField{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+func ParseCron(expr string) (*CronSchedule, error) {
+	parts := strings.Fields(expr)
+	if len(parts) != len(cronFields) {
+		return nil, fmt.Errorf("cron rule %q must have %d fields", expr, len(cronFields))
+	}
+
+	c := &CronSchedule{
+		anyDay:     parts[2] == "*",
+		anyWeekday: parts[4] == "*",
+	}
+
+	for i, part := range parts {
+		values, err := parseCronField(part, cronFields[i])
+		if err != nil {
+			return nil, err
+		}
+
+		for _, v := range values {
+			switch i {
+			case 0:
+				c.minutes[v] = true
+			case 1:
+				c.hours[v] = true
+			case 2:
+				c.days[v] = true
+			case 3:
+				c.months[v] = true
+			case 4:
+				c.weekdays[v%7] = true
+			}
+		}
+	}
+
+	return c, nil
+}
+
+func parseCronField(part string, field cronField) ([]int, error) {
+	var values []int
+
+	for _, item := range strings.Split(part, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(item, "/")
+
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepPart)
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("invalid step %q in %s field", stepPart, field.name)
+			}
+			step = n
+		}
+
+		lo, hi := field.min, field.max
+		if rangePart != "*" {
+			from, to, isRange := strings.Cut(rangePart, "-")
+
+			n, err := strconv.Atoi(from)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %q in %s field", from, field.name)
+			}
+			lo, hi = n, n
+			if hasStep && !isRange {
+				hi = field.max
+			}
+
+			if isRange {
+				if hi, err = strconv.Atoi(to); err != nil {
+					return nil, fmt.Errorf("invalid value %q in %s field", to, field.name)
+				}
+			}
+		}
+
+		if lo < field.min || hi > field.max || lo > hi {
+			return nil, fmt.Errorf("%s field %q is out of range %d-%d", field.name, item, field.min, field.max)
+		}
+
+		for v := lo; v <= hi; v += step {
+			values = append(values, v)
+		}
+	}
+
+	return values, nil
+}
+
+func (c *CronSchedule) dayMatches(t time.Time) bool {
+	day, weekday := c.days[t.Day()], c.weekdays[t.Weekday()]
+
+	switch {
+	case c.anyDay && c.anyWeekday:
+		return true
+	case c.anyDay:
+		return weekday
+	case c.anyWeekday:
+		return day
+	}
+	return day || weekday
+}
+
+// Next returns the first minute strictly after `after` that matches the rule,
+// in after's location. A rule that never fires (30 February) gives the zero time.
+func (c *CronSchedule) Next(after time.Time) time.Time {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if !c.months[t.Month()] {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.hours[t.Hour()] {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.minutes[t.Minute()] {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+
+	return time.Time{}
+}
@@ -0,0 +1,96 @@
+package functionalities
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	valid := []string{
+		"* * * * *",
+		"0 9 1 * *",
+		"*/15 8-18 * * 1-5",
+		"0 0 1,15 * *",
+		"30 6 * 1-12/3 0",
+		"0 12 * * 7",
+		"5-50/5 * 31 12 *",
+	}
+	for _, expr := range valid {
+		if _, err := ParseCron(expr); err != nil {
+			t.Errorf("ParseCron(%q): unexpected error: %v", expr, err)
+		}
+	}
+
+	invalid := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * 32 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"*/x * * * *",
+		"a * * * *",
+		"1- * * * *",
+	}
+	for _, expr := range invalid {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("ParseCron(%q): expected an error", expr)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	at := func(s string) time.Time {
+		v, err := time.Parse("2006-01-02 15:04", s)
+		if err != nil {
+			t.Fatalf("bad time %q: %v", s, err)
+		}
+		return v
+	}
+
+	tests := []struct {
+		expr  string
+		after string
+		want  string // empty when the rule never fires
+	}{
+		{"* * * * *", "2024-03-01 10:00", "2024-03-01 10:01"},
+		{"0 9 1 * *", "2024-03-01 09:00", "2024-04-01 09:00"},
+		{"0 9 1 * *", "2024-03-01 08:59", "2024-03-01 09:00"},
+		{"*/15 * * * *", "2024-03-01 10:07", "2024-03-01 10:15"},
+		{"0 0 * * *", "2024-12-31 23:59", "2025-01-01 00:00"},
+		// 2024-03-02 is a Saturday
+		{"0 9 * * 1-5", "2024-03-01 09:00", "2024-03-04 09:00"},
+		{"0 9 * * 0", "2024-03-01 09:00", "2024-03-03 09:00"},
+		{"0 9 * * 7", "2024-03-01 09:00", "2024-03-03 09:00"},
+		// both day fields restricted: either one matching is enough
+		{"0 9 15 * 1", "2024-03-01 09:00", "2024-03-04 09:00"},
+		{"0 9 15 * 1", "2024-03-12 09:00", "2024-03-15 09:00"},
+		// the 31st skips the months without one
+		{"0 9 31 * *", "2024-04-01 00:00", "2024-05-31 09:00"},
+		{"0 0 29 2 *", "2024-03-01 00:00", "2028-02-29 00:00"},
+		{"0 0 30 2 *", "2024-01-01 00:00", ""},
+	}
+
+	for _, tt := range tests {
+		rule, err := ParseCron(tt.expr)
+		if err != nil {
+			t.Fatalf("ParseCron(%q): %v", tt.expr, err)
+		}
+
+		got := rule.Next(at(tt.after))
+		if tt.want == "" {
+			if !got.IsZero() {
+				t.Errorf("%q after %s = %v; expected it never to fire", tt.expr, tt.after, got)
+			}
+			continue
+		}
+		if !got.Equal(at(tt.want)) {
+			t.Errorf("%q after %s = %v; expected %s", tt.expr, tt.after, got.Format("2006-01-02 15:04"), tt.want)
+		}
+	}
+}
@@ -0,0 +1,304 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	FrequencyQuarterly = "quarterly"
+	FrequencyYearly    = "yearly"
+
+	BillPaid     = "paid"
+	BillPartial  = "partial"
+	BillUpcoming = "upcoming"
+	BillOverdue  = "overdue"
+
+	defaultBillReminderDays = 3
+
+	// billTolerancePercent is how far short of the expected amount a bill
+	// can be paid and still count as paid, for rounding and small fees.
+	billTolerancePercent = 1
+)
+
+// Bill is a fixed payment the account makes to the same card every month,
+// quarter or year. It is due on DueDay (the last day of shorter months) of
+// StartMonth and every period after it.
+type Bill struct {
+	gorm.Model
+	AccountID        uint   `json:"-" gorm:"not null;index"`
+	Name             string `json:"name" gorm:"size:100;not null"`
+	PayeeCardNumber  string `json:"payeeCardNumber" gorm:"not null"`
+	Expected         Money  `json:"expected" gorm:"embedded;embeddedPrefix:expected_"`
+	DueDay           int    `json:"dueDay" gorm:"not null"`
+	Frequency        string `json:"frequency" gorm:"size:16;not null"`
+	StartMonth       string `json:"startMonth" gorm:"size:7;not null"`
+	RemindDaysBefore int    `json:"remindDaysBefore"`
+}
+
+type CreateBillRequest struct {
+	Name             string `json:"name"`
+	PayeeCardNumber  string `json:"payeeCardNumber"`
+	Expected         Money  `json:"expected"`
+	DueDay           int    `json:"dueDay"`           // 1-31
+	Frequency        string `json:"frequency"`        // monthly (default), quarterly or yearly
+	StartMonth       string `json:"startMonth"`       // 2006-01, the current month when empty
+	RemindDaysBefore *int   `json:"remindDaysBefore"` // defaults to 3
+}
+
+func NewBill(accountID uint, req *CreateBillRequest, now time.Time) (*Bill, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, fmt.Errorf("bill name is required")
+	}
+	if strings.TrimSpace(req.PayeeCardNumber) == "" {
+		return nil, fmt.Errorf("payee card number is required")
+	}
+	if req.Expected.Amount <= 0 {
+		return nil, fmt.Errorf("expected amount must be positive")
+	}
+	if req.DueDay < 1 || req.DueDay > 31 {
+		return nil, fmt.Errorf("due day must be between 1 and 31")
+	}
+
+	frequency := req.Frequency
+	if frequency == "" {
+		frequency = FrequencyMonthly
+	}
+	if billStep(frequency) == 0 {
+		return nil, fmt.Errorf("unknown frequency %q, expected monthly, quarterly or yearly", frequency)
+	}
+
+	startMonth := req.StartMonth
+	if startMonth == "" {
+		startMonth = now.Format(BudgetMonthLayout)
+	}
+	if _, err := ParseBudgetMonth(startMonth); err != nil {
+		return nil, err
+	}
+
+	remind := defaultBillReminderDays
+	if req.RemindDaysBefore != nil {
+		remind = *req.RemindDaysBefore
+	}
+	if remind < 0 {
+		return nil, fmt.Errorf("reminder days cannot be negative")
+	}
+
+	return &Bill{
+		AccountID:        accountID,
+		Name:             name,
+		PayeeCardNumber:  strings.TrimSpace(req.PayeeCardNumber),
+		Expected:         NewMoney(req.Expected.Amount, req.Expected.Currency),
+		DueDay:           req.DueDay,
+		Frequency:        frequency,
+		StartMonth:       startMonth,
+		RemindDaysBefore: remind,
+	}, nil
+}
+
+// billStep is the number of months between two due dates.
+func billStep(frequency string) int {
+	switch frequency {
+	case FrequencyMonthly:
+		return 1
+	case FrequencyQuarterly:
+		return 3
+	case FrequencyYearly:
+		return 12
+	}
+	return 0
+}
+
+// dueDate is the k-th due date of the bill, midnight in loc. It is zero
+// before StartMonth.
+func (b *Bill) dueDate(k int, loc *time.Location) time.Time {
+	start, _ := time.ParseInLocation(BudgetMonthLayout, b.StartMonth, loc)
+	month := start.AddDate(0, k*billStep(b.Frequency), 0)
+
+	lastDay := month.AddDate(0, 1, -1).Day()
+	day := b.DueDay
+	if day > lastDay {
+		day = lastDay
+	}
+	return month.AddDate(0, 0, day-1)
+}
+
+// PaymentWindow is when a transfer to the payee counts towards an occurrence:
+// halfway from the due date before it to halfway to the one after.
+type PaymentWindow struct {
+	Due      time.Time
+	From, To time.Time
+}
+
+// DueIn lists the bill's due dates in [from, to) with their payment windows.
+func (b *Bill) DueIn(from, to time.Time, loc *time.Location) []PaymentWindow {
+	var windows []PaymentWindow
+	for k := 0; ; k++ {
+		due := b.dueDate(k, loc)
+		if !due.Before(to) {
+			break
+		}
+		if due.Before(from) {
+			continue
+		}
+
+		prev := due.AddDate(0, -billStep(b.Frequency), 0)
+		if k > 0 {
+			prev = b.dueDate(k-1, loc)
+		}
+		next := b.dueDate(k+1, loc)
+
+		windows = append(windows, PaymentWindow{
+			Due:  due,
+			From: due.Add(-due.Sub(prev) / 2),
+			To:   due.Add(next.Sub(due) / 2),
+		})
+	}
+
+	return windows
+}
+
+// MonthlyAverage is what the bill comes to per month.
+func (b *Bill) MonthlyAverage() Money {
+	return NewMoney(b.Expected.Amount/int64(billStep(b.Frequency)), b.Expected.Currency)
+}
+
+// BillPayment is a transfer from one of the account's cards to a payee card.
+type BillPayment struct {
+	TransactionID uint      `json:"transactionId"`
+	CardNumber    string    `json:"-"` // the payee's
+	Amount        Money     `json:"amount"`
+	At            time.Time `json:"at"`
+}
+
+// BillDue is one due date of a bill with its payment window.
+type BillDue struct {
+	Bill   *Bill
+	Window PaymentWindow
+}
+
+// Fits reports whether p went to the bill's payee within the payment window.
+func (d *BillDue) Fits(p *BillPayment) bool {
+	return p.CardNumber == d.Bill.PayeeCardNumber && !p.At.Before(d.Window.From) && p.At.Before(d.Window.To)
+}
+
+// AssignBillPayments gives each payment to at most one due date: out of those
+// it fits, the one whose expected amount is nearest to it, then the one due
+// nearest to it. So two bills to the same card, or a payment between two
+// due dates, never count one transfer twice. convert turns a payment into a
+// bill's currency; the payments returned for each due date, by index, are
+// converted copies.
+func AssignBillPayments(dues []*BillDue, payments []*BillPayment, convert func(Money, string) (Money, error)) ([][]*BillPayment, error) {
+	assigned := make([][]*BillPayment, len(dues))
+
+	for _, p := range payments {
+		best := -1
+		var bestAmount Money
+		var bestOff float64
+		var bestFrom time.Duration
+
+		for i, due := range dues {
+			if !due.Fits(p) {
+				continue
+			}
+
+			amount, err := convert(p.Amount, due.Bill.Expected.Currency)
+			if err != nil {
+				return nil, err
+			}
+			// how far off the expected amount, as a share of it, so bills in
+			// different currencies compare
+			off := math.Abs(float64(amount.Amount-due.Bill.Expected.Amount)) / float64(due.Bill.Expected.Amount)
+			from := p.At.Sub(due.Window.Due)
+			if from < 0 {
+				from = -from
+			}
+
+			if best < 0 || off < bestOff || (off == bestOff && from < bestFrom) {
+				best, bestAmount, bestOff, bestFrom = i, amount, off, from
+			}
+		}
+
+		if best >= 0 {
+			payment := *p
+			payment.Amount = bestAmount
+			assigned[best] = append(assigned[best], &payment)
+		}
+	}
+
+	return assigned, nil
+}
+
+// BillOccurrence is one due date of a bill and the transfers that paid it.
+// It is paid once they come to the expected amount, less a small tolerance,
+// and partly paid before that.
+type BillOccurrence struct {
+	DueDate   string         `json:"dueDate"` // 2006-01-02
+	Status    string         `json:"status"`  // paid, partial, upcoming or overdue
+	Paid      Money          `json:"paid"`
+	Payments  []*BillPayment `json:"payments"`
+	DueInDays int            `json:"dueInDays"` // negative once past
+	Remind    bool           `json:"remind"`    // not fully paid and due within the bill's reminder days
+}
+
+// NewBillOccurrence is the occurrence in window paid by payments, the ones
+// AssignBillPayments gave it. today is midnight of the current day.
+func NewBillOccurrence(bill *Bill, window PaymentWindow, payments []*BillPayment, today time.Time) *BillOccurrence {
+	occurrence := &BillOccurrence{
+		DueDate:  window.Due.Format("2006-01-02"),
+		Paid:     NewMoney(0, bill.Expected.Currency),
+		Payments: []*BillPayment{},
+	}
+
+	for _, p := range payments {
+		occurrence.Payments = append(occurrence.Payments, p)
+		occurrence.Paid = occurrence.Paid.Add(p.Amount)
+	}
+
+	// count whole calendar days, which may be 23 or 25 hours long
+	for d := today; d.Before(window.Due); d = d.AddDate(0, 0, 1) {
+		occurrence.DueInDays++
+	}
+	for d := window.Due; d.Before(today); d = d.AddDate(0, 0, 1) {
+		occurrence.DueInDays--
+	}
+
+	enough := bill.Expected.Amount - bill.Expected.Amount*billTolerancePercent/100
+	switch {
+	case occurrence.Paid.Amount >= enough:
+		occurrence.Status = BillPaid
+	case len(occurrence.Payments) > 0:
+		occurrence.Status = BillPartial
+	case occurrence.DueInDays < 0:
+		occurrence.Status = BillOverdue
+	default:
+		occurrence.Status = BillUpcoming
+	}
+	if occurrence.Status != BillPaid && occurrence.DueInDays >= 0 {
+		occurrence.Remind = occurrence.DueInDays <= bill.RemindDaysBefore
+	}
+
+	return occurrence
+}
+
+type BillStatus struct {
+	Bill        *Bill             `json:"bill"`
+	Occurrences []*BillOccurrence `json:"occurrences"`
+}
+
+// BillsOverview is the account's bills due in one month. Committed is what
+// they are expected to cost that month and MonthlyAverage what all bills
+// cost per month over a year, both in Currency.
+type BillsOverview struct {
+	Month          string        `json:"month"`
+	Currency       string        `json:"currency"`
+	Bills          []*BillStatus `json:"bills"`
+	Committed      Money         `json:"committed"`
+	Paid           Money         `json:"paid"`
+	MonthlyAverage Money         `json:"monthlyAverage"`
+}
@@ -0,0 +1,115 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewBillOccurrence(t *testing.T) {
+	bill := &Bill{Expected: NewMoney(1000000, "KZT"), RemindDaysBefore: 3}
+	due := time.Date(2024, 5, 10, 0, 0, 0, 0, time.UTC)
+	window := PaymentWindow{Due: due, From: due.AddDate(0, 0, -15), To: due.AddDate(0, 0, 15)}
+	paid := func(amounts ...int64) []*BillPayment {
+		var payments []*BillPayment
+		for _, amount := range amounts {
+			payments = append(payments, &BillPayment{Amount: NewMoney(amount, "KZT"), At: due})
+		}
+		return payments
+	}
+
+	tests := []struct {
+		name       string
+		payments   []*BillPayment
+		today      time.Time
+		wantStatus string
+		wantRemind bool
+	}{
+		{name: "paid in full", payments: paid(1000000), today: due, wantStatus: BillPaid},
+		{name: "paid in two parts", payments: paid(600000, 400000), today: due, wantStatus: BillPaid},
+		{name: "within the tolerance", payments: paid(990000), today: due, wantStatus: BillPaid},
+		{name: "short of the tolerance", payments: paid(989999), today: due.AddDate(0, 0, -2), wantStatus: BillPartial, wantRemind: true},
+		{name: "partly paid and past due", payments: paid(100), today: due.AddDate(0, 0, 1), wantStatus: BillPartial},
+		{name: "upcoming", today: due.AddDate(0, 0, -5), wantStatus: BillUpcoming},
+		{name: "upcoming, remind", today: due.AddDate(0, 0, -3), wantStatus: BillUpcoming, wantRemind: true},
+		{name: "overdue", today: due.AddDate(0, 0, 2), wantStatus: BillOverdue},
+	}
+
+	for _, tt := range tests {
+		got := NewBillOccurrence(bill, window, tt.payments, tt.today)
+		if got.Status != tt.wantStatus || got.Remind != tt.wantRemind {
+			t.Errorf("%s: got %v, remind %v; expected %v, remind %v", tt.name, got.Status, got.Remind, tt.wantStatus, tt.wantRemind)
+		}
+	}
+}
+
+func TestAssignBillPayments(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 5, d, 0, 0, 0, 0, time.UTC) }
+	window := func(due time.Time) PaymentWindow {
+		return PaymentWindow{Due: due, From: due.AddDate(0, 0, -15), To: due.AddDate(0, 0, 15)}
+	}
+	rent := &Bill{Name: "rent", PayeeCardNumber: "4400", Expected: NewMoney(20000000, "KZT")}
+	utilities := &Bill{Name: "utilities", PayeeCardNumber: "4400", Expected: NewMoney(1500000, "KZT")}
+	internet := &Bill{Name: "internet", PayeeCardNumber: "5500", Expected: NewMoney(1000, "USD")}
+
+	dues := []*BillDue{
+		{Bill: rent, Window: window(day(1))},
+		{Bill: utilities, Window: window(day(10))},
+		{Bill: internet, Window: window(day(20))},
+	}
+	payments := []*BillPayment{
+		{TransactionID: 1, CardNumber: "4400", Amount: NewMoney(20000000, "KZT"), At: day(2)},
+		{TransactionID: 2, CardNumber: "4400", Amount: NewMoney(1450000, "KZT"), At: day(3)},
+		{TransactionID: 3, CardNumber: "5500", Amount: NewMoney(500000, "KZT"), At: day(19)},
+		{TransactionID: 4, CardNumber: "6600", Amount: NewMoney(1500000, "KZT"), At: day(10)},
+	}
+	// 500 KZT to the dollar
+	convert := func(m Money, currency string) (Money, error) {
+		if m.Currency == currency {
+			return m, nil
+		}
+		return NewMoney(m.Amount/500, currency), nil
+	}
+
+	assigned, err := AssignBillPayments(dues, payments, convert)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := [][]uint{{1}, {2}, {3}}
+	for i, ids := range want {
+		if len(assigned[i]) != len(ids) {
+			t.Errorf("%s: got %d payments; expected %v", dues[i].Bill.Name, len(assigned[i]), ids)
+			continue
+		}
+		for j, id := range ids {
+			if assigned[i][j].TransactionID != id {
+				t.Errorf("%s: got payment %d; expected %d", dues[i].Bill.Name, assigned[i][j].TransactionID, id)
+			}
+		}
+	}
+	if got := assigned[2][0].Amount; got.Amount != 1000 || got.Currency != "USD" {
+		t.Errorf("internet: got %v; expected the payment in USD", got)
+	}
+	if payments[2].Amount.Currency != "KZT" {
+		t.Errorf("the payment given was changed: %v", payments[2].Amount)
+	}
+}
+
+func TestAssignBillPaymentsNearestDueDate(t *testing.T) {
+	bill := &Bill{PayeeCardNumber: "4400", Expected: NewMoney(500000, "KZT")}
+	may, june := time.Date(2024, 5, 31, 0, 0, 0, 0, time.UTC), time.Date(2024, 6, 30, 0, 0, 0, 0, time.UTC)
+	// windows that overlap, as two bills' would
+	dues := []*BillDue{
+		{Bill: bill, Window: PaymentWindow{Due: may, From: may.AddDate(0, 0, -20), To: may.AddDate(0, 0, 20)}},
+		{Bill: bill, Window: PaymentWindow{Due: june, From: june.AddDate(0, 0, -20), To: june.AddDate(0, 0, 20)}},
+	}
+	payment := &BillPayment{CardNumber: "4400", Amount: NewMoney(500000, "KZT"), At: time.Date(2024, 6, 12, 0, 0, 0, 0, time.UTC)}
+
+	assigned, err := AssignBillPayments(dues, []*BillPayment{payment}, func(m Money, _ string) (Money, error) { return m, nil })
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(assigned[0]) != 1 || len(assigned[1]) != 0 {
+		t.Errorf("got %d and %d payments; expected the payment on the May due date only", len(assigned[0]), len(assigned[1]))
+	}
+}
@@ -0,0 +1,109 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// BudgetMonthLayout is how budget months are written: "2006-01".
+const BudgetMonthLayout = "2006-01"
+
+// Budget is what an account plans to spend in one category during one
+// month. With Rollover set, whatever is left at the end of the month is added
+// to the next month's budget for the same category.
+type Budget struct {
+	gorm.Model
+	AccountID  uint      `json:"-" gorm:"not null;uniqueIndex:idx_budgets_account_category_month"`
+	CategoryID uint      `json:"categoryId" gorm:"not null;uniqueIndex:idx_budgets_account_category_month"`
+	Category   *Category `json:"category,omitempty" gorm:"foreignKey:CategoryID"`
+	Month      string    `json:"month" gorm:"size:7;not null;uniqueIndex:idx_budgets_account_category_month"`
+	Planned    Money     `json:"planned" gorm:"embedded;embeddedPrefix:planned_"`
+	Rollover   bool      `json:"rollover"`
+}
+
+// SetBudgetRequest creates the budget for a category and month, or replaces
+// it when one already exists.
+type SetBudgetRequest struct {
+	CategoryID uint   `json:"categoryId"`
+	Month      string `json:"month"` // 2006-01
+	Planned    Money  `json:"planned"`
+	Rollover   bool   `json:"rollover"`
+}
+
+func NewBudget(accountID uint, req *SetBudgetRequest) (*Budget, error) {
+	if _, err := ParseBudgetMonth(req.Month); err != nil {
+		return nil, err
+	}
+	if req.Planned.IsNegative() {
+		return nil, fmt.Errorf("planned amount cannot be negative")
+	}
+
+	return &Budget{
+		AccountID:  accountID,
+		CategoryID: req.CategoryID,
+		Month:      req.Month,
+		Planned:    NewMoney(req.Planned.Amount, req.Planned.Currency),
+		Rollover:   req.Rollover,
+	}, nil
+}
+
+// ParseBudgetMonth returns midnight UTC on the first day of a "2006-01" month.
+func ParseBudgetMonth(month string) (time.Time, error) {
+	t, err := time.Parse(BudgetMonthLayout, month)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid month %q, expected YYYY-MM", month)
+	}
+	return t, nil
+}
+
+// CategorySpending is what an account spent in one category and currency. A
+// nil CategoryID stands for uncategorized spending.
+type CategorySpending struct {
+	CategoryID *uint `json:"categoryId"`
+	Spent      Money `json:"spent"`
+}
+
+// BudgetStatus compares a budget with what was actually spent. Available is
+// the planned amount plus what rolled over from the month before; Remaining
+// and Overspent are never negative and at most one of them is non-zero.
+type BudgetStatus struct {
+	Budget     *Budget `json:"budget"`
+	RolledOver Money   `json:"rolledOver"`
+	Available  Money   `json:"available"`
+	Spent      Money   `json:"spent"`
+	Remaining  Money   `json:"remaining"`
+	Overspent  Money   `json:"overspent"`
+}
+
+// NewBudgetStatus works out a budget's figures. rolledOver and spent must be
+// in the budget's currency.
+func NewBudgetStatus(budget *Budget, rolledOver, spent Money) *BudgetStatus {
+	available := budget.Planned.Add(rolledOver)
+	left := available.Sub(spent)
+
+	status := &BudgetStatus{
+		Budget:     budget,
+		RolledOver: rolledOver,
+		Available:  available,
+		Spent:      spent,
+		Remaining:  NewMoney(0, budget.Planned.Currency),
+		Overspent:  NewMoney(0, budget.Planned.Currency),
+	}
+	if left.IsNegative() {
+		status.Overspent = left.Neg()
+	} else {
+		status.Remaining = left
+	}
+
+	return status
+}
+
+// BudgetReport is an account's budgets for a month, plus spending in
+// categories that have no budget that month.
+type BudgetReport struct {
+	Month      string              `json:"month"`
+	Budgets    []*BudgetStatus     `json:"budgets"`
+	Unbudgeted []*CategorySpending `json:"unbudgeted"`
+}
@@ -0,0 +1,63 @@
+package models
+
+import "testing"
+
+func TestNewBudgetStatus(t *testing.T) {
+	budget := &Budget{Planned: NewMoney(10000000, "KZT"), Rollover: true}
+	kzt := func(amount int64) Money { return NewMoney(amount, "KZT") }
+
+	tests := []struct {
+		name          string
+		rolledOver    Money
+		spent         Money
+		wantAvailable Money
+		wantRemaining Money
+		wantOverspent Money
+	}{
+		{name: "under budget", rolledOver: kzt(0), spent: kzt(4000000), wantAvailable: kzt(10000000), wantRemaining: kzt(6000000), wantOverspent: kzt(0)},
+		{name: "exactly on budget", rolledOver: kzt(0), spent: kzt(10000000), wantAvailable: kzt(10000000), wantRemaining: kzt(0), wantOverspent: kzt(0)},
+		{name: "overspent", rolledOver: kzt(0), spent: kzt(12500000), wantAvailable: kzt(10000000), wantRemaining: kzt(0), wantOverspent: kzt(2500000)},
+		{name: "rollover covers the overspending", rolledOver: kzt(3000000), spent: kzt(12500000), wantAvailable: kzt(13000000), wantRemaining: kzt(500000), wantOverspent: kzt(0)},
+		{name: "overspent despite the rollover", rolledOver: kzt(1000000), spent: kzt(12500000), wantAvailable: kzt(11000000), wantRemaining: kzt(0), wantOverspent: kzt(1500000)},
+		{name: "refunds exceed spending", rolledOver: kzt(0), spent: kzt(-200000), wantAvailable: kzt(10000000), wantRemaining: kzt(10200000), wantOverspent: kzt(0)},
+	}
+
+	for _, tt := range tests {
+		got := NewBudgetStatus(budget, tt.rolledOver, tt.spent)
+		if got.Available != tt.wantAvailable || got.Remaining != tt.wantRemaining || got.Overspent != tt.wantOverspent {
+			t.Errorf("%s: got available %v, remaining %v, overspent %v; expected %v, %v, %v",
+				tt.name, got.Available, got.Remaining, got.Overspent, tt.wantAvailable, tt.wantRemaining, tt.wantOverspent)
+		}
+	}
+}
+
+// TestNewBudgetStatusChained rolls three months over into each other the way
+// the budget report does, each month's Remaining carrying into the next.
+func TestNewBudgetStatusChained(t *testing.T) {
+	months := []struct {
+		planned       int64
+		spent         int64
+		wantRemaining int64
+		wantOverspent int64
+	}{
+		{planned: 5000000, spent: 3000000, wantRemaining: 2000000},
+		{planned: 5000000, spent: 6000000, wantRemaining: 1000000},
+		{planned: 5000000, spent: 7000000, wantOverspent: 1000000},
+		// an overspent month carries nothing, rather than a debt
+		{planned: 5000000, spent: 4000000, wantRemaining: 1000000},
+	}
+
+	carried := NewMoney(0, "KZT")
+	for i, month := range months {
+		budget := &Budget{Planned: NewMoney(month.planned, "KZT"), Rollover: true}
+		got := NewBudgetStatus(budget, carried, NewMoney(month.spent, "KZT"))
+
+		if got.RolledOver != carried {
+			t.Errorf("month %d: got rolled over %v; expected %v", i, got.RolledOver, carried)
+		}
+		if got.Remaining.Amount != month.wantRemaining || got.Overspent.Amount != month.wantOverspent {
+			t.Errorf("month %d: got remaining %v, overspent %v; expected %d, %d", i, got.Remaining, got.Overspent, month.wantRemaining, month.wantOverspent)
+		}
+		carried = got.Remaining
+	}
+}
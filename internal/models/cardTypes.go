@@ -6,20 +6,28 @@ import (
 
 type AddCardRequest struct {
 	CardNumber     string        `json:"cardNumber"`
-	CardBalance    float64       `json:"cardBalance"`
+	CardBalance    Money         `json:"cardBalance"`
+	Currency       string        `json:"currency"`
 	CardType       string        `json:"cardType"`
 	CardExpireDate string     `json:"cardExpireDate"`
 	AccountID      uint          `json:"accountId"`
 }
 
-func NewCard(number string, balance float64, _type string, expireDate time.Time, accountId uint) *Card {
+func NewCard(number string, balance Money, _type string, expireDate time.Time, accountId uint) *Card {
 	newCard := &Card{
 		CardNumber:       number,
 		CardBalance:    balance,
+		ReservedBalance: NewMoney(0, balance.Currency),
 		CardType:   _type,
 		CardExpireDate:    expireDate,
 		AccountID:    accountId,
 	}
 
 	return newCard
-}
\ No newline at end of file
+}
+
+// Available is what the card can spend: its balance minus the funds held
+// for pending transfers.
+func (c *Card) Available() Money {
+	return c.CardBalance.Sub(c.ReservedBalance)
+}
@@ -0,0 +1,88 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Category groups transactions for budgets and reports. Categories without
+// an AccountID are built in and shared by everyone. Categories nest under
+// ParentID; archived ones stay on old transactions but cannot be picked for
+// new ones.
+type Category struct {
+	gorm.Model
+	AccountID *uint       `json:"accountId,omitempty" gorm:"index"`
+	ParentID  *uint       `json:"parentId,omitempty" gorm:"index"`
+	Name      string      `json:"name" gorm:"not null;size:100"`
+	Archived  bool        `json:"archived" gorm:"not null;default:false"`
+	Children  []*Category `json:"children,omitempty" gorm:"-"`
+}
+
+type CreateCategoryRequest struct {
+	Name     string `json:"name"`
+	ParentID *uint  `json:"parentId"`
+}
+
+// UpdateCategoryRequest renames, moves or (un)archives a category. Fields
+// left out are unchanged; a parentId of 0 moves it to the top level.
+type UpdateCategoryRequest struct {
+	Name     *string `json:"name"`
+	ParentID *uint   `json:"parentId"`
+	Archived *bool   `json:"archived"`
+}
+
+func NewCategory(accountID uint, req *CreateCategoryRequest) (*Category, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, fmt.Errorf("category name is required")
+	}
+
+	return &Category{
+		AccountID: &accountID,
+		ParentID:  req.ParentID,
+		Name:      name,
+	}, nil
+}
+
+// BuiltinCategory is one node of the built-in category tree.
+type BuiltinCategory struct {
+	Name     string
+	Children []BuiltinCategory
+}
+
+// BuiltinCategories are created on startup when missing.
+var BuiltinCategories = []BuiltinCategory{
+	{Name: "Housing", Children: []BuiltinCategory{{Name: "Rent"}, {Name: "Utilities"}, {Name: "Maintenance"}}},
+	{Name: "Food", Children: []BuiltinCategory{{Name: "Groceries"}, {Name: "Restaurants"}}},
+	{Name: "Transport", Children: []BuiltinCategory{{Name: "Public transport"}, {Name: "Fuel"}, {Name: "Taxi"}}},
+	{Name: "Health", Children: []BuiltinCategory{{Name: "Pharmacy"}, {Name: "Doctor"}}},
+	{Name: "Shopping", Children: []BuiltinCategory{{Name: "Clothes"}, {Name: "Household"}}},
+	{Name: "Entertainment", Children: []BuiltinCategory{{Name: "Subscriptions"}, {Name: "Hobbies"}}},
+	{Name: "Income", Children: []BuiltinCategory{{Name: "Salary"}}},
+	{Name: "Other"},
+}
+
+// BuildCategoryTree nests a flat list of categories under their parents and
+// returns the roots. Categories whose parent is not in the list become roots.
+func BuildCategoryTree(categories []*Category) []*Category {
+	byID := make(map[uint]*Category, len(categories))
+	for _, c := range categories {
+		c.Children = nil
+		byID[c.ID] = c
+	}
+
+	var roots []*Category
+	for _, c := range categories {
+		if c.ParentID != nil {
+			if parent, ok := byID[*c.ParentID]; ok {
+				parent.Children = append(parent.Children, c)
+				continue
+			}
+		}
+		roots = append(roots, c)
+	}
+
+	return roots
+}
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// rateDigits is the precision exchange rates are stored and applied with.
+const rateDigits = 12
+
+// ExchangeRate says that one unit of BaseCurrency buys Rate units of
+// QuoteCurrency. Rate is kept as a decimal string so it round-trips through
+// the numeric column exactly.
+type ExchangeRate struct {
+	gorm.Model
+	BaseCurrency  string `json:"baseCurrency" gorm:"size:3;not null;uniqueIndex:idx_exchange_rates_pair"`
+	QuoteCurrency string `json:"quoteCurrency" gorm:"size:3;not null;uniqueIndex:idx_exchange_rates_pair"`
+	Rate          string `json:"rate" gorm:"type:numeric(24,12);not null"`
+}
+
+func NewExchangeRate(base, quote, rate string) (*ExchangeRate, error) {
+	base, quote = strings.ToUpper(strings.TrimSpace(base)), strings.ToUpper(strings.TrimSpace(quote))
+	if len(base) != 3 || len(quote) != 3 {
+		return nil, fmt.Errorf("currency codes must have 3 letters, got %q and %q", base, quote)
+	}
+	if base == quote {
+		return nil, fmt.Errorf("rate from %v to itself", base)
+	}
+
+	r, err := ParseRate(rate)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ExchangeRate{
+		BaseCurrency:  base,
+		QuoteCurrency: quote,
+		Rate:          FormatRate(r),
+	}, nil
+}
+
+// ParseRate parses a positive decimal rate and rounds it to the stored precision.
+func ParseRate(s string) (*big.Rat, error) {
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(s))
+	if !ok || r.Sign() <= 0 {
+		return nil, fmt.Errorf("invalid exchange rate %q", s)
+	}
+
+	return RoundRate(r), nil
+}
+
+// RoundRate rounds r to the precision rates are stored with, so the rate
+// recorded on a transaction is exactly the one its amounts were computed from.
+func RoundRate(r *big.Rat) *big.Rat {
+	rounded, _ := new(big.Rat).SetString(r.FloatString(rateDigits))
+	return rounded
+}
+
+func FormatRate(r *big.Rat) string {
+	return r.FloatString(rateDigits)
+}
+
+// ConvertMoney multiplies m by rate and rounds half away from zero to whole
+// minor units of the target currency.
+func ConvertMoney(m Money, rate *big.Rat, currency string) Money {
+	product := new(big.Rat).Mul(new(big.Rat).SetInt64(m.Amount), rate)
+
+	num, den := product.Num(), product.Denom()
+	quo, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+
+	twiceRem := new(big.Int).Mul(new(big.Int).Abs(rem), big.NewInt(2))
+	if twiceRem.Cmp(den) >= 0 {
+		if num.Sign() < 0 {
+			quo.Sub(quo, big.NewInt(1))
+		} else {
+			quo.Add(quo, big.NewInt(1))
+		}
+	}
+
+	return NewMoney(quo.Int64(), currency)
+}
@@ -0,0 +1,89 @@
+package models
+
+import "testing"
+
+func TestConvertMoney(t *testing.T) {
+	tests := []struct {
+		amount int64
+		rate   string
+		want   int64
+	}{
+		{100, "1", 100},
+		{10000, "0.5", 5000},
+		// 1.00 USD at 470.123456 KZT
+		{100, "470.123456", 47012},
+		// halves round away from zero, in both directions
+		{1, "0.5", 1},
+		{-1, "0.5", -1},
+		{3, "0.5", 2},
+		{-3, "0.5", -2},
+		// just under a half rounds down
+		{1, "0.499999999999", 0},
+		{-1, "0.499999999999", 0},
+		{1, "0.500000000001", 1},
+		{1999, "0.0021", 4},
+		{0, "470", 0},
+	}
+
+	for _, tt := range tests {
+		rate, err := ParseRate(tt.rate)
+		if err != nil {
+			t.Fatalf("ParseRate(%q): %v", tt.rate, err)
+		}
+		got := ConvertMoney(NewMoney(tt.amount, "USD"), rate, "KZT")
+		if got.Amount != tt.want || got.Currency != "KZT" {
+			t.Errorf("ConvertMoney(%d, %s) = %v; expected %d KZT", tt.amount, tt.rate, got, tt.want)
+		}
+	}
+}
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "470.5", want: "470.500000000000"},
+		{in: " 1 ", want: "1.000000000000"},
+		// rounded to the stored precision
+		{in: "0.0000000000005", want: "0.000000000001"},
+		{in: "1/3", want: "0.333333333333"},
+		{in: "0", wantErr: true},
+		{in: "-1", wantErr: true},
+		{in: "abc", wantErr: true},
+		{in: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseRate(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseRate(%q): expected an error; got %v", tt.in, FormatRate(got))
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseRate(%q): unexpected error: %v", tt.in, err)
+			continue
+		}
+		if FormatRate(got) != tt.want {
+			t.Errorf("ParseRate(%q) = %v; expected %v", tt.in, FormatRate(got), tt.want)
+		}
+	}
+}
+
+func TestNewExchangeRate(t *testing.T) {
+	rate, err := NewExchangeRate("usd", " kzt ", "470.12")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rate.BaseCurrency != "USD" || rate.QuoteCurrency != "KZT" || rate.Rate != "470.120000000000" {
+		t.Errorf("got %+v", rate)
+	}
+
+	for _, pair := range [][2]string{{"USD", "USD"}, {"US", "KZT"}, {"USD", "KZTT"}} {
+		if _, err := NewExchangeRate(pair[0], pair[1], "1"); err == nil {
+			t.Errorf("NewExchangeRate(%q, %q): expected an error", pair[0], pair[1])
+		}
+	}
+}
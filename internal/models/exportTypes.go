@@ -0,0 +1,199 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ExportRow is one transaction in an export, with the card numbers and
+// category names it refers to. Direction and Amount are from the exported
+// card's side, or the account's when all cards are exported, as in a feed.
+type ExportRow struct {
+	Transaction    *Transaction
+	FromCardNumber string
+	ToCardNumber   string
+	Category       string // the category's name, or the names of its split lines
+	Direction      string
+	Amount         Money
+}
+
+// exportColumns renders each column an export can have.
+var exportColumns = map[string]func(row *ExportRow, f *ExportFormat) string{
+	"id":        func(row *ExportRow, f *ExportFormat) string { return strconv.FormatUint(uint64(row.Transaction.ID), 10) },
+	"date":      func(row *ExportRow, f *ExportFormat) string { return row.Transaction.TransactionTime.In(f.Location).Format(f.DateLayout) },
+	"direction": func(row *ExportRow, f *ExportFormat) string { return row.Direction },
+	"kind":      func(row *ExportRow, f *ExportFormat) string { return row.Transaction.Kind },
+	"status":    func(row *ExportRow, f *ExportFormat) string { return row.Transaction.Status },
+	// negative when money left; internal moves are not signed
+	"amount": func(row *ExportRow, f *ExportFormat) string {
+		if row.Direction == FeedOutgoing {
+			return f.number(row.Amount.Neg())
+		}
+		return f.number(row.Amount)
+	},
+	"currency":         func(row *ExportRow, f *ExportFormat) string { return row.Amount.Currency },
+	"sentAmount":       func(row *ExportRow, f *ExportFormat) string { return f.number(row.Transaction.TransactionAmount) },
+	"sentCurrency":     func(row *ExportRow, f *ExportFormat) string { return row.Transaction.TransactionAmount.Currency },
+	"receivedAmount":   func(row *ExportRow, f *ExportFormat) string { return f.number(row.Transaction.ReceivedAmount) },
+	"receivedCurrency": func(row *ExportRow, f *ExportFormat) string { return row.Transaction.ReceivedAmount.Currency },
+	"fromCard":         func(row *ExportRow, f *ExportFormat) string { return row.FromCardNumber },
+	"toCard":           func(row *ExportRow, f *ExportFormat) string { return row.ToCardNumber },
+	"counterparty":     func(row *ExportRow, f *ExportFormat) string { return row.Transaction.CounterpartyName },
+	"description":      func(row *ExportRow, f *ExportFormat) string { return row.Transaction.Description },
+	"category":         func(row *ExportRow, f *ExportFormat) string { return row.Category },
+	"tags":             func(row *ExportRow, f *ExportFormat) string { return strings.Join(row.Transaction.Tags, ", ") },
+	"reference":        func(row *ExportRow, f *ExportFormat) string { return row.Transaction.ExternalReference },
+}
+
+// exportTextColumns are the columns holding text people typed in. A
+// spreadsheet runs a cell starting with = + - @, a tab or a carriage return
+// as a formula, so Record escapes those; amounts are numbers and keep their
+// minus sign.
+var exportTextColumns = map[string]bool{
+	"fromCard": true, "toCard": true, "counterparty": true, "category": true,
+	"description": true, "tags": true, "reference": true,
+}
+
+var defaultExportColumns = []string{
+	"id", "date", "direction", "amount", "currency", "fromCard", "toCard",
+	"counterparty", "category", "description", "tags", "reference",
+}
+
+var exportDelimiters = map[string]rune{
+	"comma":     ',',
+	"semicolon": ';',
+	"tab":       '\t',
+	"pipe":      '|',
+}
+
+var exportDateLayouts = map[string]string{
+	"iso":      time.RFC3339,
+	"date":     "2006-01-02",
+	"datetime": "2006-01-02 15:04:05",
+	"dmy":      "02.01.2006",
+	"mdy":      "01/02/2006",
+}
+
+const (
+	ExportNumbersPoint = "point" // 1234.50
+	ExportNumbersComma = "comma" // 1234,50
+	ExportNumbersMinor = "minor" // 123450
+)
+
+// ExportFormat is how an export is written: which columns in which order,
+// the field delimiter, and how amounts and dates look. Dates are shown in
+// Location.
+type ExportFormat struct {
+	Columns    []string
+	Delimiter  rune
+	Numbers    string
+	DateLayout string
+	Location   *time.Location
+}
+
+// ParseExportFormat reads an export format from comma-separated column
+// names, a delimiter name or character, a number format and a date format
+// name. Empty values take the defaults: the columns in defaultExportColumns,
+// commas (semicolons with decimal commas), decimal points and RFC 3339 dates.
+func ParseExportFormat(columns, delimiter, numbers, dates string, loc *time.Location) (*ExportFormat, error) {
+	f := &ExportFormat{
+		Columns:    defaultExportColumns,
+		Delimiter:  ',',
+		Numbers:    ExportNumbersPoint,
+		DateLayout: time.RFC3339,
+		Location:   loc,
+	}
+
+	if columns != "" {
+		f.Columns = nil
+		for _, column := range strings.Split(columns, ",") {
+			column = strings.TrimSpace(column)
+			if exportColumns[column] == nil {
+				return nil, fmt.Errorf("unknown column %q, expected some of %s", column, strings.Join(ExportColumnNames(), ", "))
+			}
+			f.Columns = append(f.Columns, column)
+		}
+	}
+
+	switch numbers {
+	case "":
+	case ExportNumbersComma:
+		// spreadsheets that read decimal commas expect semicolons between fields
+		f.Numbers, f.Delimiter = numbers, ';'
+	case ExportNumbersPoint, ExportNumbersMinor:
+		f.Numbers = numbers
+	default:
+		return nil, fmt.Errorf("unknown number format %q, expected point, comma or minor", numbers)
+	}
+
+	if delimiter != "" {
+		if d, ok := exportDelimiters[delimiter]; ok {
+			f.Delimiter = d
+		} else if r, size := utf8.DecodeRuneInString(delimiter); size == len(delimiter) && validDelimiter(r) {
+			f.Delimiter = r
+		} else {
+			return nil, fmt.Errorf("invalid delimiter %q, expected comma, semicolon, tab, pipe or a single character", delimiter)
+		}
+	}
+
+	if dates != "" {
+		layout, ok := exportDateLayouts[dates]
+		if !ok {
+			return nil, fmt.Errorf("unknown date format %q, expected iso, date, datetime, dmy or mdy", dates)
+		}
+		f.DateLayout = layout
+	}
+
+	return f, nil
+}
+
+// ExportColumnNames lists the columns an export can have, in their default
+// order first.
+func ExportColumnNames() []string {
+	names := append([]string(nil), defaultExportColumns...)
+	return append(names, "kind", "status", "sentAmount", "sentCurrency", "receivedAmount", "receivedCurrency")
+}
+
+func validDelimiter(r rune) bool {
+	return r != utf8.RuneError && r != '"' && r != '\r' && r != '\n' && r != ' ' &&
+		!(r >= '0' && r <= '9') && !(r >= 'a' && r <= 'z') && !(r >= 'A' && r <= 'Z')
+}
+
+func (f *ExportFormat) number(m Money) string {
+	switch f.Numbers {
+	case ExportNumbersMinor:
+		return strconv.FormatInt(m.Amount, 10)
+	case ExportNumbersComma:
+		return strings.Replace(m.Decimal(), ".", ",", 1)
+	}
+	return m.Decimal()
+}
+
+// Header is the first record of an export.
+func (f *ExportFormat) Header() []string {
+	return append([]string(nil), f.Columns...)
+}
+
+// Record renders row in the format's columns.
+func (f *ExportFormat) Record(row *ExportRow) []string {
+	record := make([]string, len(f.Columns))
+	for i, column := range f.Columns {
+		record[i] = exportColumns[column](row, f)
+		if exportTextColumns[column] {
+			record[i] = escapeFormula(record[i])
+		}
+	}
+	return record
+}
+
+// escapeFormula prefixes text a spreadsheet would take for a formula with a
+// quote, so it is shown as typed and never run.
+func escapeFormula(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
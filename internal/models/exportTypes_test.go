@@ -0,0 +1,40 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExportRecordEscapesFormulas(t *testing.T) {
+	f, err := ParseExportFormat("amount,counterparty,description,tags,reference,category,fromCard", "", "", "", time.UTC)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ts := &Transaction{
+		CounterpartyName:  "=HYPERLINK(\"http://x\")",
+		Description:       "+1 coffee",
+		Tags:              []string{"@home", "work"},
+		ExternalReference: "-42",
+	}
+	row := &ExportRow{
+		Transaction:    ts,
+		Direction:      FeedOutgoing,
+		Amount:         NewMoney(150000, "KZT"),
+		Category:       "\tfood",
+		FromCardNumber: "\r4400",
+	}
+
+	want := []string{"-1500.00", "'=HYPERLINK(\"http://x\")", "'+1 coffee", "'@home, work", "'-42", "'\tfood", "'\r4400"}
+	got := f.Record(row)
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("%s: got %q; expected %q", f.Columns[i], got[i], want[i])
+		}
+	}
+
+	ts.CounterpartyName, ts.Description = "Landlord", ""
+	if got := f.Record(row); got[1] != "Landlord" || got[2] != "" {
+		t.Errorf("plain text changed: %q, %q", got[1], got[2])
+	}
+}
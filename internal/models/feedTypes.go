@@ -0,0 +1,42 @@
+package models
+
+const (
+	FeedIncoming = "incoming"
+	FeedOutgoing = "outgoing"
+	FeedInternal = "internal" // between two of the account's own cards
+)
+
+// FeedItem is one transaction in an account's feed, seen from the account:
+// Amount is what left it for outgoing and internal moves, and what arrived
+// for incoming ones.
+type FeedItem struct {
+	Direction   string       `json:"direction"`
+	Amount      Money        `json:"amount"`
+	Transaction *Transaction `json:"transaction"`
+}
+
+// NewFeedItem labels ts by which side of it owned, the account's card ids,
+// are on. Entries made by hand without a card go by their kind.
+func NewFeedItem(ts *Transaction, owned map[uint]bool) *FeedItem {
+	fromOwned := ts.FromCardID != nil && owned[*ts.FromCardID]
+	toOwned := ts.ToCardID != nil && owned[*ts.ToCardID]
+
+	item := &FeedItem{Direction: FeedOutgoing, Amount: ts.TransactionAmount, Transaction: ts}
+	switch {
+	case fromOwned && toOwned:
+		item.Direction = FeedInternal
+	case fromOwned:
+	case toOwned, ts.Kind == TransactionKindIncome:
+		item.Direction = FeedIncoming
+		item.Amount = ts.ReceivedAmount
+	}
+
+	return item
+}
+
+// Feed is one page of the transactions of all of an account's cards and its
+// entries, each listed once. NextCursor is empty on the last page.
+type Feed struct {
+	Items      []*FeedItem `json:"items"`
+	NextCursor string      `json:"nextCursor,omitempty"`
+}
@@ -0,0 +1,329 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	ForecastScheduled = "scheduled"
+	ForecastRecurring = "recurring"
+)
+
+// CardMovement is money that came into or left one of an account's cards
+// from outside the account, in the card's currency.
+type CardMovement struct {
+	CardID       uint
+	Incoming     bool
+	Counterparty string // the other card's number, or the name on an entry made by hand
+	Amount       Money
+	At           time.Time
+}
+
+// Key identifies the card, direction and counterparty of the movement.
+func (m *CardMovement) Key() string {
+	return MovementKey(m.CardID, m.Incoming, m.Counterparty)
+}
+
+// MovementKey is the Key of movements between cardID and counterparty.
+func MovementKey(cardID uint, incoming bool, counterparty string) string {
+	return fmt.Sprintf("%d|%t|%s", cardID, incoming, counterparty)
+}
+
+// RecurringPayment is money that keeps moving between a card and the same
+// counterparty, about the same amount each week or month.
+type RecurringPayment struct {
+	CardID       uint      `json:"cardId"`
+	Incoming     bool      `json:"incoming"`
+	Counterparty string    `json:"counterparty"`
+	Amount       Money     `json:"amount"` // the median of past payments
+	Frequency    string    `json:"frequency"`
+	Occurrences  int       `json:"occurrences"`
+	LastAt       time.Time `json:"lastAt"`
+}
+
+// Key matches the Key of the movements that make up the payment.
+func (p *RecurringPayment) Key() string {
+	return MovementKey(p.CardID, p.Incoming, p.Counterparty)
+}
+
+// after returns the k-th payment expected after the last one seen.
+func (p *RecurringPayment) after(k int) time.Time {
+	if p.Frequency == FrequencyWeekly {
+		return p.LastAt.AddDate(0, 0, 7*k)
+	}
+	return addMonthsClamped(p.LastAt, k)
+}
+
+// recurringMinOccurrences is how many payments make a pattern.
+const recurringMinOccurrences = 3
+
+// DetectRecurring finds the payments among movements that repeat weekly or
+// monthly: at least three to the same counterparty, every gap between them
+// close to a week or a month, and no amount more than a quarter off the
+// median. Patterns whose next payment is overdue by more than half a period
+// at now are taken to have stopped.
+func DetectRecurring(movements []*CardMovement, now time.Time) []*RecurringPayment {
+	groups := make(map[string][]*CardMovement)
+	var keys []string
+	for _, m := range movements {
+		if m.Counterparty == "" {
+			continue
+		}
+		k := m.Key()
+		if groups[k] == nil {
+			keys = append(keys, k)
+		}
+		groups[k] = append(groups[k], m)
+	}
+
+	var payments []*RecurringPayment
+	for _, k := range keys {
+		group := groups[k]
+		if len(group) < recurringMinOccurrences {
+			continue
+		}
+		sort.SliceStable(group, func(i, j int) bool { return group[i].At.Before(group[j].At) })
+
+		frequency, period := recurringFrequency(group)
+		if frequency == "" {
+			continue
+		}
+
+		last := group[len(group)-1]
+		if now.Sub(last.At) > period+period/2 {
+			continue
+		}
+
+		amounts := make([]int64, len(group))
+		for i, m := range group {
+			amounts[i] = m.Amount.Amount
+		}
+		median := medianAmount(amounts)
+		if !withinQuarter(amounts, median) {
+			continue
+		}
+
+		payments = append(payments, &RecurringPayment{
+			CardID:       last.CardID,
+			Incoming:     last.Incoming,
+			Counterparty: last.Counterparty,
+			Amount:       NewMoney(median, last.Amount.Currency),
+			Frequency:    frequency,
+			Occurrences:  len(group),
+			LastAt:       last.At,
+		})
+	}
+
+	return payments
+}
+
+// recurringFrequency tells whether movements, oldest first, are spaced a
+// week or a month apart.
+func recurringFrequency(movements []*CardMovement) (string, time.Duration) {
+	const day = 24 * time.Hour
+
+	frequencies := []struct {
+		name     string
+		period   time.Duration
+		min, max time.Duration
+	}{
+		{FrequencyWeekly, 7 * day, 5 * day, 9 * day},
+		{FrequencyMonthly, 30 * day, 25 * day, 36 * day},
+	}
+
+	for _, f := range frequencies {
+		regular := true
+		for i := 1; i < len(movements); i++ {
+			gap := movements[i].At.Sub(movements[i-1].At)
+			if gap < f.min || gap > f.max {
+				regular = false
+				break
+			}
+		}
+		if regular {
+			return f.name, f.period
+		}
+	}
+
+	return "", 0
+}
+
+func medianAmount(amounts []int64) int64 {
+	sorted := append([]int64(nil), amounts...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+func withinQuarter(amounts []int64, median int64) bool {
+	for _, a := range amounts {
+		diff := a - median
+		if diff < 0 {
+			diff = -diff
+		}
+		if diff*4 > median {
+			return false
+		}
+	}
+	return true
+}
+
+// AverageDailySpending is what left the card per day over [since, until),
+// leaving out the movements skip picks (payments the forecast already
+// expects on their own).
+func AverageDailySpending(movements []*CardMovement, card *Card, since, until time.Time, skip func(*CardMovement) bool) Money {
+	total := NewMoney(0, card.CardBalance.Currency)
+	for _, m := range movements {
+		if m.CardID != card.ID || m.Incoming || m.At.Before(since) || !m.At.Before(until) || skip(m) {
+			continue
+		}
+		total = total.Add(m.Amount)
+	}
+
+	days := int64(until.Sub(since) / (24 * time.Hour))
+	if days < 1 {
+		days = 1
+	}
+	return NewMoney(total.Amount/days, total.Currency)
+}
+
+// ForecastEvent is a payment the forecast expects on a card: negative when
+// money leaves it.
+type ForecastEvent struct {
+	At           time.Time `json:"at"`
+	CardID       uint      `json:"-"`
+	Amount       Money     `json:"amount"`
+	Source       string    `json:"source"` // scheduled or recurring
+	Counterparty string    `json:"counterparty"`
+	ScheduleID   *uint     `json:"scheduleId,omitempty"`
+}
+
+// RecurringEvents lists the payments p is expected to make in [from, to).
+func RecurringEvents(p *RecurringPayment, from, to time.Time) []*ForecastEvent {
+	amount := p.Amount
+	if !p.Incoming {
+		amount = amount.Neg()
+	}
+
+	var events []*ForecastEvent
+	for k := 1; ; k++ {
+		at := p.after(k)
+		if !at.Before(to) {
+			break
+		}
+		if at.Before(from) {
+			continue
+		}
+		events = append(events, &ForecastEvent{
+			At:           at,
+			CardID:       p.CardID,
+			Amount:       amount,
+			Source:       ForecastRecurring,
+			Counterparty: p.Counterparty,
+		})
+	}
+
+	return events
+}
+
+// ForecastDay is a card's projected balance at the end of a day.
+type ForecastDay struct {
+	Date           string           `json:"date"` // 2006-01-02
+	Balance        Money            `json:"balance"`
+	Discretionary  Money            `json:"discretionary"`
+	Events         []*ForecastEvent `json:"events"`
+	BelowZero      bool             `json:"belowZero"`
+	BelowThreshold bool             `json:"belowThreshold"`
+}
+
+// ForecastAlert marks the day a card's projected balance drops below zero or
+// the threshold, after being above it.
+type ForecastAlert struct {
+	Date    string `json:"date"`
+	Reason  string `json:"reason"` // belowZero or belowThreshold
+	Balance Money  `json:"balance"`
+}
+
+// CardForecast projects one card's balance day by day.
+type CardForecast struct {
+	CardID             uint                `json:"cardId"`
+	CardNumber         string              `json:"cardNumber"`
+	StartBalance       Money               `json:"startBalance"` // available now
+	DailyDiscretionary Money               `json:"dailyDiscretionary"`
+	Threshold          *Money              `json:"threshold,omitempty"`
+	Recurring          []*RecurringPayment `json:"recurring"`
+	Days               []*ForecastDay      `json:"days"`
+	Alerts             []*ForecastAlert    `json:"alerts"`
+}
+
+// NewCardForecast runs the card's available balance through days days
+// starting with the one that begins at start. Every day loses the daily
+// discretionary amount and gains or loses the events that fall on it; all
+// amounts must be in the card's currency.
+func NewCardForecast(card *Card, start time.Time, days int, events []*ForecastEvent, recurring []*RecurringPayment, daily Money, threshold *Money) *CardForecast {
+	forecast := &CardForecast{
+		CardID:             card.ID,
+		CardNumber:         card.CardNumber,
+		StartBalance:       card.Available(),
+		DailyDiscretionary: daily,
+		Threshold:          threshold,
+		Recurring:          recurring,
+		Days:               make([]*ForecastDay, 0, days),
+		Alerts:             []*ForecastAlert{},
+	}
+	if forecast.Recurring == nil {
+		forecast.Recurring = []*RecurringPayment{}
+	}
+
+	sort.SliceStable(events, func(i, j int) bool { return events[i].At.Before(events[j].At) })
+
+	balance := forecast.StartBalance
+	wasBelowZero, wasBelowThreshold := false, false
+	next := 0
+	for i := 0; i < days; i++ {
+		dayStart := start.AddDate(0, 0, i)
+		dayEnd := start.AddDate(0, 0, i+1)
+
+		day := &ForecastDay{
+			Date:          dayStart.Format("2006-01-02"),
+			Discretionary: daily.Neg(),
+			Events:        []*ForecastEvent{},
+		}
+		for ; next < len(events) && events[next].At.Before(dayEnd); next++ {
+			if events[next].At.Before(dayStart) {
+				continue
+			}
+			day.Events = append(day.Events, events[next])
+			balance = balance.Add(events[next].Amount)
+		}
+		balance = balance.Sub(daily)
+		day.Balance = balance
+
+		day.BelowZero = balance.IsNegative()
+		day.BelowThreshold = threshold != nil && balance.Cmp(*threshold) < 0
+
+		if day.BelowZero && !wasBelowZero {
+			forecast.Alerts = append(forecast.Alerts, &ForecastAlert{Date: day.Date, Reason: "belowZero", Balance: balance})
+		} else if day.BelowThreshold && !wasBelowThreshold && !day.BelowZero {
+			forecast.Alerts = append(forecast.Alerts, &ForecastAlert{Date: day.Date, Reason: "belowThreshold", Balance: balance})
+		}
+		wasBelowZero, wasBelowThreshold = day.BelowZero, day.BelowThreshold
+
+		forecast.Days = append(forecast.Days, day)
+	}
+
+	return forecast
+}
+
+// CashFlowForecast is the forecast for each of an account's cards.
+type CashFlowForecast struct {
+	From  string          `json:"from"` // first day, 2006-01-02
+	Days  int             `json:"days"`
+	Cards []*CardForecast `json:"cards"`
+}
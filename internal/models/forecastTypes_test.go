@@ -0,0 +1,149 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDetectRecurring(t *testing.T) {
+	now := time.Date(2024, 6, 20, 12, 0, 0, 0, time.UTC)
+	at := func(s string) time.Time {
+		v, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			t.Fatalf("bad date %q: %v", s, err)
+		}
+		return v
+	}
+	payments := func(cardID uint, incoming bool, counterparty string, amounts []int64, dates ...string) []*CardMovement {
+		var movements []*CardMovement
+		for i, d := range dates {
+			movements = append(movements, &CardMovement{
+				CardID:       cardID,
+				Incoming:     incoming,
+				Counterparty: counterparty,
+				Amount:       NewMoney(amounts[i%len(amounts)], "KZT"),
+				At:           at(d),
+			})
+		}
+		return movements
+	}
+
+	tests := []struct {
+		name          string
+		movements     []*CardMovement
+		wantFrequency string // empty when nothing should be detected
+		wantAmount    int64
+	}{
+		{
+			name:          "monthly rent",
+			movements:     payments(1, false, "landlord", []int64{20000000}, "2024-03-05", "2024-04-05", "2024-05-06", "2024-06-05"),
+			wantFrequency: FrequencyMonthly,
+			wantAmount:    20000000,
+		},
+		{
+			name:          "monthly salary over February",
+			movements:     payments(1, true, "employer", []int64{50000000}, "2024-01-31", "2024-02-29", "2024-03-31", "2024-04-30", "2024-05-31"),
+			wantFrequency: FrequencyMonthly,
+			wantAmount:    50000000,
+		},
+		{
+			name:          "weekly, amounts vary a little",
+			movements:     payments(2, false, "grocer", []int64{1000000, 1100000, 900000}, "2024-05-30", "2024-06-06", "2024-06-13", "2024-06-19"),
+			wantFrequency: FrequencyWeekly,
+			wantAmount:    1000000,
+		},
+		{
+			name:      "only twice",
+			movements: payments(1, false, "gym", []int64{1500000}, "2024-05-05", "2024-06-05"),
+		},
+		{
+			name:      "irregular gaps",
+			movements: payments(1, false, "shop", []int64{500000}, "2024-04-01", "2024-04-03", "2024-05-20", "2024-06-15"),
+		},
+		{
+			name:      "amounts too far apart",
+			movements: payments(1, false, "utility", []int64{1000000, 2000000}, "2024-03-10", "2024-04-10", "2024-05-10", "2024-06-10"),
+		},
+		{
+			name:      "stopped: overdue by more than half a month",
+			movements: payments(1, false, "streaming", []int64{399000}, "2024-01-01", "2024-02-01", "2024-03-01", "2024-04-01"),
+		},
+		{
+			name:      "no counterparty",
+			movements: payments(1, false, "", []int64{100000}, "2024-03-05", "2024-04-05", "2024-05-05", "2024-06-05"),
+		},
+	}
+
+	for _, tt := range tests {
+		got := DetectRecurring(tt.movements, now)
+
+		if tt.wantFrequency == "" {
+			if len(got) != 0 {
+				t.Errorf("%s: detected %+v; expected nothing", tt.name, got[0])
+			}
+			continue
+		}
+		if len(got) != 1 {
+			t.Errorf("%s: detected %d payments; expected 1", tt.name, len(got))
+			continue
+		}
+
+		p, last := got[0], tt.movements[len(tt.movements)-1]
+		if p.Frequency != tt.wantFrequency || p.Amount.Amount != tt.wantAmount {
+			t.Errorf("%s: got %v %v; expected %v %v", tt.name, p.Frequency, p.Amount.Amount, tt.wantFrequency, tt.wantAmount)
+		}
+		if p.Occurrences != len(tt.movements) || !p.LastAt.Equal(last.At) || p.Counterparty != last.Counterparty {
+			t.Errorf("%s: unexpected payment %+v", tt.name, p)
+		}
+	}
+}
+
+func TestDetectRecurringKeepsCounterpartiesApart(t *testing.T) {
+	now := time.Date(2024, 6, 20, 0, 0, 0, 0, time.UTC)
+	var movements []*CardMovement
+	// out of order, interleaved, and the same counterparty on two cards
+	for _, month := range []time.Month{6, 3, 5, 4} {
+		for _, card := range []uint{1, 2} {
+			movements = append(movements, &CardMovement{
+				CardID:       card,
+				Counterparty: "telecom",
+				Amount:       NewMoney(int64(card)*100000, "KZT"),
+				At:           time.Date(2024, month, 10, 0, 0, 0, 0, time.UTC),
+			})
+		}
+	}
+
+	got := DetectRecurring(movements, now)
+	if len(got) != 2 {
+		t.Fatalf("detected %d payments; expected one per card", len(got))
+	}
+	for _, p := range got {
+		if p.Amount.Amount != int64(p.CardID)*100000 || p.Occurrences != 4 || p.LastAt.Month() != 6 {
+			t.Errorf("unexpected payment %+v", p)
+		}
+	}
+}
+
+func TestRecurringEvents(t *testing.T) {
+	p := &RecurringPayment{
+		CardID:    1,
+		Amount:    NewMoney(20000000, "KZT"),
+		Frequency: FrequencyMonthly,
+		LastAt:    time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC),
+	}
+
+	events := RecurringEvents(p, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC))
+	want := []time.Time{
+		time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, 4, 30, 0, 0, 0, 0, time.UTC),
+	}
+	if len(events) != len(want) {
+		t.Fatalf("got %d events; expected %d", len(events), len(want))
+	}
+	for i, e := range events {
+		if !e.At.Equal(want[i]) || e.Amount.Amount != -20000000 {
+			t.Errorf("event %d: got %v %v; expected %v -20000000", i, e.At, e.Amount.Amount, want[i])
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package models
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SavingsGoal is an amount an account wants to have put aside by a deadline.
+// The money lives on a card: SharePercent of the card's balance counts
+// towards the goal, so one card can hold several goals. Contributions can be
+// made automatically by a scheduled transfer into the card.
+type SavingsGoal struct {
+	gorm.Model
+	AccountID      uint               `json:"-" gorm:"not null;index"`
+	Name           string             `json:"name" gorm:"size:100;not null"`
+	Target         Money              `json:"target" gorm:"embedded;embeddedPrefix:target_"`
+	Deadline       time.Time          `json:"deadline"`
+	CardID         uint               `json:"cardId" gorm:"not null;index"`
+	SharePercent   int                `json:"sharePercent" gorm:"not null;default:100"`
+	ContributionID *uint              `json:"contributionId,omitempty"` // the scheduled transfer paying into the card
+	Contribution   *ScheduledTransfer `json:"contribution,omitempty" gorm:"foreignKey:ContributionID"`
+}
+
+// AutoContributionRequest sets up a transfer into the goal's card every week
+// or month from another of the account's cards.
+type AutoContributionRequest struct {
+	FromCardID uint   `json:"fromCardID"`
+	Amount     Money  `json:"amount"`
+	Frequency  string `json:"frequency"` // weekly or monthly
+	StartAt    string `json:"startAt"`   // RFC 3339, or 2006-01-02 for midnight UTC; now when empty
+}
+
+type CreateSavingsGoalRequest struct {
+	Name             string                   `json:"name"`
+	Target           Money                    `json:"target"`
+	Deadline         string                   `json:"deadline"` // 2006-01-02
+	CardID           uint                     `json:"cardId"`
+	SharePercent     int                      `json:"sharePercent"` // defaults to 100
+	AutoContribution *AutoContributionRequest `json:"autoContribution"`
+}
+
+func NewSavingsGoal(accountID uint, req *CreateSavingsGoalRequest, now time.Time) (*SavingsGoal, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, fmt.Errorf("goal name is required")
+	}
+	if req.Target.Amount <= 0 {
+		return nil, fmt.Errorf("target amount must be positive")
+	}
+
+	deadline, err := time.Parse("2006-01-02", req.Deadline)
+	if err != nil {
+		return nil, fmt.Errorf("invalid deadline %q, expected YYYY-MM-DD", req.Deadline)
+	}
+	if !deadline.After(now) {
+		return nil, fmt.Errorf("deadline must be in the future")
+	}
+
+	share := req.SharePercent
+	if share == 0 {
+		share = 100
+	}
+	if share < 1 || share > 100 {
+		return nil, fmt.Errorf("share must be between 1 and 100 percent")
+	}
+
+	return &SavingsGoal{
+		AccountID:    accountID,
+		Name:         name,
+		Target:       req.Target,
+		Deadline:     deadline,
+		CardID:       req.CardID,
+		SharePercent: share,
+	}, nil
+}
+
+// SavingsGoalStatus is a goal with how far along it is. RequiredMonthly is
+// what still has to be saved each month to make the deadline.
+// ProjectedCompletion assumes saving continues at MonthlyRate: the goal's
+// share of the automatic contribution if there is an active one, otherwise
+// of the card's recent net inflow. It is nil when that rate is not positive.
+type SavingsGoalStatus struct {
+	Goal                *SavingsGoal `json:"goal"`
+	Progress            Money        `json:"progress"`
+	Remaining           Money        `json:"remaining"`
+	PercentComplete     int          `json:"percentComplete"`
+	MonthsLeft          int          `json:"monthsLeft"`
+	RequiredMonthly     Money        `json:"requiredMonthly"`
+	MonthlyRate         Money        `json:"monthlyRate"`
+	ProjectedCompletion *time.Time   `json:"projectedCompletion"`
+	OnTrack             bool         `json:"onTrack"`
+}
+
+// NewSavingsGoalStatus works out a goal's figures. cardBalance and
+// cardRate, what the card gains per month, must be in the goal's currency;
+// the goal's share is applied to both.
+func NewSavingsGoalStatus(goal *SavingsGoal, cardBalance, cardRate Money, now time.Time) *SavingsGoalStatus {
+	currency := goal.Target.Currency
+	monthlyRate := NewMoney(cardRate.Amount*int64(goal.SharePercent)/100, currency)
+
+	progress := NewMoney(cardBalance.Amount*int64(goal.SharePercent)/100, currency)
+	if progress.IsNegative() {
+		progress.Amount = 0
+	}
+	if progress.Cmp(goal.Target) > 0 {
+		progress = goal.Target
+	}
+	remaining := goal.Target.Sub(progress)
+
+	status := &SavingsGoalStatus{
+		Goal:            goal,
+		Progress:        progress,
+		Remaining:       remaining,
+		PercentComplete: int(progress.Amount * 100 / goal.Target.Amount),
+		MonthsLeft:      monthsBetween(now, goal.Deadline),
+		RequiredMonthly: NewMoney(0, currency),
+		MonthlyRate:     monthlyRate,
+	}
+
+	if remaining.IsZero() {
+		status.OnTrack = true
+		status.ProjectedCompletion = &now
+		return status
+	}
+
+	months := status.MonthsLeft
+	if months < 1 {
+		months = 1
+	}
+	status.RequiredMonthly = NewMoney((remaining.Amount+int64(months)-1)/int64(months), currency)
+
+	if monthlyRate.Amount > 0 {
+		needed := int((remaining.Amount + monthlyRate.Amount - 1) / monthlyRate.Amount)
+		projected := now.AddDate(0, needed, 0)
+		status.ProjectedCompletion = &projected
+		status.OnTrack = !projected.After(goal.Deadline)
+	}
+
+	return status
+}
+
+// MonthlyEquivalent is what a weekly or monthly contribution of amount comes
+// to per month, to the nearest minor unit.
+func MonthlyEquivalent(amount Money, frequency string) Money {
+	if frequency != FrequencyWeekly {
+		return amount
+	}
+
+	perMonth := new(big.Rat).SetFrac64(52, 12)
+	return ConvertMoney(amount, perMonth, amount.Currency)
+}
+
+// monthsBetween counts the whole months from now until deadline, at least 0.
+func monthsBetween(now, deadline time.Time) int {
+	months := 0
+	for !addMonthsClamped(now, months+1).After(deadline) {
+		months++
+	}
+	return months
+}
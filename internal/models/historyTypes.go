@@ -0,0 +1,66 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const (
+	HistorySortTime   = "time"
+	HistorySortAmount = "amount"
+)
+
+// HistoryPageRequest asks for one page of a card's history, ordered by
+// SortBy with ties broken by id. After is the cursor of the page before, nil
+// for the first page.
+type HistoryPageRequest struct {
+	SortBy    string // time (default) or amount, as the card saw it
+	Ascending bool   // newest or largest first by default
+	Limit     int
+	After     *HistoryCursor
+}
+
+// HistoryCursor marks the last transaction of a page by the values the page
+// was sorted on. It is handed out as an opaque string.
+type HistoryCursor struct {
+	SortBy    string     `json:"s"`
+	Ascending bool       `json:"a,omitempty"`
+	Time      *time.Time `json:"t,omitempty"`
+	Amount    *int64     `json:"m,omitempty"`
+	ID        uint       `json:"i"`
+}
+
+func (c *HistoryCursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// ParseHistoryCursor reads a cursor made by Encode.
+func ParseHistoryCursor(s string) (*HistoryCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+
+	cursor := new(HistoryCursor)
+	if err := json.Unmarshal(data, cursor); err != nil || cursor.ID == 0 {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	switch {
+	case cursor.SortBy == HistorySortTime && cursor.Time != nil:
+	case cursor.SortBy == HistorySortAmount && cursor.Amount != nil:
+	default:
+		return nil, fmt.Errorf("invalid cursor")
+	}
+
+	return cursor, nil
+}
+
+// HistoryPage is one page of a card's history. NextCursor is empty on the
+// last page.
+type HistoryPage struct {
+	Transactions []*Transaction `json:"transactions"`
+	NextCursor   string         `json:"nextCursor,omitempty"`
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+)
+
+func TestHistoryCursorRoundTrip(t *testing.T) {
+	at := time.Date(2024, 5, 2, 10, 30, 15, 123456789, time.FixedZone("ALMT", 5*3600))
+	amount := int64(-150000)
+
+	tests := []*HistoryCursor{
+		{SortBy: HistorySortTime, Time: &at, ID: 42},
+		{SortBy: HistorySortTime, Ascending: true, Time: &at, ID: 1},
+		{SortBy: HistorySortAmount, Amount: &amount, ID: 7},
+		{SortBy: HistorySortAmount, Ascending: true, Amount: new(int64), ID: 9},
+	}
+
+	for _, want := range tests {
+		got, err := ParseHistoryCursor(want.Encode())
+		if err != nil {
+			t.Errorf("%+v: unexpected error: %v", want, err)
+			continue
+		}
+		if got.SortBy != want.SortBy || got.Ascending != want.Ascending || got.ID != want.ID {
+			t.Errorf("got %+v; expected %+v", got, want)
+		}
+		if want.Time != nil && (got.Time == nil || !got.Time.Equal(*want.Time)) {
+			t.Errorf("got time %v; expected %v", got.Time, *want.Time)
+		}
+		if want.Amount != nil && (got.Amount == nil || *got.Amount != *want.Amount) {
+			t.Errorf("got amount %v; expected %v", got.Amount, *want.Amount)
+		}
+	}
+}
+
+func TestParseHistoryCursorInvalid(t *testing.T) {
+	encode := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+
+	tests := []string{
+		"",
+		"not base64!",
+		encode("not json"),
+		encode(`{"s":"time","t":"2024-05-02T10:30:00Z"}`),
+		encode(`{"s":"time","m":100,"i":3}`),
+		encode(`{"s":"amount","t":"2024-05-02T10:30:00Z","i":3}`),
+		encode(`{"s":"size","m":100,"i":3}`),
+	}
+
+	for _, s := range tests {
+		if got, err := ParseHistoryCursor(s); err == nil {
+			t.Errorf("%q: expected an error; got %+v", s, got)
+		}
+	}
+}
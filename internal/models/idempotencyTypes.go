@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// IdempotencyKey remembers a client-supplied Idempotency-Key together with a
+// hash of the request it came with and, once finished, the response that was
+// sent, so a retried request can be answered without running it again.
+type IdempotencyKey struct {
+	ID             uint      `gorm:"primaryKey"`
+	AccountID      uint      `gorm:"not null;uniqueIndex:idx_idempotency_keys_account_key"`
+	Key            string    `gorm:"not null;size:255;uniqueIndex:idx_idempotency_keys_account_key"`
+	RequestHash    string    `gorm:"not null;size:64"`
+	ResponseStatus int       `gorm:"not null;default:0"` // 0 while the first request is still running
+	ResponseBody   []byte
+	CreatedAt      time.Time
+	ExpiresAt      time.Time `gorm:"not null"`
+}
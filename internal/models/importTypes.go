@@ -0,0 +1,241 @@
+package models
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StatementEntry is one transaction of a bank statement, as OFX and QFX
+// files list them.
+type StatementEntry struct {
+	FITID    string // the bank's id for it, unique within the bank account
+	BankID   string // BANKID of the statement's account, empty for credit cards
+	AcctID   string // ACCTID of the statement's account
+	Posted   string // DTPOSTED as written
+	Amount   string // TRNAMT as written, negative when money left
+	Currency string // CURDEF of the statement it belongs to
+	Name     string
+	Memo     string
+	CheckNum string
+}
+
+// ParseOFX reads the transactions of every statement in an OFX or QFX file,
+// each with the account its statement is for. Both the SGML flavour of OFX
+// 1.x, where most tags are never closed, and the XML of OFX 2.x are read the
+// same way: each tag's text is the value.
+func ParseOFX(r io.Reader) ([]*StatementEntry, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	body := string(data)
+	start := strings.Index(strings.ToUpper(body), "<OFX>")
+	if start < 0 {
+		return nil, fmt.Errorf("not an OFX file: no <OFX> element")
+	}
+	body = body[start:]
+
+	var entries []*StatementEntry
+	var current *StatementEntry
+	currency, bankID, acctID := "", "", ""
+	// inside BANKACCTFROM or CCACCTFROM, the statement's own account rather
+	// than the one a transfer went to
+	inAccount := false
+
+	for len(body) > 0 {
+		open := strings.IndexByte(body, '<')
+		if open < 0 {
+			break
+		}
+		end := strings.IndexByte(body[open:], '>')
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated tag near %q", truncate(body[open:], 20))
+		}
+		tag := strings.ToUpper(strings.TrimSpace(body[open+1 : open+end]))
+		body = body[open+end+1:]
+
+		next := strings.IndexByte(body, '<')
+		if next < 0 {
+			next = len(body)
+		}
+		value := strings.TrimSpace(html.UnescapeString(body[:next]))
+
+		switch {
+		case tag == "STMTRS" || tag == "CCSTMTRS":
+			currency, bankID, acctID = "", "", ""
+		case tag == "BANKACCTFROM" || tag == "CCACCTFROM":
+			inAccount = true
+		case tag == "/BANKACCTFROM" || tag == "/CCACCTFROM":
+			inAccount = false
+		case inAccount && current == nil && tag == "BANKID":
+			bankID = value
+		case inAccount && current == nil && tag == "ACCTID":
+			acctID = value
+		case tag == "STMTTRN":
+			current = &StatementEntry{Currency: currency, BankID: bankID, AcctID: acctID}
+		case tag == "/STMTTRN":
+			if current != nil {
+				entries = append(entries, current)
+			}
+			current = nil
+		case tag == "CURDEF":
+			currency = strings.ToUpper(value)
+		case current != nil && value != "":
+			switch tag {
+			case "FITID":
+				current.FITID = value
+			case "DTPOSTED":
+				current.Posted = value
+			case "TRNAMT":
+				current.Amount = value
+			case "NAME":
+				current.Name = value
+			case "MEMO":
+				current.Memo = value
+			case "CHECKNUM":
+				current.CheckNum = value
+			}
+		}
+	}
+	if current != nil {
+		return nil, fmt.Errorf("the file ends inside a transaction")
+	}
+
+	return entries, nil
+}
+
+// truncate cuts s to at most n characters.
+func truncate(s string, n int) string {
+	if runes := []rune(s); len(runes) > n {
+		return string(runes[:n])
+	}
+	return s
+}
+
+// ParseOFXTime reads an OFX date: YYYYMMDD, optionally followed by HHMMSS,
+// milliseconds and a zone such as [-5:EST] or [+5.5]. Times without a zone
+// are taken in loc.
+func ParseOFXTime(s string, loc *time.Location) (time.Time, error) {
+	value, zone, _ := strings.Cut(strings.TrimSpace(s), "[")
+	if dot := strings.IndexByte(value, '.'); dot >= 0 {
+		value = value[:dot]
+	}
+
+	layouts := map[int]string{8: "20060102", 12: "200601021504", 14: "20060102150405"}
+	layout, ok := layouts[len(value)]
+	if !ok || !isDigits(value) {
+		return time.Time{}, fmt.Errorf("invalid date %q", s)
+	}
+
+	if zone != "" {
+		offset, _, _ := strings.Cut(strings.TrimSuffix(zone, "]"), ":")
+		hours, err := strconv.ParseFloat(offset, 64)
+		if err != nil || hours < -14 || hours > 14 {
+			return time.Time{}, fmt.Errorf("invalid time zone in date %q", s)
+		}
+		loc = time.FixedZone("", int(hours*3600))
+	}
+
+	t, err := time.ParseInLocation(layout, value, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q", s)
+	}
+	return t, nil
+}
+
+// ImportStatementRequest says where entries read from a statement go.
+type ImportStatementRequest struct {
+	CardID         *uint // the card the statement is for; the account alone when nil
+	AffectsBalance bool  // change the card's balance too
+	CategoryID     *uint
+}
+
+// NewImportedEntry turns a statement entry into an income or expense entry
+// of accountID: money that left the bank account is an expense. Entries
+// dated after now are rejected, as pending ones may still change. FITIDs are
+// only unique within a bank account, so the entry keeps the account of its
+// statement, or the card it goes to when the statement names none.
+func NewImportedEntry(accountID uint, entry *StatementEntry, req *ImportStatementRequest, now time.Time, loc *time.Location) (*Transaction, error) {
+	if entry.FITID == "" {
+		return nil, fmt.Errorf("no FITID")
+	}
+
+	at, err := ParseOFXTime(entry.Posted, loc)
+	if err != nil {
+		return nil, err
+	}
+	if at.After(now) {
+		return nil, fmt.Errorf("dated in the future")
+	}
+
+	// some banks write decimal commas
+	raw := entry.Amount
+	if !strings.Contains(raw, ".") {
+		raw = strings.Replace(raw, ",", ".", 1)
+	}
+	amount, err := ParseMoney(raw, entry.Currency)
+	if err != nil {
+		return nil, err
+	}
+	if amount.IsZero() {
+		return nil, fmt.Errorf("zero amount")
+	}
+
+	kind := TransactionKindIncome
+	if amount.IsNegative() {
+		kind = TransactionKindExpense
+		amount = amount.Neg()
+	}
+	// without a CURDEF the amount is in the card's currency
+	amount.Currency = entry.Currency
+
+	counterparty, description := entry.Name, entry.Memo
+	if description == "" {
+		description = entry.Name
+	}
+
+	ts, err := NewManualEntry(accountID, &AddManualEntryRequest{
+		Kind:              kind,
+		Amount:            amount,
+		CategoryID:        req.CategoryID,
+		CardID:            req.CardID,
+		AffectsBalance:    req.AffectsBalance,
+		Description:       truncate(description, 500),
+		CounterpartyName:  truncate(counterparty, 200),
+		ExternalReference: truncate(entry.CheckNum, 100),
+	}, at)
+	if err != nil {
+		return nil, err
+	}
+	ts.FITID = entry.FITID
+	switch {
+	case entry.AcctID != "":
+		ts.FITAccount = truncate(entry.BankID+"/"+entry.AcctID, 100)
+	case req.CardID != nil:
+		ts.FITAccount = fmt.Sprintf("card:%d", *req.CardID)
+	}
+
+	return ts, nil
+}
+
+// ImportFailure is a statement entry that could not be imported.
+type ImportFailure struct {
+	FITID  string `json:"fitId,omitempty"`
+	Posted string `json:"posted,omitempty"`
+	Amount string `json:"amount,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// ImportResult sums up an import. Skipped entries were imported before.
+type ImportResult struct {
+	Imported int              `json:"imported"`
+	Skipped  int              `json:"skipped"`
+	Failed   int              `json:"failed"`
+	Entries  []*Transaction   `json:"entries"` // the ones imported now
+	Failures []*ImportFailure `json:"failures"`
+}
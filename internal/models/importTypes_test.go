@@ -0,0 +1,172 @@
+package models
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const sgmlStatement = `OFXHEADER:100
+DATA:OFXSGML
+VERSION:102
+
+<OFX>
+<BANKMSGSRSV1><STMTTRNRS><STMTRS>
+<CURDEF>KZT
+<BANKACCTFROM><BANKID>190501<ACCTID>KZ12345<ACCTTYPE>CHECKING</BANKACCTFROM>
+<BANKTRANLIST>
+<STMTTRN><TRNTYPE>DEBIT<DTPOSTED>20240502120000[+5:ALMT]<TRNAMT>-4500.00<FITID>A1<NAME>Magnum &amp; Co<MEMO>groceries</STMTTRN>
+<STMTTRN><TRNTYPE>XFER<DTPOSTED>20240503<TRNAMT>15000,50<FITID>A2<CHECKNUM>77
+<BANKACCTTO><BANKID>999<ACCTID>OTHER</BANKACCTTO></STMTTRN>
+</BANKTRANLIST>
+</STMTRS></STMTTRNRS></BANKMSGSRSV1>
+<CREDITCARDMSGSRSV1><CCSTMTTRNRS><CCSTMTRS>
+<CURDEF>USD
+<CCACCTFROM><ACCTID>4400-1234</CCACCTFROM>
+<BANKTRANLIST>
+<STMTTRN><TRNTYPE>DEBIT<DTPOSTED>20240504<TRNAMT>-12.99<FITID>A1<NAME>Streaming</STMTTRN>
+</BANKTRANLIST>
+</CCSTMTRS></CCSTMTTRNRS></CREDITCARDMSGSRSV1>
+</OFX>`
+
+const xmlStatement = `<?xml version="1.0" encoding="UTF-8"?>
+<?OFX OFXHEADER="200" VERSION="220"?>
+<OFX>
+  <BANKMSGSRSV1><STMTTRNRS><STMTRS>
+    <CURDEF>EUR</CURDEF>
+    <BANKACCTFROM><BANKID>DE01</BANKID><ACCTID>777</ACCTID><ACCTTYPE>SAVINGS</ACCTTYPE></BANKACCTFROM>
+    <BANKTRANLIST>
+      <STMTTRN><TRNTYPE>CREDIT</TRNTYPE><DTPOSTED>20240601</DTPOSTED><TRNAMT>100.00</TRNAMT><FITID>X9</FITID><NAME>Salary</NAME></STMTTRN>
+    </BANKTRANLIST>
+  </STMTRS></STMTTRNRS></BANKMSGSRSV1>
+</OFX>`
+
+func TestParseOFX(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		want []StatementEntry
+	}{
+		{
+			name: "SGML with a bank and a credit card statement",
+			file: sgmlStatement,
+			want: []StatementEntry{
+				{FITID: "A1", BankID: "190501", AcctID: "KZ12345", Posted: "20240502120000[+5:ALMT]", Amount: "-4500.00", Currency: "KZT", Name: "Magnum & Co", Memo: "groceries"},
+				{FITID: "A2", BankID: "190501", AcctID: "KZ12345", Posted: "20240503", Amount: "15000,50", Currency: "KZT", CheckNum: "77"},
+				{FITID: "A1", AcctID: "4400-1234", Posted: "20240504", Amount: "-12.99", Currency: "USD", Name: "Streaming"},
+			},
+		},
+		{
+			name: "XML",
+			file: xmlStatement,
+			want: []StatementEntry{
+				{FITID: "X9", BankID: "DE01", AcctID: "777", Posted: "20240601", Amount: "100.00", Currency: "EUR", Name: "Salary"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseOFX(strings.NewReader(tt.file))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: got %d entries; expected %d", tt.name, len(got), len(tt.want))
+			continue
+		}
+		for i, entry := range got {
+			if *entry != tt.want[i] {
+				t.Errorf("%s: entry %d = %+v; expected %+v", tt.name, i, *entry, tt.want[i])
+			}
+		}
+	}
+}
+
+func TestParseOFXInvalid(t *testing.T) {
+	tests := []string{
+		"",
+		"date,amount\n2024-05-02,-4500",
+		"<OFX><STMTTRN><FITID>A1<TRNAMT>-1.00",
+		"<OFX><STMTTRN><FITID>A1</STMTTRN><BROKEN",
+	}
+
+	for _, file := range tests {
+		if got, err := ParseOFX(strings.NewReader(file)); err == nil {
+			t.Errorf("%q: expected an error; got %d entries", file, len(got))
+		}
+	}
+}
+
+func TestParseOFXTime(t *testing.T) {
+	almaty := time.FixedZone("ALMT", 5*3600)
+
+	tests := []struct {
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{value: "20240502", want: time.Date(2024, 5, 2, 0, 0, 0, 0, almaty)},
+		{value: "202405021230", want: time.Date(2024, 5, 2, 12, 30, 0, 0, almaty)},
+		{value: "20240502123045", want: time.Date(2024, 5, 2, 12, 30, 45, 0, almaty)},
+		{value: "20240502123045.123", want: time.Date(2024, 5, 2, 12, 30, 45, 0, almaty)},
+		{value: "20240502123045.123[-5:EST]", want: time.Date(2024, 5, 2, 17, 30, 45, 0, time.UTC)},
+		{value: "20240502120000[+5.5]", want: time.Date(2024, 5, 2, 6, 30, 0, 0, time.UTC)},
+		{value: "20240502[0:GMT]", want: time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC)},
+		{value: " 20240502 ", want: time.Date(2024, 5, 2, 0, 0, 0, 0, almaty)},
+		{value: "", wantErr: true},
+		{value: "2024-05-02", wantErr: true},
+		{value: "202405", wantErr: true},
+		{value: "20241302", wantErr: true},
+		{value: "20240502[+15]", wantErr: true},
+		{value: "20240502[EST]", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseOFXTime(tt.value, almaty)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%q: expected an error; got %v", tt.value, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tt.value, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("%q = %v; expected %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestNewImportedEntryBankAccount(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	cardID := uint(12)
+	entry := func(bankID, acctID string) *StatementEntry {
+		return &StatementEntry{FITID: "A1", BankID: bankID, AcctID: acctID, Posted: "20240502", Amount: "-10.00", Currency: "KZT"}
+	}
+
+	tests := []struct {
+		name  string
+		entry *StatementEntry
+		card  *uint
+		want  string
+	}{
+		{name: "bank account", entry: entry("190501", "KZ12345"), card: &cardID, want: "190501/KZ12345"},
+		{name: "credit card account", entry: entry("", "4400-1234"), want: "/4400-1234"},
+		{name: "no account, a card", entry: entry("", ""), card: &cardID, want: "card:12"},
+		{name: "no account, no card", entry: entry("", ""), want: ""},
+	}
+
+	for _, tt := range tests {
+		ts, err := NewImportedEntry(1, tt.entry, &ImportStatementRequest{CardID: tt.card}, now, time.UTC)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+		if ts.FITID != "A1" || ts.FITAccount != tt.want {
+			t.Errorf("%s: got %q %q; expected \"A1\" %q", tt.name, ts.FITID, ts.FITAccount, tt.want)
+		}
+	}
+}
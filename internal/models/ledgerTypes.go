@@ -0,0 +1,130 @@
+package models
+
+import "time"
+
+const (
+	LedgerDebit  = "debit"
+	LedgerCredit = "credit"
+
+	LedgerKindOpening  = "opening"
+	LedgerKindTransfer = "transfer"
+	LedgerKindRefund   = "refund"
+	LedgerKindManual   = "manual"
+)
+
+// LedgerEntry is one immutable side of a money movement. A card's balance is
+// the sum of its credits minus the sum of its debits. Entries with no CardID
+// belong to the outside world: money that entered the system when a card was
+// opened, the exchange desk a cross-currency transfer passes through, or the
+// shop or employer on the other side of an entry made by hand.
+// Every movement writes entries that net to zero in each currency.
+type LedgerEntry struct {
+	ID            uint      `json:"id" gorm:"primaryKey"`
+	TransactionID *uint     `json:"transactionId,omitempty" gorm:"index"`
+	CardID        *uint     `json:"cardId,omitempty" gorm:"index:idx_ledger_entries_card_effective"`
+	Kind          string    `json:"kind" gorm:"size:16;not null"`
+	Direction     string    `json:"direction" gorm:"size:6;not null"`
+	Amount        Money     `json:"amount" gorm:"embedded;embeddedPrefix:amount_"`
+	EffectiveAt   time.Time `json:"effectiveAt" gorm:"not null;index:idx_ledger_entries_card_effective"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+// CardDrift is a card whose stored balance disagrees with its ledger.
+type CardDrift struct {
+	CardID        uint  `json:"cardId"`
+	StoredBalance Money `json:"storedBalance"`
+	LedgerBalance Money `json:"ledgerBalance"`
+}
+
+// UnbalancedTransaction is a transaction whose entries do not net to zero.
+type UnbalancedTransaction struct {
+	TransactionID uint  `json:"transactionId"`
+	Imbalance     Money `json:"imbalance"`
+}
+
+type ReconciliationReport struct {
+	CheckedAt              time.Time                `json:"checkedAt"`
+	Drifts                 []*CardDrift             `json:"drifts"`
+	UnbalancedTransactions []*UnbalancedTransaction `json:"unbalancedTransactions"`
+}
+
+func newLedgerEntry(kind, direction string, transactionID, cardID *uint, amount Money, at time.Time) *LedgerEntry {
+	return &LedgerEntry{
+		TransactionID: transactionID,
+		CardID:        cardID,
+		Kind:          kind,
+		Direction:     direction,
+		Amount:        amount,
+		EffectiveAt:   at,
+	}
+}
+
+// NewTransferEntries debits the sender and credits the receiver of a recorded
+// transaction. A cross-currency transfer goes through the outside world so
+// that each currency still balances on its own.
+func NewTransferEntries(ts *Transaction) []*LedgerEntry {
+	id, from, to := ts.ID, *ts.FromCardID, *ts.ToCardID
+
+	kind := LedgerKindTransfer
+	if ts.Kind == TransactionKindRefund {
+		kind = LedgerKindRefund
+	}
+
+	entries := []*LedgerEntry{
+		newLedgerEntry(kind, LedgerDebit, &id, &from, ts.TransactionAmount, ts.TransactionTime),
+	}
+
+	if !ts.TransactionAmount.SameCurrency(ts.ReceivedAmount) {
+		entries = append(entries,
+			newLedgerEntry(kind, LedgerCredit, &id, nil, ts.TransactionAmount, ts.TransactionTime),
+			newLedgerEntry(kind, LedgerDebit, &id, nil, ts.ReceivedAmount, ts.TransactionTime),
+		)
+	}
+
+	return append(entries,
+		newLedgerEntry(kind, LedgerCredit, &id, &to, ts.ReceivedAmount, ts.TransactionTime),
+	)
+}
+
+// NewManualEntries moves an income or expense entry that affects its card's
+// balance between the card and the outside world. reverse undoes an entry
+// that is being deleted.
+func NewManualEntries(ts *Transaction, reverse bool) []*LedgerEntry {
+	id, card := ts.ID, *ts.ManualCardID()
+
+	cardSide, worldSide := LedgerCredit, LedgerDebit
+	if ts.Kind == TransactionKindExpense {
+		cardSide, worldSide = LedgerDebit, LedgerCredit
+	}
+	at := ts.TransactionTime
+	if reverse {
+		cardSide, worldSide = worldSide, cardSide
+		at = time.Now()
+	}
+
+	return []*LedgerEntry{
+		newLedgerEntry(LedgerKindManual, cardSide, &id, &card, ts.TransactionAmount, at),
+		newLedgerEntry(LedgerKindManual, worldSide, &id, nil, ts.TransactionAmount, at),
+	}
+}
+
+// NewOpeningEntries brings the balance a card was registered with into the
+// ledger. A zero balance needs no entries.
+func NewOpeningEntries(card *Card) []*LedgerEntry {
+	if card.CardBalance.IsZero() {
+		return nil
+	}
+
+	cardSide, worldSide := LedgerCredit, LedgerDebit
+	amount := card.CardBalance
+	if amount.IsNegative() {
+		cardSide, worldSide = LedgerDebit, LedgerCredit
+		amount = amount.Neg()
+	}
+
+	id := card.ID
+	return []*LedgerEntry{
+		newLedgerEntry(LedgerKindOpening, cardSide, nil, &id, amount, card.CreatedAt),
+		newLedgerEntry(LedgerKindOpening, worldSide, nil, nil, amount, card.CreatedAt),
+	}
+}
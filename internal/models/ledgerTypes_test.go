@@ -0,0 +1,175 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+// ledgerNets sums entries as the ledger does: credits minus debits, per
+// currency and per card, with 0 standing for the outside world.
+func ledgerNets(entries []*LedgerEntry) (byCurrency map[string]int64, byCard map[uint]int64) {
+	byCurrency, byCard = map[string]int64{}, map[uint]int64{}
+	for _, e := range entries {
+		amount := e.Amount.Amount
+		if e.Direction == LedgerDebit {
+			amount = -amount
+		}
+		byCurrency[e.Amount.Currency] += amount
+
+		card := uint(0)
+		if e.CardID != nil {
+			card = *e.CardID
+		}
+		byCard[card] += amount
+	}
+	return byCurrency, byCard
+}
+
+func uintPtr(v uint) *uint {
+	return &v
+}
+
+func TestNewTransferEntries(t *testing.T) {
+	at := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		ts          *Transaction
+		wantEntries int
+		wantKind    string
+		wantCards   map[uint]int64
+	}{
+		{
+			name: "same currency",
+			ts: &Transaction{
+				FromCardID: uintPtr(1), ToCardID: uintPtr(2), Kind: TransactionKindTransfer,
+				TransactionAmount: NewMoney(5000, "KZT"), ReceivedAmount: NewMoney(5000, "KZT"),
+			},
+			wantEntries: 2,
+			wantKind:    LedgerKindTransfer,
+			wantCards:   map[uint]int64{1: -5000, 2: 5000},
+		},
+		{
+			name: "cross currency goes through the outside world",
+			ts: &Transaction{
+				FromCardID: uintPtr(1), ToCardID: uintPtr(2), Kind: TransactionKindTransfer,
+				TransactionAmount: NewMoney(1000, "USD"), ReceivedAmount: NewMoney(470150, "KZT"),
+			},
+			wantEntries: 4,
+			wantKind:    LedgerKindTransfer,
+			wantCards:   map[uint]int64{1: -1000, 2: 470150, 0: 1000 - 470150},
+		},
+		{
+			name: "refund",
+			ts: &Transaction{
+				FromCardID: uintPtr(2), ToCardID: uintPtr(1), Kind: TransactionKindRefund,
+				TransactionAmount: NewMoney(250, "EUR"), ReceivedAmount: NewMoney(250, "EUR"),
+			},
+			wantEntries: 2,
+			wantKind:    LedgerKindRefund,
+			wantCards:   map[uint]int64{2: -250, 1: 250},
+		},
+	}
+
+	for _, tt := range tests {
+		tt.ts.ID = 7
+		tt.ts.TransactionTime = at
+		entries := NewTransferEntries(tt.ts)
+
+		if len(entries) != tt.wantEntries {
+			t.Errorf("%s: got %d entries; expected %d", tt.name, len(entries), tt.wantEntries)
+		}
+		for _, e := range entries {
+			if e.Kind != tt.wantKind || e.TransactionID == nil || *e.TransactionID != 7 || !e.EffectiveAt.Equal(at) {
+				t.Errorf("%s: unexpected entry %+v", tt.name, e)
+			}
+		}
+
+		byCurrency, byCard := ledgerNets(entries)
+		for currency, net := range byCurrency {
+			if net != 0 {
+				t.Errorf("%s: %s nets to %d; expected 0", tt.name, currency, net)
+			}
+		}
+		for card, want := range tt.wantCards {
+			if byCard[card] != want {
+				t.Errorf("%s: card %d moved %d; expected %d", tt.name, card, byCard[card], want)
+			}
+		}
+	}
+}
+
+func TestNewManualEntries(t *testing.T) {
+	at := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		kind    string
+		reverse bool
+		want    int64 // what the card gains
+	}{
+		{"income", TransactionKindIncome, false, 1500},
+		{"expense", TransactionKindExpense, false, -1500},
+		{"income deleted", TransactionKindIncome, true, -1500},
+		{"expense deleted", TransactionKindExpense, true, 1500},
+	}
+
+	for _, tt := range tests {
+		ts, err := NewManualEntry(3, &AddManualEntryRequest{
+			Kind:           tt.kind,
+			Amount:         NewMoney(1500, "KZT"),
+			CardID:         uintPtr(9),
+			AffectsBalance: true,
+		}, at)
+		if err != nil {
+			t.Fatalf("%s: NewManualEntry: %v", tt.name, err)
+		}
+		ts.ID = 11
+
+		entries := NewManualEntries(ts, tt.reverse)
+		if len(entries) != 2 {
+			t.Fatalf("%s: got %d entries; expected 2", tt.name, len(entries))
+		}
+
+		byCurrency, byCard := ledgerNets(entries)
+		if byCurrency["KZT"] != 0 {
+			t.Errorf("%s: KZT nets to %d; expected 0", tt.name, byCurrency["KZT"])
+		}
+		if byCard[9] != tt.want {
+			t.Errorf("%s: card moved %d; expected %d", tt.name, byCard[9], tt.want)
+		}
+		if !tt.reverse && !entries[0].EffectiveAt.Equal(at) {
+			t.Errorf("%s: entry effective at %v; expected %v", tt.name, entries[0].EffectiveAt, at)
+		}
+	}
+}
+
+func TestNewOpeningEntries(t *testing.T) {
+	tests := []struct {
+		balance     int64
+		wantEntries int
+	}{
+		{0, 0},
+		{10000, 2},
+		{-2500, 2},
+	}
+
+	for _, tt := range tests {
+		card := &Card{CardBalance: NewMoney(tt.balance, "USD")}
+		card.ID = 4
+
+		entries := NewOpeningEntries(card)
+		if len(entries) != tt.wantEntries {
+			t.Errorf("balance %d: got %d entries; expected %d", tt.balance, len(entries), tt.wantEntries)
+			continue
+		}
+
+		byCurrency, byCard := ledgerNets(entries)
+		if byCurrency["USD"] != 0 {
+			t.Errorf("balance %d: USD nets to %d; expected 0", tt.balance, byCurrency["USD"])
+		}
+		if byCard[4] != tt.balance {
+			t.Errorf("balance %d: card opens with %d", tt.balance, byCard[4])
+		}
+	}
+}
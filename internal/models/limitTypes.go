@@ -0,0 +1,110 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TransferLimits caps what an account can send to other people's cards. All
+// four amounts share one currency; transfers in other currencies are
+// converted before they are compared. Accounts without a row use the
+// defaults from the environment.
+type TransferLimits struct {
+	gorm.Model
+	AccountID    uint  `json:"accountId" gorm:"not null;uniqueIndex"`
+	MinAmount    Money `json:"minAmount" gorm:"embedded;embeddedPrefix:min_amount_"`
+	MaxAmount    Money `json:"maxAmount" gorm:"embedded;embeddedPrefix:max_amount_"`
+	DailyLimit   Money `json:"dailyLimit" gorm:"embedded;embeddedPrefix:daily_limit_"`
+	MonthlyLimit Money `json:"monthlyLimit" gorm:"embedded;embeddedPrefix:monthly_limit_"`
+	// set by the account holder: larger transfers wait for confirmation, zero turns it off
+	ConfirmationThreshold Money `json:"confirmationThreshold" gorm:"embedded;embeddedPrefix:confirmation_threshold_"`
+}
+
+// Validate checks that the limits share a currency and make sense together.
+func (l *TransferLimits) Validate() error {
+	currency := l.MinAmount.Currency
+	for _, m := range []Money{l.MaxAmount, l.DailyLimit, l.MonthlyLimit, l.ConfirmationThreshold} {
+		if m.Currency != currency {
+			return fmt.Errorf("all limits must be in the same currency, got %v and %v", currency, m.Currency)
+		}
+	}
+
+	switch {
+	case l.MinAmount.IsNegative():
+		return fmt.Errorf("minimum amount cannot be negative")
+	case l.ConfirmationThreshold.IsNegative():
+		return fmt.Errorf("confirmation threshold cannot be negative")
+	case l.MaxAmount.Cmp(l.MinAmount) < 0:
+		return fmt.Errorf("maximum amount is below the minimum")
+	case l.DailyLimit.Cmp(l.MaxAmount) < 0:
+		return fmt.Errorf("daily limit is below the maximum amount")
+	case l.MonthlyLimit.Cmp(l.DailyLimit) < 0:
+		return fmt.Errorf("monthly limit is below the daily limit")
+	}
+
+	return nil
+}
+
+// UpdateTransferLimitsRequest changes only the limits it names.
+type UpdateTransferLimitsRequest struct {
+	MinAmount    *Money `json:"minAmount"`
+	MaxAmount    *Money `json:"maxAmount"`
+	DailyLimit   *Money `json:"dailyLimit"`
+	MonthlyLimit *Money `json:"monthlyLimit"`
+}
+
+type SetConfirmationThresholdRequest struct {
+	Amount Money `json:"amount"` // "0" turns confirmations off
+}
+
+// NeedsConfirmation reports whether a transfer of amount (in the limits'
+// currency) has to wait for the account holder to confirm it.
+func (l *TransferLimits) NeedsConfirmation(amount Money) bool {
+	return l.ConfirmationThreshold.Amount > 0 && amount.Cmp(l.ConfirmationThreshold) > 0
+}
+
+// TransferLimitsStatus is an account's limits with what is left of them.
+type TransferLimitsStatus struct {
+	Limits             *TransferLimits `json:"limits"`
+	UsedToday          Money           `json:"usedToday"`
+	UsedThisMonth      Money           `json:"usedThisMonth"`
+	RemainingToday     Money           `json:"remainingToday"`
+	RemainingThisMonth Money           `json:"remainingThisMonth"`
+}
+
+func NewTransferLimitsStatus(limits *TransferLimits, usedToday, usedThisMonth Money) *TransferLimitsStatus {
+	remaining := func(limit, used Money) Money {
+		left := limit.Sub(used)
+		if left.IsNegative() {
+			left.Amount = 0
+		}
+		return left
+	}
+
+	return &TransferLimitsStatus{
+		Limits:             limits,
+		UsedToday:          usedToday,
+		UsedThisMonth:      usedThisMonth,
+		RemainingToday:     remaining(limits.DailyLimit, usedToday),
+		RemainingThisMonth: remaining(limits.MonthlyLimit, usedThisMonth),
+	}
+}
+
+// TransferQuota is what a transfer to someone else's card is checked against
+// while it is being made, with the account locked: the daily and monthly
+// limits, counted from the start of the day and month it is made in.
+type TransferQuota struct {
+	Limits       *TransferLimits
+	StartOfDay   time.Time
+	StartOfMonth time.Time
+}
+
+func NewTransferQuota(limits *TransferLimits, now time.Time) *TransferQuota {
+	return &TransferQuota{
+		Limits:       limits,
+		StartOfDay:   time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()),
+		StartOfMonth: time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()),
+	}
+}
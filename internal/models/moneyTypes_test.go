@@ -0,0 +1,109 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		in       string
+		currency string
+		want     Money
+		wantErr  bool
+	}{
+		{in: "1500", currency: "KZT", want: Money{Amount: 150000, Currency: "KZT"}},
+		{in: "1500.25", currency: "usd", want: Money{Amount: 150025, Currency: "USD"}},
+		{in: "-3.5", currency: "EUR", want: Money{Amount: -350, Currency: "EUR"}},
+		{in: "+0.05", currency: "", want: Money{Amount: 5, Currency: DefaultCurrency}},
+		{in: ".25", currency: "KZT", want: Money{Amount: 25, Currency: "KZT"}},
+		{in: "7.", currency: "KZT", want: Money{Amount: 700, Currency: "KZT"}},
+		{in: "  12.30  ", currency: "KZT", want: Money{Amount: 1230, Currency: "KZT"}},
+		{in: "0", currency: "KZT", want: Money{Amount: 0, Currency: "KZT"}},
+		{in: "", wantErr: true},
+		{in: "-", wantErr: true},
+		{in: ".", wantErr: true},
+		{in: "1.234", wantErr: true},
+		{in: "1,50", wantErr: true},
+		{in: "1e3", wantErr: true},
+		{in: "--1", wantErr: true},
+		{in: "1.-5", wantErr: true},
+		{in: "99999999999999999999", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseMoney(tt.in, tt.currency)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseMoney(%q): expected an error; got %v", tt.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseMoney(%q): unexpected error: %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseMoney(%q) = %+v; expected %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyDecimal(t *testing.T) {
+	tests := []struct {
+		amount int64
+		want   string
+	}{
+		{0, "0.00"},
+		{5, "0.05"},
+		{-5, "-0.05"},
+		{150025, "1500.25"},
+		{-1205, "-12.05"},
+	}
+
+	for _, tt := range tests {
+		if got := NewMoney(tt.amount, "KZT").Decimal(); got != tt.want {
+			t.Errorf("Decimal of %d = %q; expected %q", tt.amount, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyJSON(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Money
+	}{
+		{`{"amount":"1500.25","currency":"usd"}`, Money{Amount: 150025, Currency: "USD"}},
+		{`{"amount":12.5,"currency":"EUR"}`, Money{Amount: 1250, Currency: "EUR"}},
+		{`"12.50"`, Money{Amount: 1250}},
+		{`5000`, Money{Amount: 500000}},
+		// never through float64: 0.1 + 0.2 style errors would show here
+		{`0.29`, Money{Amount: 29}},
+	}
+
+	for _, tt := range tests {
+		var got Money
+		if err := json.Unmarshal([]byte(tt.in), &got); err != nil {
+			t.Errorf("unmarshal %s: unexpected error: %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("unmarshal %s = %+v; expected %+v", tt.in, got, tt.want)
+		}
+	}
+
+	for _, in := range []string{`"1.999"`, `{"amount":"abc"}`, `true`} {
+		var got Money
+		if err := json.Unmarshal([]byte(in), &got); err == nil {
+			t.Errorf("unmarshal %s: expected an error; got %+v", in, got)
+		}
+	}
+
+	data, err := json.Marshal(NewMoney(-1205, "kzt"))
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if expected := `{"amount":"-12.05","currency":"KZT"}`; string(data) != expected {
+		t.Errorf("marshal = %s; expected %s", data, expected)
+	}
+}
@@ -0,0 +1,256 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	ReportIncome  = "income"
+	ReportExpense = "expense"
+)
+
+// ReportLine is money that came into or went out of an account, summed per
+// category, counterparty and currency. Income is transfers from other
+// people's cards and income entered by hand; expenses are what
+// CategorySpending counts. Refunds are netted against the side they reverse.
+type ReportLine struct {
+	Kind         string // ReportIncome or ReportExpense
+	CategoryID   *uint
+	Counterparty string // counterparty name, or the other card's number; empty when unknown
+	Amount       Money
+}
+
+// MaxReportYears is the longest period a report or statement can cover.
+const MaxReportYears = 5
+
+// ReportPeriod is the half-open range [From, To).
+type ReportPeriod struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// ParseReportPeriod reads the period of a report in loc: a month
+// ("2006-01"), or from and to dates ("2006-01-02", both included) at most
+// MaxReportYears apart. With nothing given it is the current month.
+func ParseReportPeriod(month, from, to string, now time.Time, loc *time.Location) (ReportPeriod, error) {
+	if month != "" {
+		if from != "" || to != "" {
+			return ReportPeriod{}, fmt.Errorf("give either a month or from and to dates")
+		}
+		start, err := time.ParseInLocation(BudgetMonthLayout, month, loc)
+		if err != nil {
+			return ReportPeriod{}, fmt.Errorf("invalid month %q, expected YYYY-MM", month)
+		}
+		return ReportPeriod{From: start, To: start.AddDate(0, 1, 0)}, nil
+	}
+
+	if from == "" && to == "" {
+		now = now.In(loc)
+		start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, loc)
+		return ReportPeriod{From: start, To: start.AddDate(0, 1, 0)}, nil
+	}
+	if from == "" || to == "" {
+		return ReportPeriod{}, fmt.Errorf("from and to must be given together")
+	}
+
+	start, err := time.ParseInLocation("2006-01-02", from, loc)
+	if err != nil {
+		return ReportPeriod{}, fmt.Errorf("invalid from date %q, expected YYYY-MM-DD", from)
+	}
+	end, err := time.ParseInLocation("2006-01-02", to, loc)
+	if err != nil {
+		return ReportPeriod{}, fmt.Errorf("invalid to date %q, expected YYYY-MM-DD", to)
+	}
+	if end.Before(start) {
+		return ReportPeriod{}, fmt.Errorf("to date is before from date")
+	}
+	end = end.AddDate(0, 0, 1)
+	if end.After(start.AddDate(MaxReportYears, 0, 0)) {
+		return ReportPeriod{}, fmt.Errorf("a period can cover at most %d years", MaxReportYears)
+	}
+
+	return ReportPeriod{From: start, To: end}, nil
+}
+
+// Previous is the period of the same length right before p. Whole calendar
+// months are compared with the same number of months before them, other
+// periods with the same number of days.
+func (p ReportPeriod) Previous() ReportPeriod {
+	if months, ok := wholeMonths(p); ok {
+		return ReportPeriod{From: p.From.AddDate(0, -months, 0), To: p.From}
+	}
+
+	days := calendarDays(p.From, p.To)
+	return ReportPeriod{From: p.From.AddDate(0, 0, -days), To: p.From}
+}
+
+// calendarDays counts the days from `from` up to `to`, a part of a day
+// counting as a whole one. Dates are compared, not durations, so days
+// shortened or lengthened by a clock change still count once.
+func calendarDays(from, to time.Time) int {
+	a := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	b := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC)
+
+	days := int(b.Sub(a) / (24 * time.Hour))
+	if from.AddDate(0, 0, days).Before(to) {
+		days++
+	}
+	return days
+}
+
+// wholeMonths reports how many calendar months p spans, if it starts and
+// ends on the first of a month.
+func wholeMonths(p ReportPeriod) (int, bool) {
+	if p.From.Day() != 1 || p.To.Day() != 1 {
+		return 0, false
+	}
+	for _, t := range []time.Time{p.From, p.To} {
+		if t.Hour() != 0 || t.Minute() != 0 || t.Second() != 0 || t.Nanosecond() != 0 {
+			return 0, false
+		}
+	}
+
+	months := (p.To.Year()-p.From.Year())*12 + int(p.To.Month()-p.From.Month())
+	return months, months > 0
+}
+
+// CategoryBreakdown is the income and expenses of one category; a nil
+// CategoryID stands for uncategorized money.
+type CategoryBreakdown struct {
+	CategoryID *uint `json:"categoryId"`
+	Income     Money `json:"income"`
+	Expenses   Money `json:"expenses"`
+}
+
+// CounterpartyBreakdown is the income from and expenses to one counterparty.
+type CounterpartyBreakdown struct {
+	Counterparty string `json:"counterparty"`
+	Income       Money  `json:"income"`
+	Expenses     Money  `json:"expenses"`
+}
+
+// PeriodSummary is an account's income and expenses over one period, in a
+// single currency.
+type PeriodSummary struct {
+	ReportPeriod
+	Income         Money                    `json:"income"`
+	Expenses       Money                    `json:"expenses"`
+	Net            Money                    `json:"net"`
+	ByCategory     []*CategoryBreakdown     `json:"byCategory"`
+	ByCounterparty []*CounterpartyBreakdown `json:"byCounterparty"`
+}
+
+// NewPeriodSummary totals lines that are already in currency. Breakdowns
+// are sorted by expenses, then income, largest first.
+func NewPeriodSummary(period ReportPeriod, currency string, lines []*ReportLine) *PeriodSummary {
+	zero := NewMoney(0, currency)
+	summary := &PeriodSummary{
+		ReportPeriod:   period,
+		Income:         zero,
+		Expenses:       zero,
+		ByCategory:     []*CategoryBreakdown{},
+		ByCounterparty: []*CounterpartyBreakdown{},
+	}
+
+	categories := make(map[uint]*CategoryBreakdown)
+	var uncategorized *CategoryBreakdown
+	counterparties := make(map[string]*CounterpartyBreakdown)
+
+	for _, line := range lines {
+		var category *CategoryBreakdown
+		if line.CategoryID == nil {
+			if uncategorized == nil {
+				uncategorized = &CategoryBreakdown{Income: zero, Expenses: zero}
+				summary.ByCategory = append(summary.ByCategory, uncategorized)
+			}
+			category = uncategorized
+		} else if category = categories[*line.CategoryID]; category == nil {
+			category = &CategoryBreakdown{CategoryID: line.CategoryID, Income: zero, Expenses: zero}
+			categories[*line.CategoryID] = category
+			summary.ByCategory = append(summary.ByCategory, category)
+		}
+
+		counterparty := counterparties[line.Counterparty]
+		if counterparty == nil {
+			counterparty = &CounterpartyBreakdown{Counterparty: line.Counterparty, Income: zero, Expenses: zero}
+			counterparties[line.Counterparty] = counterparty
+			summary.ByCounterparty = append(summary.ByCounterparty, counterparty)
+		}
+
+		if line.Kind == ReportIncome {
+			summary.Income = summary.Income.Add(line.Amount)
+			category.Income = category.Income.Add(line.Amount)
+			counterparty.Income = counterparty.Income.Add(line.Amount)
+		} else {
+			summary.Expenses = summary.Expenses.Add(line.Amount)
+			category.Expenses = category.Expenses.Add(line.Amount)
+			counterparty.Expenses = counterparty.Expenses.Add(line.Amount)
+		}
+	}
+	summary.Net = summary.Income.Sub(summary.Expenses)
+
+	sort.SliceStable(summary.ByCategory, func(i, j int) bool {
+		return largerFirst(summary.ByCategory[i].Expenses, summary.ByCategory[i].Income,
+			summary.ByCategory[j].Expenses, summary.ByCategory[j].Income)
+	})
+	sort.SliceStable(summary.ByCounterparty, func(i, j int) bool {
+		return largerFirst(summary.ByCounterparty[i].Expenses, summary.ByCounterparty[i].Income,
+			summary.ByCounterparty[j].Expenses, summary.ByCounterparty[j].Income)
+	})
+
+	return summary
+}
+
+func largerFirst(expensesA, incomeA, expensesB, incomeB Money) bool {
+	if c := expensesA.Cmp(expensesB); c != 0 {
+		return c > 0
+	}
+	return incomeA.Cmp(incomeB) > 0
+}
+
+// PeriodChange is how a period compares with the one before it. The
+// percentages are nil when the previous figure was zero.
+type PeriodChange struct {
+	Income          Money    `json:"income"`
+	Expenses        Money    `json:"expenses"`
+	Net             Money    `json:"net"`
+	IncomePercent   *float64 `json:"incomePercent"`
+	ExpensesPercent *float64 `json:"expensesPercent"`
+}
+
+func NewPeriodChange(current, previous *PeriodSummary) *PeriodChange {
+	return &PeriodChange{
+		Income:          current.Income.Sub(previous.Income),
+		Expenses:        current.Expenses.Sub(previous.Expenses),
+		Net:             current.Net.Sub(previous.Net),
+		IncomePercent:   percentChange(current.Income, previous.Income),
+		ExpensesPercent: percentChange(current.Expenses, previous.Expenses),
+	}
+}
+
+// percentChange is the change from previous to current in percent, rounded
+// to one decimal place.
+func percentChange(current, previous Money) *float64 {
+	if previous.Amount == 0 {
+		return nil
+	}
+
+	tenths := (current.Amount - previous.Amount) * 1000 / previous.Amount
+	if previous.Amount < 0 {
+		tenths = -tenths
+	}
+	percent := float64(tenths) / 10
+	return &percent
+}
+
+// SummaryReport is an account's income and expenses across all of its
+// cards over a period, compared with the period before.
+type SummaryReport struct {
+	Currency string         `json:"currency"`
+	Timezone string         `json:"timezone"`
+	Current  *PeriodSummary `json:"current"`
+	Previous *PeriodSummary `json:"previous"`
+	Change   *PeriodChange  `json:"change"`
+}
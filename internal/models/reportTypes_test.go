@@ -0,0 +1,92 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseReportPeriod(t *testing.T) {
+	loc := time.FixedZone("ALMT", 5*3600)
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	day := func(s string) time.Time {
+		v, _ := time.ParseInLocation("2006-01-02", s, loc)
+		return v
+	}
+
+	tests := []struct {
+		month, from, to string
+		wantFrom        string
+		wantTo          string
+		wantErr         bool
+	}{
+		{wantFrom: "2024-03-01", wantTo: "2024-04-01"},
+		{month: "2024-02", wantFrom: "2024-02-01", wantTo: "2024-03-01"},
+		{from: "2024-01-10", to: "2024-01-10", wantFrom: "2024-01-10", wantTo: "2024-01-11"},
+		{from: "2020-01-01", to: "2024-12-31", wantFrom: "2020-01-01", wantTo: "2025-01-01"},
+		{from: "2020-01-01", to: "2025-01-01", wantErr: true},
+		{from: "0001-01-01", to: "9999-12-31", wantErr: true},
+		{from: "2024-01-10", to: "2024-01-09", wantErr: true},
+		{from: "2024-01-10", wantErr: true},
+		{month: "2024-02", from: "2024-01-10", to: "2024-01-20", wantErr: true},
+		{month: "2024-13", wantErr: true},
+		{from: "10.01.2024", to: "2024-01-20", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseReportPeriod(tt.month, tt.from, tt.to, now, loc)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("month %q, from %q, to %q: expected an error; got %+v", tt.month, tt.from, tt.to, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("month %q, from %q, to %q: unexpected error: %v", tt.month, tt.from, tt.to, err)
+			continue
+		}
+		if !got.From.Equal(day(tt.wantFrom)) || !got.To.Equal(day(tt.wantTo)) {
+			t.Errorf("month %q, from %q, to %q = %v - %v; expected %s - %s", tt.month, tt.from, tt.to, got.From, got.To, tt.wantFrom, tt.wantTo)
+		}
+	}
+}
+
+func TestReportPeriodPrevious(t *testing.T) {
+	day := func(s string) time.Time {
+		v, _ := time.Parse("2006-01-02", s)
+		return v
+	}
+
+	tests := []struct {
+		from, to         string
+		wantFrom, wantTo string
+	}{
+		// whole months compare with as many months before
+		{"2024-03-01", "2024-04-01", "2024-02-01", "2024-03-01"},
+		{"2024-01-01", "2024-04-01", "2023-10-01", "2024-01-01"},
+		// other periods with as many days
+		{"2024-03-10", "2024-03-20", "2024-02-29", "2024-03-10"},
+		{"2024-03-01", "2024-03-15", "2024-02-16", "2024-03-01"},
+		// 1827 days, one leap day more than the five years before
+		{"2020-01-02", "2025-01-02", "2015-01-01", "2020-01-02"},
+	}
+
+	for _, tt := range tests {
+		got := ReportPeriod{From: day(tt.from), To: day(tt.to)}.Previous()
+		if !got.From.Equal(day(tt.wantFrom)) || !got.To.Equal(day(tt.wantTo)) {
+			t.Errorf("before %s - %s = %v - %v; expected %s - %s", tt.from, tt.to, got.From, got.To, tt.wantFrom, tt.wantTo)
+		}
+	}
+
+	// a day an hour short across a clock change still counts as a day
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("no time zone data")
+	}
+	period := ReportPeriod{
+		From: time.Date(2024, 3, 30, 0, 0, 0, 0, berlin),
+		To:   time.Date(2024, 4, 2, 0, 0, 0, 0, berlin),
+	}
+	if got := period.Previous(); !got.From.Equal(time.Date(2024, 3, 27, 0, 0, 0, 0, berlin)) {
+		t.Errorf("before %v - %v starts %v; expected 2024-03-27", period.From, period.To, got.From)
+	}
+}
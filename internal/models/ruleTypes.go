@@ -0,0 +1,227 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CategoryRule files an account's outgoing transactions automatically. All
+// of the conditions that are set must match. Rules run in Priority order
+// (lowest first): the first matching rule with a category sets it, and every
+// matching rule adds its tags.
+type CategoryRule struct {
+	gorm.Model
+	AccountID uint   `json:"-" gorm:"not null;index"`
+	Name      string `json:"name" gorm:"size:100"`
+	Priority  int    `json:"priority"`
+	Enabled   bool   `json:"enabled" gorm:"not null;default:true"`
+
+	// conditions
+	ToCardNumber        string `json:"toCardNumber,omitempty"`
+	MinAmount           Money  `json:"minAmount" gorm:"embedded;embeddedPrefix:min_amount_"` // zero means no bound;
+	MaxAmount           Money  `json:"maxAmount" gorm:"embedded;embeddedPrefix:max_amount_"` // only amounts in this currency match
+	DescriptionContains string `json:"descriptionContains,omitempty"` // case-insensitive
+	DescriptionRegex    string `json:"descriptionRegex,omitempty"`
+	Weekdays            []int  `json:"weekdays,omitempty" gorm:"serializer:json;type:jsonb"` // 0 is Sunday
+
+	// actions
+	CategoryID *uint    `json:"categoryId,omitempty"`
+	Tags       []string `json:"tags,omitempty" gorm:"serializer:json;type:jsonb"`
+
+	descriptionRegex *regexp.Regexp
+}
+
+// CategoryRuleRequest creates a rule or replaces all of an existing one.
+type CategoryRuleRequest struct {
+	Name                string   `json:"name"`
+	Priority            int      `json:"priority"`
+	Enabled             *bool    `json:"enabled"` // defaults to true
+	ToCardNumber        string   `json:"toCardNumber"`
+	MinAmount           *Money   `json:"minAmount"`
+	MaxAmount           *Money   `json:"maxAmount"`
+	DescriptionContains string   `json:"descriptionContains"`
+	DescriptionRegex    string   `json:"descriptionRegex"`
+	Weekdays            []int    `json:"weekdays"`
+	CategoryID          *uint    `json:"categoryId"`
+	Tags                []string `json:"tags"`
+}
+
+type ReapplyRulesRequest struct {
+	DryRun    bool `json:"dryRun"`    // report what would change without saving it
+	Overwrite bool `json:"overwrite"` // also recategorize transactions that already have a category
+}
+
+// RuleChange is what re-applying the rules does to one transaction.
+type RuleChange struct {
+	TransactionID uint     `json:"transactionId"`
+	OldCategoryID *uint    `json:"oldCategoryId"`
+	NewCategoryID *uint    `json:"newCategoryId"`
+	AddedTags     []string `json:"addedTags,omitempty"`
+}
+
+type ReapplyRulesResult struct {
+	DryRun  bool          `json:"dryRun"`
+	Checked int           `json:"checked"`
+	Changes []*RuleChange `json:"changes"`
+}
+
+// RuleInput is what rules look at in a transaction.
+type RuleInput struct {
+	ToCardNumber string
+	Amount       Money
+	Description  string
+	Time         time.Time
+}
+
+func NewCategoryRule(accountID uint, req *CategoryRuleRequest) (*CategoryRule, error) {
+	rule := &CategoryRule{
+		AccountID:           accountID,
+		Name:                strings.TrimSpace(req.Name),
+		Priority:            req.Priority,
+		Enabled:             req.Enabled == nil || *req.Enabled,
+		ToCardNumber:        strings.TrimSpace(req.ToCardNumber),
+		DescriptionContains: req.DescriptionContains,
+		DescriptionRegex:    req.DescriptionRegex,
+		Weekdays:            req.Weekdays,
+		CategoryID:          req.CategoryID,
+		Tags:                NormalizeTags(req.Tags),
+	}
+
+	// a range given in one currency applies to the other bound too
+	currency := ""
+	for _, bound := range []*Money{req.MinAmount, req.MaxAmount} {
+		if bound != nil && bound.Currency != "" {
+			currency = bound.Currency
+		}
+	}
+	if req.MinAmount != nil {
+		rule.MinAmount = NewMoney(req.MinAmount.Amount, currency)
+	}
+	if req.MaxAmount != nil {
+		rule.MaxAmount = NewMoney(req.MaxAmount.Amount, currency)
+	}
+	if rule.MinAmount.Currency == "" {
+		rule.MinAmount.Currency = rule.MaxAmount.Currency
+	}
+	if rule.MaxAmount.Currency == "" {
+		rule.MaxAmount.Currency = rule.MinAmount.Currency
+	}
+
+	if err := rule.Validate(); err != nil {
+		return nil, err
+	}
+
+	return rule, nil
+}
+
+// Validate checks the rule has something to match and something to do.
+func (r *CategoryRule) Validate() error {
+	hasAmount := !r.MinAmount.IsZero() || !r.MaxAmount.IsZero()
+
+	if r.ToCardNumber == "" && !hasAmount && r.DescriptionContains == "" && r.DescriptionRegex == "" && len(r.Weekdays) == 0 {
+		return fmt.Errorf("a rule needs at least one condition")
+	}
+	if r.CategoryID == nil && len(r.Tags) == 0 {
+		return fmt.Errorf("a rule must set a category or tags")
+	}
+
+	if hasAmount {
+		switch {
+		case r.MinAmount.IsNegative() || r.MaxAmount.IsNegative():
+			return fmt.Errorf("amount bounds cannot be negative")
+		case !r.MaxAmount.IsZero() && r.MaxAmount.Cmp(r.MinAmount) < 0:
+			return fmt.Errorf("maximum amount is below the minimum")
+		}
+	}
+
+	for _, day := range r.Weekdays {
+		if day < 0 || day > 6 {
+			return fmt.Errorf("weekday %d is out of range 0-6 (0 is Sunday)", day)
+		}
+	}
+
+	if r.DescriptionRegex != "" {
+		re, err := regexp.Compile(r.DescriptionRegex)
+		if err != nil {
+			return fmt.Errorf("invalid description regex: %v", err)
+		}
+		r.descriptionRegex = re
+	}
+
+	return nil
+}
+
+// Matches reports whether every condition of the rule holds for in.
+func (r *CategoryRule) Matches(in *RuleInput) bool {
+	if r.ToCardNumber != "" && r.ToCardNumber != in.ToCardNumber {
+		return false
+	}
+
+	if !r.MinAmount.IsZero() || !r.MaxAmount.IsZero() {
+		if !in.Amount.SameCurrency(r.MinAmount) {
+			return false
+		}
+		if in.Amount.Cmp(r.MinAmount) < 0 {
+			return false
+		}
+		if !r.MaxAmount.IsZero() && in.Amount.Cmp(r.MaxAmount) > 0 {
+			return false
+		}
+	}
+
+	if r.DescriptionContains != "" && !strings.Contains(strings.ToLower(in.Description), strings.ToLower(r.DescriptionContains)) {
+		return false
+	}
+
+	if r.DescriptionRegex != "" {
+		if r.descriptionRegex == nil {
+			re, err := regexp.Compile(r.DescriptionRegex)
+			if err != nil {
+				return false
+			}
+			r.descriptionRegex = re
+		}
+		if !r.descriptionRegex.MatchString(in.Description) {
+			return false
+		}
+	}
+
+	if len(r.Weekdays) > 0 {
+		weekday := int(in.Time.Weekday())
+		found := false
+		for _, day := range r.Weekdays {
+			if day == weekday {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ApplyRules runs the enabled rules, already in priority order, against in
+// and returns the category to set (nil if none matched) and the tags to add.
+func ApplyRules(rules []*CategoryRule, in *RuleInput) (*uint, []string) {
+	var categoryID *uint
+	var tags []string
+
+	for _, rule := range rules {
+		if !rule.Enabled || !rule.Matches(in) {
+			continue
+		}
+		if categoryID == nil {
+			categoryID = rule.CategoryID
+		}
+		tags = append(tags, rule.Tags...)
+	}
+
+	return categoryID, NormalizeTags(tags)
+}
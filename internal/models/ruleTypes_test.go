@@ -0,0 +1,141 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNewCategoryRule(t *testing.T) {
+	groceries := uint(3)
+	money := func(amount int64, currency string) *Money { return &Money{Amount: amount, Currency: currency} }
+
+	tests := []struct {
+		name    string
+		req     CategoryRuleRequest
+		wantMin Money
+		wantMax Money
+		wantErr bool
+	}{
+		{name: "min only", req: CategoryRuleRequest{MinAmount: money(1000, "USD"), CategoryID: &groceries}, wantMin: NewMoney(1000, "USD"), wantMax: NewMoney(0, "USD")},
+		{name: "max only", req: CategoryRuleRequest{MaxAmount: money(500000, ""), CategoryID: &groceries}, wantMin: NewMoney(0, "KZT"), wantMax: NewMoney(500000, "KZT")},
+		{name: "currency from the other bound", req: CategoryRuleRequest{MinAmount: money(100, ""), MaxAmount: money(900, "usd"), CategoryID: &groceries}, wantMin: NewMoney(100, "USD"), wantMax: NewMoney(900, "USD")},
+		{name: "tags only", req: CategoryRuleRequest{ToCardNumber: " 4400 ", Tags: []string{"Rent"}}},
+		{name: "no condition", req: CategoryRuleRequest{CategoryID: &groceries}, wantErr: true},
+		{name: "no action", req: CategoryRuleRequest{ToCardNumber: "4400"}, wantErr: true},
+		{name: "negative bound", req: CategoryRuleRequest{MinAmount: money(-1, "KZT"), CategoryID: &groceries}, wantErr: true},
+		{name: "max below min", req: CategoryRuleRequest{MinAmount: money(900, "KZT"), MaxAmount: money(100, "KZT"), CategoryID: &groceries}, wantErr: true},
+		{name: "weekday out of range", req: CategoryRuleRequest{Weekdays: []int{7}, CategoryID: &groceries}, wantErr: true},
+		{name: "invalid regex", req: CategoryRuleRequest{DescriptionRegex: "(coffee", CategoryID: &groceries}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := NewCategoryRule(1, &tt.req)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected an error; got %+v", tt.name, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+		if got.MinAmount != tt.wantMin || got.MaxAmount != tt.wantMax {
+			t.Errorf("%s: got %v - %v; expected %v - %v", tt.name, got.MinAmount, got.MaxAmount, tt.wantMin, tt.wantMax)
+		}
+		if !got.Enabled {
+			t.Errorf("%s: got a disabled rule; expected rules to start enabled", tt.name)
+		}
+	}
+}
+
+func TestCategoryRuleMatches(t *testing.T) {
+	// 6 May 2024 is a Monday
+	monday := time.Date(2024, 5, 6, 12, 0, 0, 0, time.UTC)
+	input := func(amount int64, currency, description string, at time.Time) *RuleInput {
+		return &RuleInput{ToCardNumber: "4400", Amount: NewMoney(amount, currency), Description: description, Time: at}
+	}
+
+	tests := []struct {
+		name string
+		rule CategoryRule
+		in   *RuleInput
+		want bool
+	}{
+		{name: "card", rule: CategoryRule{ToCardNumber: "4400"}, in: input(100, "KZT", "", monday), want: true},
+		{name: "other card", rule: CategoryRule{ToCardNumber: "5500"}, in: input(100, "KZT", "", monday), want: false},
+		{name: "min only, above", rule: CategoryRule{MinAmount: NewMoney(1000, "KZT"), MaxAmount: NewMoney(0, "KZT")}, in: input(1000000, "KZT", "", monday), want: true},
+		{name: "min only, below", rule: CategoryRule{MinAmount: NewMoney(1000, "KZT"), MaxAmount: NewMoney(0, "KZT")}, in: input(999, "KZT", "", monday), want: false},
+		{name: "max only, below", rule: CategoryRule{MinAmount: NewMoney(0, "KZT"), MaxAmount: NewMoney(5000, "KZT")}, in: input(5000, "KZT", "", monday), want: true},
+		{name: "max only, above", rule: CategoryRule{MinAmount: NewMoney(0, "KZT"), MaxAmount: NewMoney(5000, "KZT")}, in: input(5001, "KZT", "", monday), want: false},
+		{name: "other currency", rule: CategoryRule{MinAmount: NewMoney(100, "USD"), MaxAmount: NewMoney(500, "USD")}, in: input(300, "KZT", "", monday), want: false},
+		{name: "contains, any case", rule: CategoryRule{DescriptionContains: "Coffee"}, in: input(100, "KZT", "morning COFFEE", monday), want: true},
+		{name: "regex", rule: CategoryRule{DescriptionRegex: `^rent \d{4}-\d{2}$`}, in: input(100, "KZT", "rent 2024-05", monday), want: true},
+		{name: "regex, no match", rule: CategoryRule{DescriptionRegex: `^rent \d{4}-\d{2}$`}, in: input(100, "KZT", "rent for May", monday), want: false},
+		{name: "invalid regex", rule: CategoryRule{DescriptionRegex: "(rent"}, in: input(100, "KZT", "(rent", monday), want: false},
+		{name: "weekday", rule: CategoryRule{Weekdays: []int{1, 5}}, in: input(100, "KZT", "", monday), want: true},
+		{name: "other weekday", rule: CategoryRule{Weekdays: []int{0, 6}}, in: input(100, "KZT", "", monday.AddDate(0, 0, 1)), want: false},
+		{name: "every condition must hold", rule: CategoryRule{ToCardNumber: "4400", DescriptionContains: "taxi"}, in: input(100, "KZT", "coffee", monday), want: false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.rule.Matches(tt.in); got != tt.want {
+			t.Errorf("%s: got %v; expected %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestApplyRules(t *testing.T) {
+	transport, taxi := uint(1), uint(2)
+	in := &RuleInput{ToCardNumber: "4400", Amount: NewMoney(250000, "KZT"), Description: "Yandex Taxi", Time: time.Date(2024, 5, 6, 8, 0, 0, 0, time.UTC)}
+
+	tests := []struct {
+		name         string
+		rules        []*CategoryRule
+		wantCategory *uint
+		wantTags     []string
+	}{
+		{
+			name: "first match wins, tags add up",
+			rules: []*CategoryRule{
+				{Enabled: true, DescriptionContains: "taxi", CategoryID: &transport, Tags: []string{"Work"}},
+				{Enabled: true, ToCardNumber: "4400", CategoryID: &taxi, Tags: []string{"work", "commute"}},
+			},
+			wantCategory: &transport,
+			wantTags:     []string{"work", "commute"},
+		},
+		{
+			name: "a tags-only rule does not take the category",
+			rules: []*CategoryRule{
+				{Enabled: true, ToCardNumber: "4400", Tags: []string{"commute"}},
+				{Enabled: true, DescriptionContains: "taxi", CategoryID: &taxi},
+			},
+			wantCategory: &taxi,
+			wantTags:     []string{"commute"},
+		},
+		{
+			name: "disabled and unmatched rules are skipped",
+			rules: []*CategoryRule{
+				{Enabled: false, DescriptionContains: "taxi", CategoryID: &transport},
+				{Enabled: true, ToCardNumber: "5500", CategoryID: &transport},
+				{Enabled: true, Weekdays: []int{1}, CategoryID: &taxi},
+			},
+			wantCategory: &taxi,
+		},
+		{
+			name:  "nothing matches",
+			rules: []*CategoryRule{{Enabled: true, DescriptionContains: "coffee", CategoryID: &transport}},
+		},
+	}
+
+	for _, tt := range tests {
+		category, tags := ApplyRules(tt.rules, in)
+		if !reflect.DeepEqual(category, tt.wantCategory) {
+			t.Errorf("%s: got category %v; expected %v", tt.name, category, tt.wantCategory)
+		}
+		if !reflect.DeepEqual(tags, tt.wantTags) {
+			t.Errorf("%s: got tags %v; expected %v", tt.name, tags, tt.wantTags)
+		}
+	}
+}
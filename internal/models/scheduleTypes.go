@@ -0,0 +1,167 @@
+package models
+
+import (
+	"fmt"
+	"personal_budget_app/internal/functionalities"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	FrequencyOnce    = "once"
+	FrequencyWeekly  = "weekly"
+	FrequencyMonthly = "monthly"
+	FrequencyCron    = "cron"
+
+	ScheduleActive    = "active"
+	SchedulePaused    = "paused"
+	ScheduleCancelled = "cancelled"
+	ScheduleCompleted = "completed"
+
+	RunClaimed   = "claimed" // being run; left so when the run was interrupted
+	RunSucceeded = "succeeded"
+	RunFailed    = "failed"
+)
+
+// ScheduledTransfer is a transfer the app makes on the account's behalf,
+// once on a future date or repeatedly. NextRunAt is nil once nothing is left
+// to run.
+type ScheduledTransfer struct {
+	gorm.Model
+	AccountID    uint                   `json:"-" gorm:"not null;index"`
+	FromCardID   uint                   `json:"fromCardID" gorm:"not null"`
+	ToCardNumber string                 `json:"toCardNumber" gorm:"not null"`
+	Amount       Money                  `json:"amount" gorm:"embedded;embeddedPrefix:amount_"`
+	Frequency    string                 `json:"frequency" gorm:"size:16;not null"`
+	CronExpr     string                 `json:"cronExpr,omitempty"`
+	StartAt      time.Time              `json:"startAt" gorm:"not null"`
+	NextRunAt    *time.Time             `json:"nextRunAt" gorm:"index"`
+	LastRunAt    *time.Time             `json:"lastRunAt"`
+	Status       string                 `json:"status" gorm:"size:16;not null;index"`
+	Runs         []ScheduledTransferRun `gorm:"foreignKey:ScheduleID" json:"runs,omitempty"`
+}
+
+// ScheduledTransferRun records one attempt to run a schedule. It is written
+// as claimed together with the schedule moving on, and finished once the
+// transfer is made, so a run cut short stays visible and is picked up again.
+type ScheduledTransferRun struct {
+	ID            uint               `json:"id" gorm:"primaryKey"`
+	ScheduleID    uint               `json:"scheduleId" gorm:"not null;index"`
+	ScheduledFor  time.Time          `json:"scheduledFor"`
+	RunAt         time.Time          `json:"runAt"`
+	Status        string             `json:"status" gorm:"size:16;not null;index"`
+	Error         string             `json:"error,omitempty"`
+	TransactionID *uint              `json:"transactionId,omitempty"`
+	Schedule      *ScheduledTransfer `json:"-" gorm:"-"` // set on claimed runs
+}
+
+type CreateScheduledTransferRequest struct {
+	FromCardID   uint   `json:"fromCardID"`
+	ToCardNumber string `json:"toCardNumber"`
+	Amount       Money  `json:"amount"`
+	Frequency    string `json:"frequency"`
+	CronExpr     string `json:"cronExpr"`
+	StartAt      string `json:"startAt"` // RFC 3339, or 2006-01-02 for midnight UTC
+}
+
+func NewScheduledTransfer(accountID uint, req *CreateScheduledTransferRequest, startAt time.Time) (*ScheduledTransfer, error) {
+	st := &ScheduledTransfer{
+		AccountID:    accountID,
+		FromCardID:   req.FromCardID,
+		ToCardNumber: req.ToCardNumber,
+		Amount:       req.Amount,
+		Frequency:    req.Frequency,
+		CronExpr:     req.CronExpr,
+		StartAt:      startAt,
+		Status:       ScheduleActive,
+	}
+
+	switch st.Frequency {
+	case FrequencyOnce, FrequencyWeekly, FrequencyMonthly:
+		st.CronExpr = ""
+	case FrequencyCron:
+		if _, err := functionalities.ParseCron(st.CronExpr); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown frequency %q, expected once, weekly, monthly or cron", st.Frequency)
+	}
+
+	next, err := st.NextRun(startAt.Add(-time.Nanosecond))
+	if err != nil {
+		return nil, err
+	}
+	if next == nil {
+		return nil, fmt.Errorf("schedule never runs")
+	}
+	st.NextRunAt = next
+
+	return st, nil
+}
+
+// NextRun is the first run strictly after `after`, or nil when the schedule
+// has nothing left to run. Weekly and monthly runs keep StartAt's weekday or
+// day of month; a monthly run on the 31st falls on the last day of shorter
+// months. Cron rules are evaluated in UTC from StartAt onwards.
+func (st *ScheduledTransfer) NextRun(after time.Time) (*time.Time, error) {
+	var next time.Time
+
+	switch st.Frequency {
+	case FrequencyOnce:
+		if !st.StartAt.After(after) {
+			return nil, nil
+		}
+		next = st.StartAt
+
+	case FrequencyWeekly:
+		next = st.StartAt
+		if !next.After(after) {
+			weeks := int(after.Sub(st.StartAt)/(7*24*time.Hour)) + 1
+			next = st.StartAt.AddDate(0, 0, 7*weeks)
+			for !next.After(after) {
+				next = next.AddDate(0, 0, 7)
+			}
+		}
+
+	case FrequencyMonthly:
+		for months := 0; ; months++ {
+			next = addMonthsClamped(st.StartAt, months)
+			if next.After(after) {
+				break
+			}
+		}
+
+	case FrequencyCron:
+		rule, err := functionalities.ParseCron(st.CronExpr)
+		if err != nil {
+			return nil, err
+		}
+		if after.Before(st.StartAt) {
+			after = st.StartAt.Add(-time.Minute)
+		}
+		next = rule.Next(after.UTC())
+		if next.IsZero() {
+			return nil, nil
+		}
+
+	default:
+		return nil, fmt.Errorf("unknown frequency %q", st.Frequency)
+	}
+
+	return &next, nil
+}
+
+// addMonthsClamped moves t forward by n months, landing on the last day of
+// the month when t's day does not exist there.
+func addMonthsClamped(t time.Time, n int) time.Time {
+	firstOfMonth := time.Date(t.Year(), t.Month()+time.Month(n), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	lastDay := firstOfMonth.AddDate(0, 1, -1).Day()
+
+	day := t.Day()
+	if day > lastDay {
+		day = lastDay
+	}
+
+	return firstOfMonth.AddDate(0, 0, day-1)
+}
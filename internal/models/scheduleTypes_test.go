@@ -0,0 +1,96 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduledTransferNextRun(t *testing.T) {
+	at := func(s string) time.Time {
+		v, err := time.Parse("2006-01-02 15:04", s)
+		if err != nil {
+			t.Fatalf("bad time %q: %v", s, err)
+		}
+		return v
+	}
+
+	tests := []struct {
+		name      string
+		frequency string
+		cron      string
+		start     string
+		after     string
+		want      string // empty when nothing is left to run
+	}{
+		{"once, before", FrequencyOnce, "", "2024-03-10 09:00", "2024-03-01 00:00", "2024-03-10 09:00"},
+		{"once, at", FrequencyOnce, "", "2024-03-10 09:00", "2024-03-10 09:00", ""},
+		{"once, after", FrequencyOnce, "", "2024-03-10 09:00", "2024-04-01 00:00", ""},
+
+		{"weekly, before start", FrequencyWeekly, "", "2024-03-01 09:00", "2024-02-01 00:00", "2024-03-01 09:00"},
+		{"weekly, at a run", FrequencyWeekly, "", "2024-03-01 09:00", "2024-03-08 09:00", "2024-03-15 09:00"},
+		{"weekly, between runs", FrequencyWeekly, "", "2024-03-01 09:00", "2024-03-09 12:00", "2024-03-15 09:00"},
+		{"weekly, much later", FrequencyWeekly, "", "2024-03-01 09:00", "2025-03-01 09:00", "2025-03-07 09:00"},
+
+		{"monthly, next month", FrequencyMonthly, "", "2024-01-15 09:00", "2024-01-15 09:00", "2024-02-15 09:00"},
+		// the 31st falls on the last day of shorter months
+		{"monthly, 31st in February", FrequencyMonthly, "", "2024-01-31 09:00", "2024-01-31 09:00", "2024-02-29 09:00"},
+		{"monthly, 31st in a common year", FrequencyMonthly, "", "2023-01-31 09:00", "2023-01-31 09:00", "2023-02-28 09:00"},
+		{"monthly, 31st in April", FrequencyMonthly, "", "2024-01-31 09:00", "2024-03-31 09:00", "2024-04-30 09:00"},
+		// and goes back to the 31st when the month has one
+		{"monthly, 31st after April", FrequencyMonthly, "", "2024-01-31 09:00", "2024-04-30 09:00", "2024-05-31 09:00"},
+		{"monthly, 30th in February", FrequencyMonthly, "", "2024-01-30 09:00", "2024-02-01 00:00", "2024-02-29 09:00"},
+		{"monthly, across the year", FrequencyMonthly, "", "2024-12-31 09:00", "2024-12-31 09:00", "2025-01-31 09:00"},
+
+		{"cron, from start", FrequencyCron, "0 9 1 * *", "2024-03-15 00:00", "2024-01-01 00:00", "2024-04-01 09:00"},
+		{"cron, later", FrequencyCron, "0 9 1 * *", "2024-03-15 00:00", "2024-04-01 09:00", "2024-05-01 09:00"},
+		{"cron, at start", FrequencyCron, "0 9 * * *", "2024-03-15 09:00", "2024-03-01 00:00", "2024-03-15 09:00"},
+		{"cron, never", FrequencyCron, "0 0 30 2 *", "2024-01-01 00:00", "2024-01-01 00:00", ""},
+	}
+
+	for _, tt := range tests {
+		st := &ScheduledTransfer{Frequency: tt.frequency, CronExpr: tt.cron, StartAt: at(tt.start)}
+
+		got, err := st.NextRun(at(tt.after))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+		if tt.want == "" {
+			if got != nil {
+				t.Errorf("%s: got %v; expected no run", tt.name, got)
+			}
+			continue
+		}
+		if got == nil || !got.Equal(at(tt.want)) {
+			t.Errorf("%s: got %v; expected %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNewScheduledTransfer(t *testing.T) {
+	start := time.Date(2024, 3, 10, 9, 0, 0, 0, time.UTC)
+	req := &CreateScheduledTransferRequest{
+		FromCardID:   1,
+		ToCardNumber: "4400000000000000",
+		Amount:       NewMoney(500000, "KZT"),
+		Frequency:    FrequencyMonthly,
+	}
+
+	st, err := NewScheduledTransfer(3, req, start)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if st.NextRunAt == nil || !st.NextRunAt.Equal(start) || st.Status != ScheduleActive {
+		t.Errorf("got next run %v and status %q; expected %v and active", st.NextRunAt, st.Status, start)
+	}
+
+	for _, bad := range []*CreateScheduledTransferRequest{
+		{Frequency: "daily"},
+		{Frequency: FrequencyCron, CronExpr: "0 9 * *"},
+		{Frequency: FrequencyCron, CronExpr: "0 0 30 2 *"},
+	} {
+		if _, err := NewScheduledTransfer(3, bad, start); err == nil {
+			t.Errorf("frequency %q, cron %q: expected an error", bad.Frequency, bad.CronExpr)
+		}
+	}
+}
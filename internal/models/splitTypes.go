@@ -0,0 +1,70 @@
+package models
+
+import "fmt"
+
+// TransactionSplit is one line of a transaction split across categories.
+// The lines of a transaction add up to its TransactionAmount; budgets and
+// reports count them instead of the transaction's own category.
+type TransactionSplit struct {
+	ID            uint   `json:"id" gorm:"primaryKey"`
+	TransactionID uint   `json:"-" gorm:"not null;index"`
+	Amount        Money  `json:"amount" gorm:"embedded;embeddedPrefix:amount_"`
+	CategoryID    *uint  `json:"categoryId" gorm:"index"`
+	Note          string `json:"note,omitempty" gorm:"size:500"`
+}
+
+type SplitLineRequest struct {
+	Amount     Money  `json:"amount"` // in the transaction's currency when it names none
+	CategoryID *uint  `json:"categoryId"`
+	Note       string `json:"note"`
+}
+
+// SplitTransactionRequest replaces the lines of a transaction; no lines
+// removes the split.
+type SplitTransactionRequest struct {
+	Lines []SplitLineRequest `json:"lines"`
+}
+
+// NewTransactionSplits checks that the lines add up to the transaction's
+// amount and builds them.
+func NewTransactionSplits(ts *Transaction, req *SplitTransactionRequest) ([]*TransactionSplit, error) {
+	if ts.Kind == TransactionKindRefund {
+		return nil, fmt.Errorf("a refund cannot be split")
+	}
+	if len(req.Lines) == 0 {
+		return nil, nil
+	}
+	if len(req.Lines) == 1 {
+		return nil, fmt.Errorf("a split needs at least two lines")
+	}
+
+	total := NewMoney(0, ts.TransactionAmount.Currency)
+	splits := make([]*TransactionSplit, 0, len(req.Lines))
+	for i, line := range req.Lines {
+		currency := line.Amount.Currency
+		if currency == "" {
+			currency = ts.TransactionAmount.Currency
+		}
+		amount := NewMoney(line.Amount.Amount, currency)
+		if !amount.SameCurrency(ts.TransactionAmount) {
+			return nil, fmt.Errorf("line %d is in %v but the transaction is in %v", i+1, amount.Currency, ts.TransactionAmount.Currency)
+		}
+		if amount.Amount <= 0 {
+			return nil, fmt.Errorf("line %d: amount must be positive", i+1)
+		}
+		total = total.Add(amount)
+
+		splits = append(splits, &TransactionSplit{
+			TransactionID: ts.ID,
+			Amount:        amount,
+			CategoryID:    line.CategoryID,
+			Note:          line.Note,
+		})
+	}
+
+	if total.Cmp(ts.TransactionAmount) != 0 {
+		return nil, fmt.Errorf("lines add up to %v, not the transaction's %v", total, ts.TransactionAmount)
+	}
+
+	return splits, nil
+}
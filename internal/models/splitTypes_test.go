@@ -0,0 +1,66 @@
+package models
+
+import "testing"
+
+func TestNewTransactionSplits(t *testing.T) {
+	food, home := uint(1), uint(2)
+	line := func(amount int64, currency string, category *uint) SplitLineRequest {
+		return SplitLineRequest{Amount: Money{Amount: amount, Currency: currency}, CategoryID: category}
+	}
+
+	tests := []struct {
+		name      string
+		kind      string
+		lines     []SplitLineRequest
+		wantLines int
+		wantErr   bool
+	}{
+		{name: "two lines", lines: []SplitLineRequest{line(600000, "", &food), line(400000, "", &home)}, wantLines: 2},
+		{name: "currency named", lines: []SplitLineRequest{line(999999, "kzt", &food), line(1, "", nil)}, wantLines: 2},
+		{name: "three lines", lines: []SplitLineRequest{line(333333, "", &food), line(333333, "", &home), line(333334, "", nil)}, wantLines: 3},
+		{name: "no lines removes the split", lines: nil, wantLines: 0},
+		{name: "expense entry", kind: TransactionKindExpense, lines: []SplitLineRequest{line(500000, "", &food), line(500000, "", &home)}, wantLines: 2},
+		{name: "one line", lines: []SplitLineRequest{line(1000000, "", &food)}, wantErr: true},
+		{name: "short", lines: []SplitLineRequest{line(600000, "", &food), line(399999, "", &home)}, wantErr: true},
+		{name: "over", lines: []SplitLineRequest{line(600000, "", &food), line(400001, "", &home)}, wantErr: true},
+		{name: "zero line", lines: []SplitLineRequest{line(1000000, "", &food), line(0, "", &home)}, wantErr: true},
+		{name: "negative line", lines: []SplitLineRequest{line(1100000, "", &food), line(-100000, "", &home)}, wantErr: true},
+		{name: "other currency", lines: []SplitLineRequest{line(500000, "USD", &food), line(500000, "", &home)}, wantErr: true},
+		{name: "refund", kind: TransactionKindRefund, lines: []SplitLineRequest{line(500000, "", &food), line(500000, "", &home)}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		ts := &Transaction{Kind: TransactionKindTransfer, TransactionAmount: NewMoney(1000000, "KZT")}
+		ts.ID = 5
+		if tt.kind != "" {
+			ts.Kind = tt.kind
+		}
+
+		splits, err := NewTransactionSplits(ts, &SplitTransactionRequest{Lines: tt.lines})
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected an error", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+		if len(splits) != tt.wantLines {
+			t.Errorf("%s: got %d lines; expected %d", tt.name, len(splits), tt.wantLines)
+			continue
+		}
+
+		total := int64(0)
+		for i, split := range splits {
+			total += split.Amount.Amount
+			if split.TransactionID != 5 || split.Amount.Currency != "KZT" || split.CategoryID != tt.lines[i].CategoryID {
+				t.Errorf("%s: unexpected line %+v", tt.name, split)
+			}
+		}
+		if len(splits) > 0 && total != ts.TransactionAmount.Amount {
+			t.Errorf("%s: lines add up to %d; expected %d", tt.name, total, ts.TransactionAmount.Amount)
+		}
+	}
+}
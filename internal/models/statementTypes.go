@@ -0,0 +1,68 @@
+package models
+
+import "time"
+
+// StatementLine is one movement of a card's balance, taken from its ledger.
+// Amount is positive when money came in.
+type StatementLine struct {
+	At            time.Time `json:"at"`
+	Kind          string    `json:"kind"` // opening, transfer, refund or manual
+	TransactionID *uint     `json:"transactionId,omitempty"`
+	Description   string    `json:"description,omitempty"`
+	Counterparty  string    `json:"counterparty,omitempty"` // the other card's number, or the name on an entry made by hand
+	Deleted       bool      `json:"deleted,omitempty"`      // the entry was deleted later; its reversal is a line of its own
+	Amount        Money     `json:"amount"`
+	Balance       Money     `json:"balance"` // after this line
+}
+
+// CardStatement is a card's balance over a period: where it started, every
+// movement with the balance after it, and where it ended. Its balances are
+// the ledger's, so the closing balance of a period that ends now is the
+// card's balance.
+type CardStatement struct {
+	ReportPeriod
+	CardID         uint             `json:"cardId"`
+	CardNumber     string           `json:"cardNumber"`
+	Timezone       string           `json:"timezone"`
+	OpeningBalance Money            `json:"openingBalance"`
+	ClosingBalance Money            `json:"closingBalance"`
+	MoneyIn        Money            `json:"moneyIn"`
+	MoneyOut       Money            `json:"moneyOut"` // positive
+	Net            Money            `json:"net"`
+	Lines          []*StatementLine `json:"lines"`
+}
+
+// NewCardStatement runs opening, the balance before period, through lines,
+// oldest first, filling in the balance after each.
+func NewCardStatement(card *Card, period ReportPeriod, opening Money, lines []*StatementLine) *CardStatement {
+	zero := NewMoney(0, opening.Currency)
+	statement := &CardStatement{
+		ReportPeriod:   period,
+		CardID:         card.ID,
+		CardNumber:     card.CardNumber,
+		Timezone:       period.From.Location().String(),
+		OpeningBalance: opening,
+		MoneyIn:        zero,
+		MoneyOut:       zero,
+		Lines:          lines,
+	}
+	if statement.Lines == nil {
+		statement.Lines = []*StatementLine{}
+	}
+
+	balance := opening
+	for _, line := range statement.Lines {
+		balance = balance.Add(line.Amount)
+		line.Balance = balance
+
+		if line.Amount.IsNegative() {
+			statement.MoneyOut = statement.MoneyOut.Sub(line.Amount)
+		} else {
+			statement.MoneyIn = statement.MoneyIn.Add(line.Amount)
+		}
+	}
+	statement.ClosingBalance = balance
+	statement.Net = statement.MoneyIn.Sub(statement.MoneyOut)
+
+	return statement
+}
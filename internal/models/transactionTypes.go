@@ -1,20 +1,188 @@
 package models
 
-import "time"
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	TransactionKindTransfer = "transfer"
+	TransactionKindRefund   = "refund"
+	TransactionKindIncome   = "income"  // entered by hand: money from outside the app
+	TransactionKindExpense  = "expense" // entered by hand: cash, shops, other banks
+
+	TransactionPending   = "pending"
+	TransactionConfirmed = "confirmed"
+	TransactionCancelled = "cancelled"
+	TransactionExpired   = "expired"
+)
 
 type AddTransactionRequest struct {
-	TransactionAmount float64   `json:"transactionAmount"`
+	TransactionAmount Money     `json:"transactionAmount"`
 	FromCardID            uint      `json:"fromCardID"`
 	ToCardNumber string      `json:"toCardNumber"`
+	Description       string    `json:"description"`
+	CategoryID        *uint     `json:"categoryId"`
+	ExternalReference string    `json:"externalReference"` // e.g. an invoice or order number
+	CounterpartyName  string    `json:"counterpartyName"`
+	Tags              []string  `json:"tags"`
+	ScheduledRunID    *uint     `json:"-"` // set by the scheduler
+}
+
+// UpdateTransactionRequest edits the notes on a transaction after the fact.
+// Fields left out are unchanged; a categoryId of 0 clears it and tags replace
+// the editing account's own, so [] removes them all.
+type UpdateTransactionRequest struct {
+	Description *string   `json:"description"`
+	CategoryID  *uint     `json:"categoryId"`
+	Tags        *[]string `json:"tags"`
+}
+
+// TransactionFilter narrows a card's transaction history. Zero values match everything.
+type TransactionFilter struct {
+	Kind              string // transfer, refund, income or expense
+	CategoryID        *uint
+	Description       string // case-insensitive substring
+	ExternalReference string
+	CounterpartyName  string // case-insensitive substring
+	Tag               string
+	From, To          *time.Time // transaction time in [From, To)
+	// amounts are in minor units of the card's side of the transaction: what
+	// left it or what arrived on it; both bounds are included
+	MinAmount, MaxAmount   *int64
+	CounterpartyCardNumber string // the card on the other side
+}
+
+// TransactionSearch finds transactions of an account by words in their
+// description, counterparty name, external reference and tags, or by digits
+// of either card number.
+type TransactionSearch struct {
+	Query string
+	Limit int
+}
+
+// TransactionTag is a tag an account put on a transaction. Each side of a
+// transfer tags it for itself, and only sees and searches its own tags.
+type TransactionTag struct {
+	TransactionID uint   `gorm:"primaryKey;autoIncrement:false"`
+	AccountID     uint   `gorm:"primaryKey;autoIncrement:false;index:idx_transaction_tags_account_tag,priority:1"`
+	Tag           string `gorm:"primaryKey;index:idx_transaction_tags_account_tag,priority:2"`
 }
 
-func NewTransaction(amount float64, fromCardId uint, toCardID uint) *Transaction {
+// NewTransactionTags is the rows behind the account's tags on a transaction.
+func NewTransactionTags(transactionID, accountID uint, tags []string) []*TransactionTag {
+	rows := make([]*TransactionTag, 0, len(tags))
+	for _, tag := range tags {
+		rows = append(rows, &TransactionTag{TransactionID: transactionID, AccountID: accountID, Tag: tag})
+	}
+	return rows
+}
+
+// TagCount is a tag and how many of an account's transactions carry it.
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int64  `json:"count"`
+}
+
+type RefundTransactionRequest struct {
+	Amount *Money `json:"amount"` // omit to refund everything not yet refunded
+}
+
+func NewTransaction(amount Money, fromCardId uint, toCardID uint) *Transaction {
 	newCard := &Transaction{
 		TransactionTime: time.Now(),
 		TransactionAmount: amount,
-		FromCardID: fromCardId,
-		ToCardID: toCardID,
+		FromCardID: &fromCardId,
+		ToCardID: &toCardID,
+		Kind: TransactionKindTransfer,
+		Status: TransactionConfirmed,
+		AffectsBalance: true,
 	}
 
 	return newCard
 }
+
+// AddManualEntryRequest records money that moved outside the app's cards.
+type AddManualEntryRequest struct {
+	Kind              string   `json:"kind"` // income or expense
+	Amount            Money    `json:"amount"`
+	Date              string   `json:"date"` // RFC 3339 or 2006-01-02, now when empty; may be in the past
+	CategoryID        *uint    `json:"categoryId"`
+	CardID            *uint    `json:"cardId"`         // the card it was paid with or into, if any
+	AffectsBalance    bool     `json:"affectsBalance"` // change the card's balance too
+	Description       string   `json:"description"`
+	CounterpartyName  string   `json:"counterpartyName"`
+	ExternalReference string   `json:"externalReference"`
+	Tags              []string `json:"tags"`
+}
+
+// NewManualEntry builds an income or expense entry owned by accountID. An
+// expense is paid from the card and income is paid into it; without a card
+// the entry is recorded against the account only.
+func NewManualEntry(accountID uint, req *AddManualEntryRequest, at time.Time) (*Transaction, error) {
+	if req.Kind != TransactionKindIncome && req.Kind != TransactionKindExpense {
+		return nil, fmt.Errorf("unknown kind %q, expected income or expense", req.Kind)
+	}
+	if req.Amount.Amount <= 0 {
+		return nil, fmt.Errorf("amount must be positive")
+	}
+	if req.AffectsBalance && req.CardID == nil {
+		return nil, fmt.Errorf("only an entry with a card can affect a balance")
+	}
+
+	ts := &Transaction{
+		TransactionTime:   at,
+		TransactionAmount: req.Amount,
+		ReceivedAmount:    req.Amount,
+		ExchangeRate:      "1",
+		Kind:              req.Kind,
+		Status:            TransactionConfirmed,
+		AccountID:         &accountID,
+		AffectsBalance:    req.AffectsBalance,
+		Description:       req.Description,
+		CategoryID:        req.CategoryID,
+		ExternalReference: req.ExternalReference,
+		CounterpartyName:  req.CounterpartyName,
+		Tags:              NormalizeTags(req.Tags),
+	}
+
+	if req.Kind == TransactionKindExpense {
+		ts.FromCardID = req.CardID
+	} else {
+		ts.ToCardID = req.CardID
+	}
+
+	return ts, nil
+}
+
+// IsManual reports whether ts was entered by hand rather than moved between cards.
+func (ts *Transaction) IsManual() bool {
+	return ts.Kind == TransactionKindIncome || ts.Kind == TransactionKindExpense
+}
+
+// ManualCardID is the card a manual entry was paid with or into, if any.
+func (ts *Transaction) ManualCardID() *uint {
+	if ts.Kind == TransactionKindExpense {
+		return ts.FromCardID
+	}
+	return ts.ToCardID
+}
+
+// NormalizeTags lower-cases and trims tags, dropping empty ones and
+// duplicates while keeping their order.
+func NormalizeTags(tags []string) []string {
+	var normalized []string
+	seen := make(map[string]bool, len(tags))
+
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+
+	return normalized
+}
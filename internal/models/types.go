@@ -14,13 +14,16 @@ type Account struct {
 	Birthday    time.Time `json:"birthday"`
 	PhoneNumber string    `json:"phoneNumber"`
 	DefaultCardID   uint      `json:"defaultCardID"` // I want to add here default card id
+	IsAdmin     bool      `json:"isAdmin" gorm:"default:false"`
+	Timezone    string    `json:"timezone" gorm:"size:64;not null;default:'UTC'"` // IANA name; reports split months by it
 	Cards       []Card    `gorm:"foreignKey:AccountID" json:"cards,omitempty"`
 }
 
 type Card struct {
 	gorm.Model
 	CardNumber     string        `json:"cardNumber" gorm:"unique"`
-	CardBalance    float64       `json:"cardBalance"`
+	CardBalance    Money         `json:"cardBalance" gorm:"embedded;embeddedPrefix:card_balance_"`
+	ReservedBalance Money        `json:"reservedBalance" gorm:"embedded;embeddedPrefix:reserved_balance_"` // held by pending transfers
 	CardType       string        `json:"cardType"`
 	CardExpireDate time.Time     `json:"cardExpireDate"`
 	AccountID      uint          `json:"-"`
@@ -31,9 +34,28 @@ type Card struct {
 type Transaction struct {
 	gorm.Model
 	TransactionTime   time.Time `json:"transactionTime"`
-	TransactionAmount float64   `json:"transactionAmount"`
-	FromCardID            uint      `json:"fromCardID"`
-	ToCardID   uint      `json:"toCardID"`
+	TransactionAmount Money     `json:"transactionAmount" gorm:"embedded;embeddedPrefix:transaction_amount_"` // in the sender card's currency
+	ReceivedAmount    Money     `json:"receivedAmount" gorm:"embedded;embeddedPrefix:received_amount_"`       // in the receiver card's currency
+	ExchangeRate      string    `json:"exchangeRate" gorm:"type:numeric(24,12)"`                              // receivedAmount = transactionAmount * exchangeRate
+	FromCardID        *uint         `json:"fromCardID"` // nil for income entered by hand
+	ToCardID          *uint         `json:"toCardID"`   // nil for expenses entered by hand
+	Kind              string        `json:"kind" gorm:"size:16;not null;default:'transfer'"`
+	AccountID         *uint         `json:"accountId,omitempty" gorm:"index"` // owner of an entry made by hand
+	AffectsBalance    bool          `json:"affectsBalance"`                   // false for entries kept out of CardBalance
+	Status            string        `json:"status" gorm:"size:16;not null;default:'confirmed';index"`
+	ExpiresAt         *time.Time    `json:"expiresAt,omitempty"` // pending transfers are released after this
+	ReversalOfID      *uint         `json:"reversalOfID,omitempty" gorm:"index"` // set on refunds: the transfer being refunded
+	Refunds           []Transaction `gorm:"foreignKey:ReversalOfID" json:"refunds,omitempty"`
+	ScheduledRunID    *uint         `json:"scheduledRunId,omitempty" gorm:"uniqueIndex"` // set on transfers a schedule made: the run that made it
+	// set by the sender
+	Description       string        `json:"description,omitempty" gorm:"size:500"`
+	CategoryID        *uint         `json:"categoryId,omitempty" gorm:"index"`
+	ExternalReference string        `json:"externalReference,omitempty" gorm:"size:100;index"`
+	CounterpartyName  string        `json:"counterpartyName,omitempty" gorm:"size:200"`
+	Tags              []string      `json:"tags,omitempty" gorm:"-"` // the reading account's own, kept as TransactionTag rows
+	FITID             string        `json:"fitId,omitempty" gorm:"column:fit_id;size:255"` // the bank's id of an entry imported from a statement
+	FITAccount        string        `json:"fitAccount,omitempty" gorm:"column:fit_account;size:100;not null;default:''"` // the bank account FITID is unique in
+	Splits            []TransactionSplit `gorm:"foreignKey:TransactionID" json:"splits,omitempty"` // category lines, when split
 }
 
 // ----------------------------------------
@@ -69,6 +91,10 @@ func NewAccount(email, password, firstName, lastName string, birthday time.Time,
 	return newAcc
 }
 
+type SetTimezoneRequest struct {
+	Timezone string `json:"timezone"` // IANA name such as "Asia/Almaty"
+}
+
 type UpdatePasswordRequest struct {
 	Password string `json:"password"`
 }
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"os"
 	"personal_budget_app/internal/functionalities"
+	"strconv"
 )
 
 
@@ -50,3 +51,33 @@ func JWTMiddleware(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// AdminMiddleware lets the request through only for accounts with the admin
+// flag. It runs after JWTMiddleware, so the token is already known to be valid.
+func (s *Server) AdminMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, err := ExtractUserFromToken(r)
+		if err != nil {
+			functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: err.Error()})
+			return
+		}
+
+		userID, err := strconv.Atoi(user.UserID)
+		if err != nil {
+			functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: "Unauthorized"})
+			return
+		}
+
+		isAdmin, err := s.db.IsAdmin(uint(userID))
+		if err != nil {
+			functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+			return
+		}
+		if !isAdmin {
+			functionalities.WriteJSON(w, http.StatusForbidden, APIServerError{Error: "Access denied"})
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
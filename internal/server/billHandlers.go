@@ -0,0 +1,148 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/mux"
+	"net/http"
+	"personal_budget_app/internal/database"
+	"personal_budget_app/internal/functionalities"
+	"personal_budget_app/internal/models"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func (s *Server) handleCreateBill(w http.ResponseWriter, r *http.Request) {
+	user, err := ExtractUserFromToken(r)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: err.Error()})
+		return
+	}
+	if user.UserID == "" {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: "Unauthorized"})
+		return
+	}
+
+	// auth check passed:
+	userID, err := strconv.Atoi(user.UserID)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	req := new(models.CreateBillRequest)
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: "E: " + err.Error()})
+		return
+	}
+
+	loc, err := s.accountLocation(uint(userID))
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	bill, err := models.NewBill(uint(userID), req, time.Now().In(loc))
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: err.Error()})
+		return
+	}
+
+	if _, err := s.db.FindCardIDByCardNumber(bill.PayeeCardNumber); err != nil {
+		functionalities.WriteJSON(w, http.StatusNotFound, APIServerError{Error: "Payee card not found"})
+		return
+	}
+
+	if err := s.db.CreateBill(bill); err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	functionalities.WriteJSON(w, http.StatusOK, bill)
+}
+
+// handleGetBills shows the bills due in ?month=2024-05 (the current month in
+// the account's timezone by default) as paid, upcoming or overdue, with
+// totals in ?currency= (KZT by default).
+func (s *Server) handleGetBills(w http.ResponseWriter, r *http.Request) {
+	user, err := ExtractUserFromToken(r)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: err.Error()})
+		return
+	}
+	if user.UserID == "" {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: "Unauthorized"})
+		return
+	}
+
+	// auth check passed:
+	userID, err := strconv.Atoi(user.UserID)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	loc, err := s.accountLocation(uint(userID))
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	query := r.URL.Query()
+	now := time.Now()
+	period, err := models.ParseReportPeriod(query.Get("month"), "", "", now, loc)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: err.Error()})
+		return
+	}
+
+	currency := strings.ToUpper(query.Get("currency"))
+	if currency == "" {
+		currency = models.DefaultCurrency
+	}
+
+	overview, err := s.billsOverview(uint(userID), period.From, currency, now)
+	if err != nil {
+		functionalities.WriteJSON(w, transferErrorStatus(err), APIServerError{Error: err.Error()})
+		return
+	}
+
+	functionalities.WriteJSON(w, http.StatusOK, overview)
+}
+
+func (s *Server) handleDeleteBill(w http.ResponseWriter, r *http.Request) {
+	user, err := ExtractUserFromToken(r)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: err.Error()})
+		return
+	}
+	if user.UserID == "" {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: "Unauthorized"})
+		return
+	}
+
+	// auth check passed:
+	userID, err := strconv.Atoi(user.UserID)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: "invalid bill id"})
+		return
+	}
+
+	if err := s.db.DeleteBill(uint(id), uint(userID)); err != nil {
+		if errors.Is(err, database.ErrBillNotFound) {
+			functionalities.WriteJSON(w, http.StatusNotFound, APIServerError{Error: err.Error()})
+			return
+		}
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	functionalities.WriteJSON(w, http.StatusOK, map[string]string{"message": "Bill deleted"})
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"personal_budget_app/internal/models"
+	"time"
+)
+
+// billsOverview shows which of the account's bills due in month (its first
+// instant in the account's timezone) are paid, partly paid or not, and what
+// they commit the account to, converted into currency.
+func (s *Server) billsOverview(accountID uint, month time.Time, currency string, now time.Time) (*models.BillsOverview, error) {
+	loc := month.Location()
+	local := now.In(loc)
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	monthEnd := month.AddDate(0, 1, 0)
+
+	bills, err := s.db.GetBills(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	overview := &models.BillsOverview{
+		Month:          month.Format(models.BudgetMonthLayout),
+		Currency:       currency,
+		Bills:          make([]*models.BillStatus, 0, len(bills)),
+		Committed:      models.NewMoney(0, currency),
+		Paid:           models.NewMoney(0, currency),
+		MonthlyAverage: models.NewMoney(0, currency),
+	}
+
+	windows := make(map[uint][]models.PaymentWindow, len(bills))
+	var cardNumbers []string
+	var from, to time.Time
+	for _, bill := range bills {
+		windows[bill.ID] = bill.DueIn(month, monthEnd, loc)
+		for _, w := range windows[bill.ID] {
+			if from.IsZero() || w.From.Before(from) {
+				from = w.From
+			}
+			if w.To.After(to) {
+				to = w.To
+			}
+		}
+		if len(windows[bill.ID]) > 0 {
+			cardNumbers = append(cardNumbers, bill.PayeeCardNumber)
+		}
+	}
+
+	payments, err := s.db.GetPaymentsTo(accountID, cardNumbers, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	// each payment counts once, towards the due date it most likely pays
+	var dues []*models.BillDue
+	for _, bill := range bills {
+		for _, w := range windows[bill.ID] {
+			dues = append(dues, &models.BillDue{Bill: bill, Window: w})
+		}
+	}
+	assigned, err := models.AssignBillPayments(dues, payments, s.db.ConvertAmount)
+	if err != nil {
+		return nil, err
+	}
+
+	next := 0
+	for _, bill := range bills {
+		status := &models.BillStatus{Bill: bill, Occurrences: []*models.BillOccurrence{}}
+
+		for _, w := range windows[bill.ID] {
+			occurrence := models.NewBillOccurrence(bill, w, assigned[next], today)
+			next++
+			status.Occurrences = append(status.Occurrences, occurrence)
+
+			expected, err := s.db.ConvertAmount(bill.Expected, currency)
+			if err != nil {
+				return nil, err
+			}
+			overview.Committed = overview.Committed.Add(expected)
+
+			paid, err := s.db.ConvertAmount(occurrence.Paid, currency)
+			if err != nil {
+				return nil, err
+			}
+			overview.Paid = overview.Paid.Add(paid)
+		}
+
+		average, err := s.db.ConvertAmount(bill.MonthlyAverage(), currency)
+		if err != nil {
+			return nil, err
+		}
+		overview.MonthlyAverage = overview.MonthlyAverage.Add(average)
+
+		overview.Bills = append(overview.Bills, status)
+	}
+
+	return overview, nil
+}
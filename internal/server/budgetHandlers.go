@@ -0,0 +1,139 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gorilla/mux"
+	"net/http"
+	"personal_budget_app/internal/database"
+	"personal_budget_app/internal/functionalities"
+	"personal_budget_app/internal/models"
+	"strconv"
+	"time"
+)
+
+// handleSetBudget creates or replaces the budget for a category and month.
+func (s *Server) handleSetBudget(w http.ResponseWriter, r *http.Request) {
+	user, err := ExtractUserFromToken(r)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: err.Error()})
+		return
+	}
+	if user.UserID == "" {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: "Unauthorized"})
+		return
+	}
+
+	// auth check passed:
+	userID, err := strconv.Atoi(user.UserID)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	req := new(models.SetBudgetRequest)
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: "invalid request body: " + err.Error()})
+		return
+	}
+
+	visible, err := s.db.CategoryVisibleTo(req.CategoryID, uint(userID))
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+	if !visible {
+		functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: fmt.Sprintf("Category (id=%v) not found", req.CategoryID)})
+		return
+	}
+
+	budget, err := models.NewBudget(uint(userID), req)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: err.Error()})
+		return
+	}
+
+	if err := s.db.SetBudget(budget); err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	functionalities.WriteJSON(w, http.StatusOK, budget)
+}
+
+// handleGetBudgets reports the budgets of ?month=2006-01 (this month by
+// default) against what was spent.
+func (s *Server) handleGetBudgets(w http.ResponseWriter, r *http.Request) {
+	user, err := ExtractUserFromToken(r)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: err.Error()})
+		return
+	}
+	if user.UserID == "" {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: "Unauthorized"})
+		return
+	}
+
+	// auth check passed:
+	userID, err := strconv.Atoi(user.UserID)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	month := time.Now().UTC().Format(models.BudgetMonthLayout)
+	if value := r.URL.Query().Get("month"); value != "" {
+		month = value
+	}
+
+	start, err := models.ParseBudgetMonth(month)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: err.Error()})
+		return
+	}
+
+	report, err := s.budgetReport(uint(userID), start)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	functionalities.WriteJSON(w, http.StatusOK, report)
+}
+
+func (s *Server) handleDeleteBudget(w http.ResponseWriter, r *http.Request) {
+	user, err := ExtractUserFromToken(r)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: err.Error()})
+		return
+	}
+	if user.UserID == "" {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: "Unauthorized"})
+		return
+	}
+
+	// auth check passed:
+	budgetID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: "invalid budget id"})
+		return
+	}
+
+	userID, err := strconv.Atoi(user.UserID)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	if err := s.db.DeleteBudget(uint(budgetID), uint(userID)); err != nil {
+		if errors.Is(err, database.ErrBudgetNotFound) {
+			functionalities.WriteJSON(w, http.StatusNotFound, APIServerError{Error: err.Error()})
+			return
+		}
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	functionalities.WriteJSON(w, http.StatusOK, map[string]string{"message": "Budget deleted"})
+}
@@ -0,0 +1,147 @@
+package server
+
+import (
+	"errors"
+	"personal_budget_app/internal/database"
+	"personal_budget_app/internal/models"
+	"time"
+)
+
+// maxRolloverMonths bounds how far back a chain of rolled-over budgets is followed.
+const maxRolloverMonths = 24
+
+// budgetReport compares the account's budgets for month (the first instant
+// of it) with what the account spent that month.
+func (s *Server) budgetReport(accountID uint, month time.Time) (*models.BudgetReport, error) {
+	monthKey := month.Format(models.BudgetMonthLayout)
+
+	budgets, err := s.db.GetBudgets(accountID, monthKey)
+	if err != nil {
+		return nil, err
+	}
+
+	spending, err := s.monthSpending(accountID, month)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.BudgetReport{
+		Month:      monthKey,
+		Budgets:    make([]*models.BudgetStatus, 0, len(budgets)),
+		Unbudgeted: []*models.CategorySpending{},
+	}
+
+	history := &spendingHistory{accountID: accountID, from: month.AddDate(0, -maxRolloverMonths, 0), to: month}
+
+	budgeted := make(map[uint]bool, len(budgets))
+	for _, budget := range budgets {
+		budgeted[budget.CategoryID] = true
+
+		rolledOver, err := s.rolledOver(accountID, budget.CategoryID, month, budget.Planned.Currency, history, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		spent, err := s.spentIn(spending, budget.CategoryID, budget.Planned.Currency)
+		if err != nil {
+			return nil, err
+		}
+
+		report.Budgets = append(report.Budgets, models.NewBudgetStatus(budget, rolledOver, spent))
+	}
+
+	for _, line := range spending {
+		if line.CategoryID == nil || !budgeted[*line.CategoryID] {
+			report.Unbudgeted = append(report.Unbudgeted, line)
+		}
+	}
+
+	return report, nil
+}
+
+// spendingHistory is the account's spending per month over the months a
+// report's rollovers can reach back to. It is fetched once, when the first
+// budget that rolls over needs it, and shared by every budget of the report.
+type spendingHistory struct {
+	accountID uint
+	from, to  time.Time
+	months    map[string][]*models.CategorySpending
+}
+
+// spendingIn returns what the account spent per category in month, which
+// must lie within the history's range.
+func (s *Server) spendingIn(history *spendingHistory, month time.Time) ([]*models.CategorySpending, error) {
+	if history.months == nil {
+		months, err := s.db.GetMonthlyCategorySpending(history.accountID, history.from, history.to)
+		if err != nil {
+			return nil, err
+		}
+		history.months = months
+	}
+
+	return history.months[month.Format(models.BudgetMonthLayout)], nil
+}
+
+// rolledOver is what carries into the category's budget for month from the
+// month before: nothing unless last month's budget rolls over, otherwise
+// whatever it left unspent, itself including what rolled into it.
+func (s *Server) rolledOver(accountID, categoryID uint, month time.Time, currency string, history *spendingHistory, depth int) (models.Money, error) {
+	zero := models.NewMoney(0, currency)
+	if depth == maxRolloverMonths {
+		return zero, nil
+	}
+
+	prevMonth := month.AddDate(0, -1, 0)
+	prev, err := s.db.GetBudget(accountID, categoryID, prevMonth.Format(models.BudgetMonthLayout))
+	if err != nil {
+		if errors.Is(err, database.ErrBudgetNotFound) {
+			return zero, nil
+		}
+		return models.Money{}, err
+	}
+	if !prev.Rollover {
+		return zero, nil
+	}
+
+	carried, err := s.rolledOver(accountID, categoryID, prevMonth, prev.Planned.Currency, history, depth+1)
+	if err != nil {
+		return models.Money{}, err
+	}
+
+	spending, err := s.spendingIn(history, prevMonth)
+	if err != nil {
+		return models.Money{}, err
+	}
+
+	spent, err := s.spentIn(spending, categoryID, prev.Planned.Currency)
+	if err != nil {
+		return models.Money{}, err
+	}
+
+	left := models.NewBudgetStatus(prev, carried, spent).Remaining
+	return s.db.ConvertAmount(left, currency)
+}
+
+// monthSpending is what the account spent per category in the month starting at month.
+func (s *Server) monthSpending(accountID uint, month time.Time) ([]*models.CategorySpending, error) {
+	return s.db.GetCategorySpending(accountID, month, month.AddDate(0, 1, 0))
+}
+
+// spentIn totals the spending in one category, converted into currency.
+func (s *Server) spentIn(spending []*models.CategorySpending, categoryID uint, currency string) (models.Money, error) {
+	total := models.NewMoney(0, currency)
+
+	for _, line := range spending {
+		if line.CategoryID == nil || *line.CategoryID != categoryID {
+			continue
+		}
+
+		converted, err := s.db.ConvertAmount(line.Spent, currency)
+		if err != nil {
+			return models.Money{}, err
+		}
+		total = total.Add(converted)
+	}
+
+	return total, nil
+}
@@ -8,6 +8,7 @@ import (
 	"personal_budget_app/internal/functionalities"
 	"personal_budget_app/internal/models"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -42,7 +43,26 @@ func (s *Server) handleAddCard(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	card := models.NewCard(req.CardNumber, req.CardBalance, req.CardType, expireDate, uint(id))
+	// the card's currency comes from either field; they must agree if both are set
+	currency := strings.ToUpper(req.Currency)
+	balance := req.CardBalance
+	switch {
+	case currency != "" && balance.Currency != "" && currency != balance.Currency:
+		functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: fmt.Sprintf("Card currency %v does not match balance currency %v", currency, balance.Currency)})
+		return
+	case currency == "" && balance.Currency == "":
+		currency = models.DefaultCurrency
+	case currency == "":
+		currency = balance.Currency
+	}
+
+	if len(currency) != 3 {
+		functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: "Invalid currency code: " + currency})
+		return
+	}
+	balance.Currency = currency
+
+	card := models.NewCard(req.CardNumber, balance, req.CardType, expireDate, uint(id))
 
 	err = s.db.AddCard(card)
 	if err != nil {
@@ -0,0 +1,273 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gorilla/mux"
+	"net/http"
+	"personal_budget_app/internal/database"
+	"personal_budget_app/internal/functionalities"
+	"personal_budget_app/internal/models"
+	"strconv"
+)
+
+// handleGetCategories lists the categories the user can use. ?archived=true
+// includes archived ones and ?tree=true nests them under their parents.
+func (s *Server) handleGetCategories(w http.ResponseWriter, r *http.Request) {
+	user, err := ExtractUserFromToken(r)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: err.Error()})
+		return
+	}
+	if user.UserID == "" {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: "Unauthorized"})
+		return
+	}
+
+	// auth check passed:
+	userID, err := strconv.Atoi(user.UserID)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	query := r.URL.Query()
+
+	categories, err := s.db.GetCategories(uint(userID), query.Get("archived") == "true")
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	if query.Get("tree") == "true" {
+		categories = models.BuildCategoryTree(categories)
+	}
+
+	functionalities.WriteJSON(w, http.StatusOK, categories)
+}
+
+func (s *Server) handleCreateCategory(w http.ResponseWriter, r *http.Request) {
+	user, err := ExtractUserFromToken(r)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: err.Error()})
+		return
+	}
+	if user.UserID == "" {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: "Unauthorized"})
+		return
+	}
+
+	// auth check passed:
+	userID, err := strconv.Atoi(user.UserID)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	req := new(models.CreateCategoryRequest)
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: "invalid request body: " + err.Error()})
+		return
+	}
+
+	category, err := models.NewCategory(uint(userID), req)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: err.Error()})
+		return
+	}
+
+	if category.ParentID != nil {
+		if err := s.checkCategoryParent(uint(userID), 0, *category.ParentID); err != nil {
+			functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: err.Error()})
+			return
+		}
+	}
+
+	if err := s.db.CreateCategory(category); err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	functionalities.WriteJSON(w, http.StatusOK, category)
+}
+
+// handleUpdateCategory renames, moves or archives one of the user's own
+// categories. Built-in categories are shared and cannot be changed.
+func (s *Server) handleUpdateCategory(w http.ResponseWriter, r *http.Request) {
+	user, err := ExtractUserFromToken(r)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: err.Error()})
+		return
+	}
+	if user.UserID == "" {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: "Unauthorized"})
+		return
+	}
+
+	// auth check passed:
+	categoryID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: "invalid category id"})
+		return
+	}
+
+	userID, err := strconv.Atoi(user.UserID)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	req := new(models.UpdateCategoryRequest)
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: "invalid request body: " + err.Error()})
+		return
+	}
+
+	category, ok := s.ownCategory(w, uint(categoryID), uint(userID))
+	if !ok {
+		return
+	}
+
+	if req.Name != nil {
+		update, err := models.NewCategory(uint(userID), &models.CreateCategoryRequest{Name: *req.Name})
+		if err != nil {
+			functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: err.Error()})
+			return
+		}
+		category.Name = update.Name
+	}
+
+	if req.ParentID != nil {
+		if *req.ParentID == 0 {
+			category.ParentID = nil
+		} else {
+			if err := s.checkCategoryParent(uint(userID), category.ID, *req.ParentID); err != nil {
+				functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: err.Error()})
+				return
+			}
+			category.ParentID = req.ParentID
+		}
+	}
+
+	if req.Archived != nil {
+		category.Archived = *req.Archived
+	}
+
+	if err := s.db.UpdateCategory(category); err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	functionalities.WriteJSON(w, http.StatusOK, category)
+}
+
+// handleDeleteCategory deletes one of the user's own categories. Its
+// transactions and budgets move to ?fallback={id}, so nothing is left
+// pointing at a category that no longer exists.
+func (s *Server) handleDeleteCategory(w http.ResponseWriter, r *http.Request) {
+	user, err := ExtractUserFromToken(r)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: err.Error()})
+		return
+	}
+	if user.UserID == "" {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: "Unauthorized"})
+		return
+	}
+
+	// auth check passed:
+	categoryID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: "invalid category id"})
+		return
+	}
+
+	userID, err := strconv.Atoi(user.UserID)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	fallbackID, err := strconv.Atoi(r.URL.Query().Get("fallback"))
+	if err != nil || fallbackID <= 0 {
+		functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: "a fallback category is required: ?fallback={id}"})
+		return
+	}
+	if fallbackID == categoryID {
+		functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: "The fallback category cannot be the one being deleted"})
+		return
+	}
+
+	if _, ok := s.ownCategory(w, uint(categoryID), uint(userID)); !ok {
+		return
+	}
+
+	visible, err := s.db.CategoryVisibleTo(uint(fallbackID), uint(userID))
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+	if !visible {
+		functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: fmt.Sprintf("Fallback category (id=%v) not found", fallbackID)})
+		return
+	}
+
+	moved, err := s.db.DeleteCategory(uint(categoryID), uint(userID), uint(fallbackID))
+	if err != nil {
+		if errors.Is(err, database.ErrCategoryNotFound) {
+			functionalities.WriteJSON(w, http.StatusNotFound, APIServerError{Error: err.Error()})
+			return
+		}
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	functionalities.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"message":           "Category deleted",
+		"movedTransactions": moved,
+	})
+}
+
+// ownCategory loads a category for editing and writes the error response
+// when it is missing or not the user's own.
+func (s *Server) ownCategory(w http.ResponseWriter, categoryID, accountID uint) (*models.Category, bool) {
+	category, err := s.db.GetCategory(categoryID, accountID)
+	if err != nil {
+		if errors.Is(err, database.ErrCategoryNotFound) {
+			functionalities.WriteJSON(w, http.StatusNotFound, APIServerError{Error: err.Error()})
+			return nil, false
+		}
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return nil, false
+	}
+
+	if category.AccountID == nil {
+		functionalities.WriteJSON(w, http.StatusForbidden, APIServerError{Error: "Built-in categories cannot be changed"})
+		return nil, false
+	}
+
+	return category, true
+}
+
+// checkCategoryParent makes sure the account can see parentID and that
+// nesting category id under it would not put the category inside itself.
+// id is 0 for a category that does not exist yet.
+func (s *Server) checkCategoryParent(accountID, id, parentID uint) error {
+	for next := &parentID; next != nil; {
+		if *next == id {
+			return fmt.Errorf("A category cannot be nested inside itself")
+		}
+
+		parent, err := s.db.GetCategory(*next, accountID)
+		if err != nil {
+			if errors.Is(err, database.ErrCategoryNotFound) {
+				return fmt.Errorf("Parent category (id=%v) not found", *next)
+			}
+			return err
+		}
+		next = parent.ParentID
+	}
+
+	return nil
+}
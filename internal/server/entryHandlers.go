@@ -0,0 +1,193 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gorilla/mux"
+	"net/http"
+	"personal_budget_app/internal/database"
+	"personal_budget_app/internal/functionalities"
+	"personal_budget_app/internal/models"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// handleAddManualEntry records cash, salary from another bank, a shop
+// payment and the like. The entry only changes a card's balance when the
+// request asks for it with affectsBalance.
+func (s *Server) handleAddManualEntry(w http.ResponseWriter, r *http.Request) {
+	user, err := ExtractUserFromToken(r)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: err.Error()})
+		return
+	}
+	if user.UserID == "" {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: "Unauthorized"})
+		return
+	}
+
+	// auth check passed:
+	userID, err := strconv.Atoi(user.UserID)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	req := new(models.AddManualEntryRequest)
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: "E: " + err.Error()})
+		return
+	}
+
+	now := time.Now()
+	at := now
+	if req.Date != "" {
+		at, err = time.Parse(time.RFC3339, req.Date)
+		if err != nil {
+			at, err = time.Parse("2006-01-02", req.Date)
+		}
+		if err != nil {
+			functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: "Invalid date, use RFC 3339 or 2006-01-02"})
+			return
+		}
+	}
+	if at.After(now) {
+		functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: "An entry cannot be dated in the future"})
+		return
+	}
+
+	ts, err := models.NewManualEntry(uint(userID), req, at)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: err.Error()})
+		return
+	}
+
+	// check card belongs
+	if req.CardID != nil {
+		doesBelong, err := s.db.CheckCardBelongsToUser(*req.CardID, uint(userID))
+		if err != nil {
+			functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+			return
+		}
+
+		if !doesBelong {
+			functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: fmt.Sprintf("The card (id=%v) is private and does not belong to this user", *req.CardID)})
+			return
+		}
+	}
+
+	if req.CategoryID != nil {
+		visible, err := s.db.CategoryVisibleTo(*req.CategoryID, uint(userID))
+		if err != nil {
+			functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+			return
+		}
+		if !visible {
+			functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: fmt.Sprintf("Category (id=%v) not found", *req.CategoryID)})
+			return
+		}
+	}
+
+	// rules file expenses the same way they file transfers
+	if ts.Kind == models.TransactionKindExpense {
+		if err := s.applyRules(uint(userID), ts, ""); err != nil {
+			functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+			return
+		}
+	}
+
+	if err := s.db.AddManualEntry(ts); err != nil {
+		functionalities.WriteJSON(w, transferErrorStatus(err), APIServerError{Error: transferErrorMessage(err)})
+		return
+	}
+
+	functionalities.WriteJSON(w, http.StatusOK, ts)
+}
+
+// handleGetManualEntries lists the entries the user made by hand, including
+// those with no card. ?kind=income|expense and the history filters apply.
+func (s *Server) handleGetManualEntries(w http.ResponseWriter, r *http.Request) {
+	user, err := ExtractUserFromToken(r)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: err.Error()})
+		return
+	}
+	if user.UserID == "" {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: "Unauthorized"})
+		return
+	}
+
+	// auth check passed:
+	userID, err := strconv.Atoi(user.UserID)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	query := r.URL.Query()
+	filter := &models.TransactionFilter{
+		Kind:              query.Get("kind"),
+		Description:       query.Get("q"),
+		ExternalReference: query.Get("reference"),
+		CounterpartyName:  query.Get("counterparty"),
+		Tag:               strings.ToLower(strings.TrimSpace(query.Get("tag"))),
+	}
+	if categoryString := query.Get("category"); categoryString != "" {
+		categoryId, err := strconv.Atoi(categoryString)
+		if err != nil {
+			functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: "invalid category id"})
+			return
+		}
+		categoryID := uint(categoryId)
+		filter.CategoryID = &categoryID
+	}
+
+	entries, err := s.db.GetManualEntries(uint(userID), filter)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	functionalities.WriteJSON(w, http.StatusOK, entries)
+}
+
+func (s *Server) handleDeleteManualEntry(w http.ResponseWriter, r *http.Request) {
+	user, err := ExtractUserFromToken(r)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: err.Error()})
+		return
+	}
+	if user.UserID == "" {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: "Unauthorized"})
+		return
+	}
+
+	// auth check passed:
+	entryID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: "invalid entry id"})
+		return
+	}
+
+	userID, err := strconv.Atoi(user.UserID)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	if err := s.db.DeleteManualEntry(uint(entryID), uint(userID)); err != nil {
+		switch {
+		case errors.Is(err, database.ErrEntryNotFound):
+			functionalities.WriteJSON(w, http.StatusNotFound, APIServerError{Error: err.Error()})
+		case errors.Is(err, database.ErrInsufficientFunds):
+			functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: "The card no longer holds this income"})
+		default:
+			functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		}
+		return
+	}
+
+	functionalities.WriteJSON(w, http.StatusOK, map[string]string{"message": "Entry deleted"})
+}
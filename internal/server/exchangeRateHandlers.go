@@ -0,0 +1,107 @@
+package server
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"personal_budget_app/internal/functionalities"
+	"personal_budget_app/internal/models"
+	"strings"
+)
+
+const maxRatesFileSize = 1 << 20
+
+func (s *Server) handleGetExchangeRates(w http.ResponseWriter, r *http.Request) {
+	rates, err := s.db.GetExchangeRates()
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	functionalities.WriteJSON(w, http.StatusOK, rates)
+}
+
+// handleLoadExchangeRates replaces exchange rates from a CSV file with
+// base,quote,rate rows (e.g. "USD,KZT,470.15"), sent either as the "file"
+// field of a multipart form or as the raw request body.
+func (s *Server) handleLoadExchangeRates(w http.ResponseWriter, r *http.Request) {
+	// wrapped before the form is parsed, so the limit covers uploads too
+	r.Body = http.MaxBytesReader(w, r.Body, maxRatesFileSize)
+	var file io.Reader = r.Body
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := r.ParseMultipartForm(maxRatesFileSize); err != nil {
+			functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: "Invalid form: " + err.Error()})
+			return
+		}
+
+		formFile, _, err := r.FormFile("file")
+		if err != nil {
+			functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: "Missing rates file: " + err.Error()})
+			return
+		}
+		defer formFile.Close()
+
+		file = formFile
+	}
+
+	rates, err := parseExchangeRates(file)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: err.Error()})
+		return
+	}
+
+	if err := s.db.SaveExchangeRates(rates); err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	functionalities.WriteJSON(w, http.StatusOK, map[string]string{"message": fmt.Sprintf("%v exchange rates loaded", len(rates))})
+}
+
+// parseExchangeRates reads base,quote,rate rows. A header row and blank lines
+// are skipped, a pair listed twice keeps its last rate, and any other bad row
+// fails the whole file.
+func parseExchangeRates(file io.Reader) ([]*models.ExchangeRate, error) {
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = 3
+	reader.TrimLeadingSpace = true
+
+	var rates []*models.ExchangeRate
+	seen := make(map[string]int)
+	for first := true; ; first = false {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("rates file: %v", err)
+		}
+
+		if first && strings.EqualFold(record[0], "base") {
+			continue
+		}
+
+		rate, err := models.NewExchangeRate(record[0], record[1], record[2])
+		if err != nil {
+			line, _ := reader.FieldPos(0)
+			return nil, fmt.Errorf("rates file line %v: %v", line, err)
+		}
+
+		pair := rate.BaseCurrency + "/" + rate.QuoteCurrency
+		if i, ok := seen[pair]; ok {
+			rates[i] = rate
+			continue
+		}
+
+		seen[pair] = len(rates)
+		rates = append(rates, rate)
+	}
+
+	if len(rates) == 0 {
+		return nil, fmt.Errorf("rates file contains no rates")
+	}
+
+	return rates, nil
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"encoding/csv"
+	"errors"
+	"net/http"
+	"personal_budget_app/internal/models"
+	"time"
+)
+
+const (
+	// exportBatchRows is how many rows an export writes between flushes.
+	exportBatchRows = 500
+	// exportWriteWindow is how long a write to the client may take. The
+	// server's WriteTimeout would cut a long export short, so the deadline
+	// is moved on before every row instead.
+	exportWriteWindow = 30 * time.Second
+)
+
+// exportTransactions writes the transactions of one of the account's cards,
+// or of all of them when cardID is nil, to w as CSV in format. Rows go out as
+// they are read, flushed to the client every exportBatchRows. For a card transactionType is left to the query; across all
+// cards it is matched against each row's direction, so internal moves can be
+// picked as well.
+func (s *Server) exportTransactions(w http.ResponseWriter, accountID uint, cardID *uint, transactionType string, filter *models.TransactionFilter, format *models.ExportFormat) error {
+	owned := make(map[uint]bool)
+	if cardID != nil {
+		owned[*cardID] = true
+	} else {
+		cards, err := s.db.FindCards(accountID)
+		if err != nil {
+			return err
+		}
+		for _, card := range cards {
+			owned[card.ID] = true
+		}
+	}
+
+	writer := csv.NewWriter(w)
+	writer.Comma = format.Delimiter
+
+	controller := http.NewResponseController(w)
+	extend := func() error {
+		err := controller.SetWriteDeadline(time.Now().Add(exportWriteWindow))
+		if errors.Is(err, http.ErrNotSupported) {
+			return nil
+		}
+		return err
+	}
+	flush := func() error {
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			return err
+		}
+		if err := controller.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return err
+		}
+		return nil
+	}
+
+	if err := extend(); err != nil {
+		return err
+	}
+	if err := writer.Write(format.Header()); err != nil {
+		return err
+	}
+
+	queryType := transactionType
+	if cardID == nil {
+		queryType = ""
+	}
+	rows := 0
+	err := s.db.ExportTransactions(accountID, cardID, queryType, filter, func(row *models.ExportRow) error {
+		item := models.NewFeedItem(row.Transaction, owned)
+		if cardID == nil && transactionType != "" && item.Direction != transactionType {
+			return nil
+		}
+		row.Direction, row.Amount = item.Direction, item.Amount
+
+		if err := extend(); err != nil {
+			return err
+		}
+		if err := writer.Write(format.Record(row)); err != nil {
+			return err
+		}
+		if rows++; rows%exportBatchRows == 0 {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := extend(); err != nil {
+		return err
+	}
+	return flush()
+}
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"personal_budget_app/internal/functionalities"
+	"personal_budget_app/internal/models"
+	"strconv"
+	"time"
+)
+
+// handleExportTransactions streams the user's transactions as CSV: one card
+// with ?cardId=, all of them otherwise. It takes the history filters, and
+// ?columns=, ?delimiter=, ?numberFormat= and ?dateFormat= shape the file.
+func (s *Server) handleExportTransactions(w http.ResponseWriter, r *http.Request) {
+	user, err := ExtractUserFromToken(r)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: err.Error()})
+		return
+	}
+	if user.UserID == "" {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: "Unauthorized"})
+		return
+	}
+
+	// auth check passed:
+	userID, err := strconv.Atoi(user.UserID)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	query := r.URL.Query()
+	transactionType := query.Get("type")
+
+	var cardID *uint
+	if cardString := query.Get("cardId"); cardString != "" {
+		id, err := strconv.Atoi(cardString)
+		if err != nil {
+			functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: "invalid card id"})
+			return
+		}
+
+		doesBelong, err := s.db.CheckCardBelongsToUser(uint(id), uint(userID))
+		if err != nil {
+			functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+			return
+		}
+
+		if !doesBelong {
+			functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: fmt.Sprintf("The card (id=%v) is private and does not belong to this user", id)})
+			return
+		}
+
+		exported := uint(id)
+		cardID = &exported
+	}
+
+	switch transactionType {
+	case "", models.FeedIncoming, models.FeedOutgoing:
+	case models.FeedInternal:
+		if cardID != nil {
+			functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: "type internal needs all cards, leave out cardId"})
+			return
+		}
+	default:
+		functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: fmt.Sprintf("unknown type %q, expected incoming, outgoing or internal", transactionType)})
+		return
+	}
+
+	loc, err := s.accountLocation(uint(userID))
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	filter, err := parseHistoryFilter(query, loc)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: err.Error()})
+		return
+	}
+
+	format, err := models.ParseExportFormat(query.Get("columns"), query.Get("delimiter"), query.Get("numberFormat"), query.Get("dateFormat"), loc)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: err.Error()})
+		return
+	}
+
+	filename := fmt.Sprintf("transactions-%s.csv", time.Now().In(loc).Format("2006-01-02"))
+	if cardID != nil {
+		filename = fmt.Sprintf("transactions-card-%d-%s.csv", *cardID, time.Now().In(loc).Format("2006-01-02"))
+	}
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	w.WriteHeader(http.StatusOK)
+
+	// the status is out by now, so a failure can only cut the file short
+	if err := s.exportTransactions(w, uint(userID), cardID, transactionType, filter, format); err != nil {
+		fmt.Printf("Error exporting transactions for user (id=%v): %v\n", userID, err)
+	}
+}
@@ -0,0 +1,31 @@
+package server
+
+import "personal_budget_app/internal/models"
+
+// accountFeed reads one page of the account's feed and labels each
+// transaction by the direction money moved for the account.
+func (s *Server) accountFeed(accountID uint, filter *models.TransactionFilter, page *models.HistoryPageRequest) (*models.Feed, error) {
+	cards, err := s.db.FindCards(accountID)
+	if err != nil {
+		return nil, err
+	}
+	owned := make(map[uint]bool, len(cards))
+	for _, card := range cards {
+		owned[card.ID] = true
+	}
+
+	history, err := s.db.GetAccountFeed(accountID, filter, page)
+	if err != nil {
+		return nil, err
+	}
+
+	feed := &models.Feed{
+		Items:      make([]*models.FeedItem, 0, len(history.Transactions)),
+		NextCursor: history.NextCursor,
+	}
+	for _, ts := range history.Transactions {
+		feed.Items = append(feed.Items, models.NewFeedItem(ts, owned))
+	}
+
+	return feed, nil
+}
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"net/http"
+	"personal_budget_app/internal/functionalities"
+	"strconv"
+)
+
+// handleGetFeed lists the transactions of all of the user's cards and the
+// entries they made by hand in one list, newest first. It takes the same
+// filters and paging as a card's history.
+func (s *Server) handleGetFeed(w http.ResponseWriter, r *http.Request) {
+	user, err := ExtractUserFromToken(r)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: err.Error()})
+		return
+	}
+	if user.UserID == "" {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: "Unauthorized"})
+		return
+	}
+
+	// auth check passed:
+	userID, err := strconv.Atoi(user.UserID)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	loc, err := s.accountLocation(uint(userID))
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	query := r.URL.Query()
+	filter, err := parseHistoryFilter(query, loc)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: err.Error()})
+		return
+	}
+	page, err := parseHistoryPage(query)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: err.Error()})
+		return
+	}
+
+	feed, err := s.accountFeed(uint(userID), filter, page)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	functionalities.WriteJSON(w, http.StatusOK, feed)
+}
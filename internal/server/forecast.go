@@ -0,0 +1,140 @@
+package server
+
+import (
+	"personal_budget_app/internal/models"
+	"time"
+)
+
+const (
+	// forecastHistoryMonths is how far back recurring payments are looked for.
+	forecastHistoryMonths = 6
+	// forecastSpendingDays is the window discretionary spending is averaged over.
+	forecastSpendingDays = 90
+	// maxScheduleRuns bounds the runs projected for one schedule, as a cron
+	// rule can fire every minute.
+	maxScheduleRuns = 1000
+)
+
+// cashFlowForecast projects the balance of each of the account's cards for
+// days days, starting today in loc. Cards move by the account's active
+// scheduled transfers, by payments that recurred in the last months and,
+// every day, by the average of the rest of their recent spending. threshold,
+// if set, is converted into each card's currency.
+func (s *Server) cashFlowForecast(accountID uint, days int, threshold *models.Money, now time.Time, loc *time.Location) (*models.CashFlowForecast, error) {
+	local := now.In(loc)
+	start := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	end := start.AddDate(0, 0, days)
+
+	cards, err := s.db.FindCards(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[uint]*models.Card, len(cards))
+	byNumber := make(map[string]*models.Card, len(cards))
+	for _, card := range cards {
+		byID[card.ID] = card
+		byNumber[card.CardNumber] = card
+	}
+
+	events := make(map[uint][]*models.ForecastEvent)
+	// payments a schedule makes are not projected a second time as recurring
+	scheduled := make(map[string]bool)
+
+	schedules, err := s.db.GetScheduledTransfers(accountID)
+	if err != nil {
+		return nil, err
+	}
+	for _, st := range schedules {
+		from := byID[st.FromCardID]
+		if st.Status != models.ScheduleActive || st.NextRunAt == nil || from == nil {
+			continue
+		}
+		scheduled[models.MovementKey(st.FromCardID, false, st.ToCardNumber)] = true
+
+		sent, err := s.db.ConvertAmount(st.Amount, from.CardBalance.Currency)
+		if err != nil {
+			return nil, err
+		}
+		to := byNumber[st.ToCardNumber]
+		var received models.Money
+		if to != nil {
+			if received, err = s.db.ConvertAmount(st.Amount, to.CardBalance.Currency); err != nil {
+				return nil, err
+			}
+		}
+
+		scheduleID := st.ID
+		at := *st.NextRunAt
+		for runs := 0; at.Before(end) && runs < maxScheduleRuns; runs++ {
+			// an overdue run goes out with the scheduler's next tick
+			runAt := at
+			if runAt.Before(start) {
+				runAt = start
+			}
+
+			events[from.ID] = append(events[from.ID], &models.ForecastEvent{
+				At: runAt, CardID: from.ID, Amount: sent.Neg(), Source: models.ForecastScheduled,
+				Counterparty: st.ToCardNumber, ScheduleID: &scheduleID,
+			})
+			if to != nil {
+				events[to.ID] = append(events[to.ID], &models.ForecastEvent{
+					At: runAt, CardID: to.ID, Amount: received, Source: models.ForecastScheduled,
+					Counterparty: from.CardNumber, ScheduleID: &scheduleID,
+				})
+			}
+
+			next, err := st.NextRun(at)
+			if err != nil {
+				return nil, err
+			}
+			if next == nil {
+				break
+			}
+			at = *next
+		}
+	}
+
+	movements, err := s.db.GetCardMovements(accountID, now.AddDate(0, -forecastHistoryMonths, 0))
+	if err != nil {
+		return nil, err
+	}
+
+	recurring := make(map[uint][]*models.RecurringPayment)
+	expected := make(map[string]bool)
+	for _, p := range models.DetectRecurring(movements, now) {
+		if scheduled[p.Key()] {
+			continue
+		}
+		expected[p.Key()] = true
+		recurring[p.CardID] = append(recurring[p.CardID], p)
+		events[p.CardID] = append(events[p.CardID], models.RecurringEvents(p, start, end)...)
+	}
+
+	forecast := &models.CashFlowForecast{
+		From:  start.Format("2006-01-02"),
+		Days:  days,
+		Cards: make([]*models.CardForecast, 0, len(cards)),
+	}
+
+	skip := func(m *models.CardMovement) bool {
+		return scheduled[m.Key()] || expected[m.Key()]
+	}
+	for _, card := range cards {
+		daily := models.AverageDailySpending(movements, card, now.AddDate(0, 0, -forecastSpendingDays), now, skip)
+
+		var cardThreshold *models.Money
+		if threshold != nil {
+			converted, err := s.db.ConvertAmount(*threshold, card.CardBalance.Currency)
+			if err != nil {
+				return nil, err
+			}
+			cardThreshold = &converted
+		}
+
+		forecast.Cards = append(forecast.Cards,
+			models.NewCardForecast(card, start, days, events[card.ID], recurring[card.ID], daily, cardThreshold))
+	}
+
+	return forecast, nil
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"net/http"
+	"personal_budget_app/internal/functionalities"
+	"personal_budget_app/internal/models"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultForecastDays = 30
+	maxForecastDays     = 365
+)
+
+// handleGetForecast projects each card's daily balance: ?days= (default 30)
+// and ?threshold=5000&currency=KZT to flag days below an amount as well as
+// below zero.
+func (s *Server) handleGetForecast(w http.ResponseWriter, r *http.Request) {
+	user, err := ExtractUserFromToken(r)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: err.Error()})
+		return
+	}
+	if user.UserID == "" {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: "Unauthorized"})
+		return
+	}
+
+	// auth check passed:
+	userID, err := strconv.Atoi(user.UserID)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	query := r.URL.Query()
+	days := defaultForecastDays
+	if daysString := query.Get("days"); daysString != "" {
+		days, err = strconv.Atoi(daysString)
+		if err != nil || days < 1 || days > maxForecastDays {
+			functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: "days must be between 1 and 365"})
+			return
+		}
+	}
+
+	var threshold *models.Money
+	if thresholdString := query.Get("threshold"); thresholdString != "" {
+		amount, err := models.ParseMoney(thresholdString, query.Get("currency"))
+		if err != nil {
+			functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: "invalid threshold: " + err.Error()})
+			return
+		}
+		threshold = &amount
+	}
+
+	loc, err := s.accountLocation(uint(userID))
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	forecast, err := s.cashFlowForecast(uint(userID), days, threshold, time.Now(), loc)
+	if err != nil {
+		functionalities.WriteJSON(w, transferErrorStatus(err), APIServerError{Error: err.Error()})
+		return
+	}
+
+	functionalities.WriteJSON(w, http.StatusOK, forecast)
+}
@@ -0,0 +1,320 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gorilla/mux"
+	"net/http"
+	"personal_budget_app/internal/database"
+	"personal_budget_app/internal/functionalities"
+	"personal_budget_app/internal/models"
+	"strconv"
+	"time"
+)
+
+// handleCreateSavingsGoal links a goal to one of the user's cards and, with
+// autoContribution, schedules transfers into that card.
+func (s *Server) handleCreateSavingsGoal(w http.ResponseWriter, r *http.Request) {
+	user, err := ExtractUserFromToken(r)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: err.Error()})
+		return
+	}
+	if user.UserID == "" {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: "Unauthorized"})
+		return
+	}
+
+	// auth check passed:
+	userID, err := strconv.Atoi(user.UserID)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	req := new(models.CreateSavingsGoalRequest)
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: "E: " + err.Error()})
+		return
+	}
+
+	now := time.Now()
+	goal, err := models.NewSavingsGoal(uint(userID), req, now)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: err.Error()})
+		return
+	}
+
+	// check card belongs
+	doesBelong, err := s.db.CheckCardBelongsToUser(req.CardID, uint(userID))
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	if !doesBelong {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: fmt.Sprintf("The card (id=%v) is private and does not belong to this user", req.CardID)})
+		return
+	}
+
+	// the target is in the card's currency unless it says otherwise
+	if goal.Target.Currency == "" {
+		goal.Target.Currency, err = s.db.GetCardCurrency(req.CardID)
+		if err != nil {
+			functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+			return
+		}
+	}
+
+	var contribution *models.ScheduledTransfer
+	if req.AutoContribution != nil {
+		contribution, err = s.newGoalContribution(uint(userID), goal, req.AutoContribution)
+		if err != nil {
+			functionalities.WriteJSON(w, transferErrorStatus(err), APIServerError{Error: transferErrorMessage(err)})
+			return
+		}
+	}
+
+	if err := s.db.CreateSavingsGoal(goal, contribution); err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	status, err := s.goalStatus(goal, now)
+	if err != nil {
+		functionalities.WriteJSON(w, transferErrorStatus(err), APIServerError{Error: err.Error()})
+		return
+	}
+
+	functionalities.WriteJSON(w, http.StatusOK, status)
+}
+
+// handleGetSavingsGoals lists the user's goals with their progress, nearest
+// deadline first.
+func (s *Server) handleGetSavingsGoals(w http.ResponseWriter, r *http.Request) {
+	user, err := ExtractUserFromToken(r)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: err.Error()})
+		return
+	}
+	if user.UserID == "" {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: "Unauthorized"})
+		return
+	}
+
+	// auth check passed:
+	userID, err := strconv.Atoi(user.UserID)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	goals, err := s.db.GetSavingsGoals(uint(userID))
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	now := time.Now()
+	statuses := make([]*models.SavingsGoalStatus, 0, len(goals))
+	for _, goal := range goals {
+		status, err := s.goalStatus(goal, now)
+		if err != nil {
+			functionalities.WriteJSON(w, transferErrorStatus(err), APIServerError{Error: err.Error()})
+			return
+		}
+		statuses = append(statuses, status)
+	}
+
+	functionalities.WriteJSON(w, http.StatusOK, statuses)
+}
+
+func (s *Server) handleGetSavingsGoal(w http.ResponseWriter, r *http.Request) {
+	user, err := ExtractUserFromToken(r)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: err.Error()})
+		return
+	}
+	if user.UserID == "" {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: "Unauthorized"})
+		return
+	}
+
+	// auth check passed:
+	userID, err := strconv.Atoi(user.UserID)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: "invalid goal id"})
+		return
+	}
+
+	goal, err := s.db.GetSavingsGoal(uint(id), uint(userID))
+	if err != nil {
+		if errors.Is(err, database.ErrGoalNotFound) {
+			functionalities.WriteJSON(w, http.StatusNotFound, APIServerError{Error: err.Error()})
+			return
+		}
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	status, err := s.goalStatus(goal, time.Now())
+	if err != nil {
+		functionalities.WriteJSON(w, transferErrorStatus(err), APIServerError{Error: err.Error()})
+		return
+	}
+
+	functionalities.WriteJSON(w, http.StatusOK, status)
+}
+
+// handleDeleteSavingsGoal deletes a goal and cancels its automatic
+// contribution. The money stays on the card.
+func (s *Server) handleDeleteSavingsGoal(w http.ResponseWriter, r *http.Request) {
+	user, err := ExtractUserFromToken(r)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: err.Error()})
+		return
+	}
+	if user.UserID == "" {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: "Unauthorized"})
+		return
+	}
+
+	// auth check passed:
+	userID, err := strconv.Atoi(user.UserID)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: "invalid goal id"})
+		return
+	}
+
+	if err := s.db.DeleteSavingsGoal(uint(id), uint(userID)); err != nil {
+		if errors.Is(err, database.ErrGoalNotFound) {
+			functionalities.WriteJSON(w, http.StatusNotFound, APIServerError{Error: err.Error()})
+			return
+		}
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	functionalities.WriteJSON(w, http.StatusOK, map[string]string{"message": "Goal deleted"})
+}
+
+// handleSetGoalContribution replaces the goal's automatic contribution with
+// the one in the body.
+func (s *Server) handleSetGoalContribution(w http.ResponseWriter, r *http.Request) {
+	user, err := ExtractUserFromToken(r)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: err.Error()})
+		return
+	}
+	if user.UserID == "" {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: "Unauthorized"})
+		return
+	}
+
+	// auth check passed:
+	userID, err := strconv.Atoi(user.UserID)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: "invalid goal id"})
+		return
+	}
+
+	req := new(models.AutoContributionRequest)
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: "E: " + err.Error()})
+		return
+	}
+
+	goal, err := s.db.GetSavingsGoal(uint(id), uint(userID))
+	if err != nil {
+		if errors.Is(err, database.ErrGoalNotFound) {
+			functionalities.WriteJSON(w, http.StatusNotFound, APIServerError{Error: err.Error()})
+			return
+		}
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	contribution, err := s.newGoalContribution(uint(userID), goal, req)
+	if err != nil {
+		functionalities.WriteJSON(w, transferErrorStatus(err), APIServerError{Error: transferErrorMessage(err)})
+		return
+	}
+
+	s.replaceGoalContribution(w, goal, contribution)
+}
+
+func (s *Server) handleStopGoalContribution(w http.ResponseWriter, r *http.Request) {
+	user, err := ExtractUserFromToken(r)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: err.Error()})
+		return
+	}
+	if user.UserID == "" {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: "Unauthorized"})
+		return
+	}
+
+	// auth check passed:
+	userID, err := strconv.Atoi(user.UserID)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: "invalid goal id"})
+		return
+	}
+
+	goal, err := s.db.GetSavingsGoal(uint(id), uint(userID))
+	if err != nil {
+		if errors.Is(err, database.ErrGoalNotFound) {
+			functionalities.WriteJSON(w, http.StatusNotFound, APIServerError{Error: err.Error()})
+			return
+		}
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	s.replaceGoalContribution(w, goal, nil)
+}
+
+func (s *Server) replaceGoalContribution(w http.ResponseWriter, goal *models.SavingsGoal, contribution *models.ScheduledTransfer) {
+	goal, err := s.db.SetGoalContribution(goal.ID, goal.AccountID, contribution)
+	if err != nil {
+		if errors.Is(err, database.ErrGoalNotFound) {
+			functionalities.WriteJSON(w, http.StatusNotFound, APIServerError{Error: err.Error()})
+			return
+		}
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	status, err := s.goalStatus(goal, time.Now())
+	if err != nil {
+		functionalities.WriteJSON(w, transferErrorStatus(err), APIServerError{Error: err.Error()})
+		return
+	}
+
+	functionalities.WriteJSON(w, http.StatusOK, status)
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"net/http"
+	"personal_budget_app/internal/models"
+	"time"
+)
+
+// goalRateMonths is how many past months of a card's net inflow project a
+// goal without an automatic contribution.
+const goalRateMonths = 3
+
+// goalStatus works out how far along a goal is from its card's balance.
+func (s *Server) goalStatus(goal *models.SavingsGoal, now time.Time) (*models.SavingsGoalStatus, error) {
+	currency := goal.Target.Currency
+
+	card, err := s.db.FindCard(goal.CardID)
+	if err != nil {
+		return nil, err
+	}
+
+	balance, err := s.db.ConvertAmount(card.CardBalance, currency)
+	if err != nil {
+		return nil, err
+	}
+
+	var rate models.Money
+	if c := goal.Contribution; c != nil && c.Status == models.ScheduleActive {
+		rate = models.MonthlyEquivalent(c.Amount, c.Frequency)
+	} else {
+		net, err := s.db.GetCardNetChange(goal.CardID, now.AddDate(0, -goalRateMonths, 0))
+		if err != nil {
+			return nil, err
+		}
+		rate = models.NewMoney(net.Amount/goalRateMonths, net.Currency)
+	}
+
+	rate, err = s.db.ConvertAmount(rate, currency)
+	if err != nil {
+		return nil, err
+	}
+
+	return models.NewSavingsGoalStatus(goal, balance, rate, now), nil
+}
+
+// newGoalContribution builds the scheduled transfer that pays into a goal's
+// card. It goes through the scheduler, and so through makeTransfer, like any
+// other scheduled transfer.
+func (s *Server) newGoalContribution(accountID uint, goal *models.SavingsGoal, req *models.AutoContributionRequest) (*models.ScheduledTransfer, error) {
+	if req.Frequency != models.FrequencyWeekly && req.Frequency != models.FrequencyMonthly {
+		return nil, rejectTransfer(http.StatusBadRequest, "Contributions are weekly or monthly")
+	}
+	if req.Amount.Amount <= 0 {
+		return nil, rejectTransfer(http.StatusBadRequest, "Amount must be positive")
+	}
+	if req.FromCardID == goal.CardID {
+		return nil, rejectTransfer(http.StatusBadRequest, "Contributions must come from another card")
+	}
+
+	// check card belongs
+	doesBelong, err := s.db.CheckCardBelongsToUser(req.FromCardID, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !doesBelong {
+		return nil, rejectTransfer(http.StatusInternalServerError, "The card (id=%v) is private and does not belong to this user", req.FromCardID)
+	}
+
+	// the amount is in the from-card's currency unless it says otherwise
+	if req.Amount.Currency == "" {
+		req.Amount.Currency, err = s.db.GetCardCurrency(req.FromCardID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	startAt := time.Now()
+	if req.StartAt != "" {
+		startAt, err = time.Parse(time.RFC3339, req.StartAt)
+		if err != nil {
+			startAt, err = time.Parse("2006-01-02", req.StartAt)
+		}
+		if err != nil {
+			return nil, rejectTransfer(http.StatusBadRequest, "Invalid start date, use RFC 3339 or 2006-01-02")
+		}
+	}
+
+	goalCard, err := s.db.FindCard(goal.CardID)
+	if err != nil {
+		return nil, err
+	}
+
+	st, err := models.NewScheduledTransfer(accountID, &models.CreateScheduledTransferRequest{
+		FromCardID:   req.FromCardID,
+		ToCardNumber: goalCard.CardNumber,
+		Amount:       req.Amount,
+		Frequency:    req.Frequency,
+	}, startAt)
+	if err != nil {
+		return nil, rejectTransfer(http.StatusBadRequest, "%v", err)
+	}
+
+	return st, nil
+}
@@ -0,0 +1,142 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"personal_budget_app/internal/functionalities"
+	"personal_budget_app/internal/models"
+	"strconv"
+	"time"
+)
+
+const (
+	idempotencyHeader = "Idempotency-Key"
+	idempotencyTTL    = 24 * time.Hour
+	maxIdempotencyKey = 255
+	// bodies are hashed and kept in memory; the requests behind keys are small JSON
+	maxIdempotentBody = 1 << 20
+)
+
+// responseRecorder passes the response through while keeping a copy of it.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
+
+// IdempotencyMiddleware makes a handler safe to retry. A request carrying an
+// Idempotency-Key header runs once per key; repeats with the same body get
+// the stored response back, repeats with a different body are rejected.
+// Requests without the header are passed through untouched.
+func (s *Server) IdempotencyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyHeader)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKey {
+			functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: "Idempotency-Key is too long"})
+			return
+		}
+
+		user, err := ExtractUserFromToken(r)
+		if err != nil {
+			functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: err.Error()})
+			return
+		}
+
+		userID, err := strconv.Atoi(user.UserID)
+		if err != nil {
+			functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: "Unauthorized"})
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIdempotentBody))
+		if err != nil {
+			status := http.StatusBadRequest
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				status = http.StatusRequestEntityTooLarge
+			}
+			functionalities.WriteJSON(w, status, APIServerError{Error: err.Error()})
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		hash := sha256.New()
+		hash.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+		hash.Write(body)
+
+		record := &models.IdempotencyKey{
+			AccountID:   uint(userID),
+			Key:         key,
+			RequestHash: hex.EncodeToString(hash.Sum(nil)),
+			ExpiresAt:   time.Now().Add(idempotencyTTL),
+		}
+
+		existing, claimed, err := s.db.ClaimIdempotencyKey(record)
+		if err != nil {
+			functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+			return
+		}
+
+		if !claimed {
+			switch {
+			case existing.RequestHash != record.RequestHash:
+				functionalities.WriteJSON(w, http.StatusUnprocessableEntity, APIServerError{Error: "Idempotency-Key was already used with a different request"})
+			case existing.ResponseStatus == 0:
+				functionalities.WriteJSON(w, http.StatusConflict, APIServerError{Error: "A request with this Idempotency-Key is still being processed"})
+			default:
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("Idempotent-Replayed", "true")
+				w.WriteHeader(existing.ResponseStatus)
+				w.Write(existing.ResponseBody)
+			}
+			return
+		}
+
+		// settled however the handler ends: a handler that panics, or never
+		// wrote a response, releases the key so a retry runs again rather
+		// than getting 409 until the key expires
+		rec := &responseRecorder{ResponseWriter: w}
+		defer func() {
+			panicked := recover()
+
+			var err error
+			if panicked != nil || rec.status == 0 || rec.status >= http.StatusInternalServerError {
+				err = s.db.ReleaseIdempotencyKey(record.AccountID, key)
+			} else {
+				err = s.db.SaveIdempotentResponse(record.AccountID, key, rec.status, rec.body.Bytes())
+			}
+			if err != nil {
+				// the response has already gone out, nothing to tell the client
+				log.Printf("Error storing idempotency key %q: %v", key, err)
+			}
+
+			if panicked != nil {
+				panic(panicked)
+			}
+		}()
+
+		next.ServeHTTP(rec, r)
+	})
+}
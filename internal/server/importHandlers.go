@@ -0,0 +1,117 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"personal_budget_app/internal/functionalities"
+	"personal_budget_app/internal/models"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxStatementSize bounds an uploaded statement file.
+const maxStatementSize = 10 << 20
+
+// handleImportStatement reads an OFX or QFX bank statement, sent as the
+// "file" field of a multipart form or as the whole body, into income and
+// expense entries. ?cardId= attaches them to one of the user's cards and
+// ?affectsBalance=true makes them change its balance; ?categoryId= files
+// them all under one category.
+func (s *Server) handleImportStatement(w http.ResponseWriter, r *http.Request) {
+	user, err := ExtractUserFromToken(r)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: err.Error()})
+		return
+	}
+	if user.UserID == "" {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: "Unauthorized"})
+		return
+	}
+
+	// auth check passed:
+	userID, err := strconv.Atoi(user.UserID)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	query := r.URL.Query()
+	req := &models.ImportStatementRequest{AffectsBalance: query.Get("affectsBalance") == "true"}
+
+	if cardString := query.Get("cardId"); cardString != "" {
+		cardID, err := strconv.Atoi(cardString)
+		if err != nil {
+			functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: "invalid card id"})
+			return
+		}
+
+		doesBelong, err := s.db.CheckCardBelongsToUser(uint(cardID), uint(userID))
+		if err != nil {
+			functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+			return
+		}
+
+		if !doesBelong {
+			functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: fmt.Sprintf("The card (id=%v) is private and does not belong to this user", cardID)})
+			return
+		}
+
+		id := uint(cardID)
+		req.CardID = &id
+	}
+	if req.AffectsBalance && req.CardID == nil {
+		functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: "only entries with a card can affect a balance"})
+		return
+	}
+
+	if categoryString := query.Get("categoryId"); categoryString != "" {
+		categoryId, err := strconv.Atoi(categoryString)
+		if err != nil {
+			functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: "invalid category id"})
+			return
+		}
+
+		visible, err := s.db.CategoryVisibleTo(uint(categoryId), uint(userID))
+		if err != nil {
+			functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+			return
+		}
+		if !visible {
+			functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: fmt.Sprintf("Category (id=%v) not found", categoryId)})
+			return
+		}
+
+		categoryID := uint(categoryId)
+		req.CategoryID = &categoryID
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxStatementSize)
+	var file io.Reader = r.Body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		upload, _, err := r.FormFile("file")
+		if err != nil {
+			functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: "E: " + err.Error()})
+			return
+		}
+		defer upload.Close()
+		file = upload
+	}
+
+	entries, err := models.ParseOFX(file)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: err.Error()})
+		return
+	}
+
+	loc, err := s.accountLocation(uint(userID))
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	result := s.importStatement(uint(userID), entries, req, time.Now(), loc)
+
+	functionalities.WriteJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,54 @@
+package server
+
+import (
+	"personal_budget_app/internal/models"
+	"time"
+)
+
+// importStatement adds the entries of a bank statement to the account one by
+// one, so that a bad line fails alone. Entries whose FITID the account
+// already has from the same bank account are skipped; expenses go through
+// the account's rules like entries made by hand.
+func (s *Server) importStatement(accountID uint, entries []*models.StatementEntry, req *models.ImportStatementRequest, now time.Time, loc *time.Location) *models.ImportResult {
+	result := &models.ImportResult{
+		Entries:  []*models.Transaction{},
+		Failures: []*models.ImportFailure{},
+	}
+
+	fail := func(entry *models.StatementEntry, reason string) {
+		result.Failed++
+		result.Failures = append(result.Failures, &models.ImportFailure{
+			FITID: entry.FITID, Posted: entry.Posted, Amount: entry.Amount, Reason: reason,
+		})
+	}
+
+	for _, entry := range entries {
+		ts, err := models.NewImportedEntry(accountID, entry, req, now, loc)
+		if err != nil {
+			fail(entry, err.Error())
+			continue
+		}
+
+		if ts.Kind == models.TransactionKindExpense {
+			if err := s.applyRules(accountID, ts, ""); err != nil {
+				fail(entry, err.Error())
+				continue
+			}
+		}
+
+		imported, err := s.db.ImportManualEntry(ts)
+		if err != nil {
+			fail(entry, transferErrorMessage(err))
+			continue
+		}
+		if !imported {
+			result.Skipped++
+			continue
+		}
+
+		result.Imported++
+		result.Entries = append(result.Entries, ts)
+	}
+
+	return result
+}
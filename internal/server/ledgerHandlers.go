@@ -0,0 +1,18 @@
+package server
+
+import (
+	"net/http"
+	"personal_budget_app/internal/functionalities"
+)
+
+// handleReconcileLedger reports cards whose stored balance has drifted from
+// their ledger and transactions whose entries do not balance.
+func (s *Server) handleReconcileLedger(w http.ResponseWriter, r *http.Request) {
+	report, err := s.db.ReconcileLedger()
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	functionalities.WriteJSON(w, http.StatusOK, report)
+}
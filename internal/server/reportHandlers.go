@@ -0,0 +1,59 @@
+package server
+
+import (
+	"net/http"
+	"personal_budget_app/internal/functionalities"
+	"personal_budget_app/internal/models"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// handleGetSummaryReport totals income and expenses across all of the
+// user's cards. The period is ?month=2024-05 or ?from=2024-05-01&to=2024-05-31
+// in the account's timezone, the current month by default; ?currency= picks
+// the currency everything is converted into.
+func (s *Server) handleGetSummaryReport(w http.ResponseWriter, r *http.Request) {
+	user, err := ExtractUserFromToken(r)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: err.Error()})
+		return
+	}
+	if user.UserID == "" {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: "Unauthorized"})
+		return
+	}
+
+	// auth check passed:
+	userID, err := strconv.Atoi(user.UserID)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	loc, err := s.accountLocation(uint(userID))
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	query := r.URL.Query()
+	period, err := models.ParseReportPeriod(query.Get("month"), query.Get("from"), query.Get("to"), time.Now(), loc)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: err.Error()})
+		return
+	}
+
+	currency := strings.ToUpper(query.Get("currency"))
+	if currency == "" {
+		currency = models.DefaultCurrency
+	}
+
+	report, err := s.summaryReport(uint(userID), period, currency)
+	if err != nil {
+		functionalities.WriteJSON(w, transferErrorStatus(err), APIServerError{Error: err.Error()})
+		return
+	}
+
+	functionalities.WriteJSON(w, http.StatusOK, report)
+}
@@ -0,0 +1,54 @@
+package server
+
+import (
+	"personal_budget_app/internal/models"
+	"time"
+)
+
+// accountLocation is the timezone the account's months are counted in.
+func (s *Server) accountLocation(accountID uint) (*time.Location, error) {
+	timezone, err := s.db.GetTimezone(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	return time.LoadLocation(timezone)
+}
+
+// summaryReport totals the account's income and expenses over period and
+// the period before it, converted into currency.
+func (s *Server) summaryReport(accountID uint, period models.ReportPeriod, currency string) (*models.SummaryReport, error) {
+	current, err := s.periodSummary(accountID, period, currency)
+	if err != nil {
+		return nil, err
+	}
+
+	previous, err := s.periodSummary(accountID, period.Previous(), currency)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.SummaryReport{
+		Currency: currency,
+		Timezone: period.From.Location().String(),
+		Current:  current,
+		Previous: previous,
+		Change:   models.NewPeriodChange(current, previous),
+	}, nil
+}
+
+func (s *Server) periodSummary(accountID uint, period models.ReportPeriod, currency string) (*models.PeriodSummary, error) {
+	lines, err := s.db.GetReportLines(accountID, period.From, period.To)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, line := range lines {
+		line.Amount, err = s.db.ConvertAmount(line.Amount, currency)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return models.NewPeriodSummary(period, currency, lines), nil
+}
@@ -35,14 +35,79 @@ func (s *Server) RegisterRoutes() http.Handler {
 	secure.HandleFunc("/cards", s.handleGetCards).Methods("GET")
 	secure.HandleFunc("/cards/{id}", s.handleDeleteCard).Methods("DELETE")
 	secure.HandleFunc("/cards/{id}", s.handleGetCard).Methods("GET")
+	secure.HandleFunc("/cards/{id}/statement", s.handleGetCardStatement).Methods("GET")
 
 	secure.HandleFunc("/transaction/{cardId}", s.handleGetTransactions).Methods("GET")
-	secure.HandleFunc("/transaction", s.handleAddTransactionTo).Methods("POST")
-
+	secure.Handle("/transaction", s.IdempotencyMiddleware(http.HandlerFunc(s.handleAddTransactionTo))).Methods("POST")
+	secure.HandleFunc("/transaction/{id}", s.handleUpdateTransaction).Methods("PATCH")
+	secure.HandleFunc("/transaction/{id}/splits", s.handleSplitTransaction).Methods("PUT")
+	secure.Handle("/transaction/{id}/refund", s.IdempotencyMiddleware(http.HandlerFunc(s.handleRefundTransaction))).Methods("POST")
+	secure.HandleFunc("/transaction/{id}/confirm", s.handleConfirmTransaction).Methods("POST")
+	secure.HandleFunc("/transaction/{id}/cancel", s.handleCancelTransaction).Methods("POST")
+
+	secure.HandleFunc("/entries", s.handleAddManualEntry).Methods("POST")
+	secure.HandleFunc("/entries", s.handleGetManualEntries).Methods("GET")
+	secure.HandleFunc("/entries/{id}", s.handleDeleteManualEntry).Methods("DELETE")
+	secure.HandleFunc("/entries/import", s.handleImportStatement).Methods("POST")
+
+
+	secure.HandleFunc("/categories", s.handleGetCategories).Methods("GET")
+	secure.HandleFunc("/categories", s.handleCreateCategory).Methods("POST")
+	secure.HandleFunc("/categories/{id}", s.handleUpdateCategory).Methods("PATCH")
+	secure.HandleFunc("/categories/{id}", s.handleDeleteCategory).Methods("DELETE")
+
+	secure.HandleFunc("/rules", s.handleCreateRule).Methods("POST")
+	secure.HandleFunc("/rules", s.handleGetRules).Methods("GET")
+	secure.HandleFunc("/rules/apply", s.handleReapplyRules).Methods("POST")
+	secure.HandleFunc("/rules/{id}", s.handleUpdateRule).Methods("PUT")
+	secure.HandleFunc("/rules/{id}", s.handleDeleteRule).Methods("DELETE")
+
+	secure.HandleFunc("/budgets", s.handleSetBudget).Methods("POST")
+	secure.HandleFunc("/budgets", s.handleGetBudgets).Methods("GET")
+	secure.HandleFunc("/budgets/{id}", s.handleDeleteBudget).Methods("DELETE")
+
+	secure.HandleFunc("/goals", s.handleCreateSavingsGoal).Methods("POST")
+	secure.HandleFunc("/goals", s.handleGetSavingsGoals).Methods("GET")
+	secure.HandleFunc("/goals/{id}", s.handleGetSavingsGoal).Methods("GET")
+	secure.HandleFunc("/goals/{id}", s.handleDeleteSavingsGoal).Methods("DELETE")
+	secure.HandleFunc("/goals/{id}/contribution", s.handleSetGoalContribution).Methods("PUT")
+	secure.HandleFunc("/goals/{id}/contribution", s.handleStopGoalContribution).Methods("DELETE")
+
+	secure.HandleFunc("/bills", s.handleCreateBill).Methods("POST")
+	secure.HandleFunc("/bills", s.handleGetBills).Methods("GET")
+	secure.HandleFunc("/bills/{id}", s.handleDeleteBill).Methods("DELETE")
+
+	secure.HandleFunc("/feed", s.handleGetFeed).Methods("GET")
+	secure.HandleFunc("/export/transactions", s.handleExportTransactions).Methods("GET")
+	secure.HandleFunc("/search", s.handleSearchTransactions).Methods("GET")
+	secure.HandleFunc("/tags", s.handleGetTags).Methods("GET")
+
+	secure.HandleFunc("/reports/summary", s.handleGetSummaryReport).Methods("GET")
+	secure.HandleFunc("/forecast", s.handleGetForecast).Methods("GET")
+
+	secure.HandleFunc("/schedules", s.handleCreateSchedule).Methods("POST")
+	secure.HandleFunc("/schedules", s.handleGetSchedules).Methods("GET")
+	secure.HandleFunc("/schedules/{id}", s.handleGetSchedule).Methods("GET")
+	secure.HandleFunc("/schedules/{id}", s.handleCancelSchedule).Methods("DELETE")
+	secure.HandleFunc("/schedules/{id}/pause", s.handlePauseSchedule).Methods("POST")
+	secure.HandleFunc("/schedules/{id}/resume", s.handleResumeSchedule).Methods("POST")
+
+	secure.HandleFunc("/exchange-rates", s.handleGetExchangeRates).Methods("GET")
+
+	// admin
+	admin := secure.PathPrefix("/admin").Subrouter()
+	admin.Use(s.AdminMiddleware)
+
+	admin.HandleFunc("/exchange-rates", s.handleLoadExchangeRates).Methods("POST")
+	admin.HandleFunc("/ledger/reconcile", s.handleReconcileLedger).Methods("GET")
+	admin.HandleFunc("/accounts/{id}/limits", s.handleSetTransferLimits).Methods("PUT")
 
 	// account settings
 	secure.HandleFunc("/accounts/settings/default-card/{cardId}", s.handleSetDefaultCard).Methods("POST")
 	secure.HandleFunc("/accounts/settings/change-password/{id}", s.handleUpdatePassword).Methods("PUT")
+	secure.HandleFunc("/accounts/settings/limits", s.handleGetTransferLimits).Methods("GET")
+	secure.HandleFunc("/accounts/settings/confirmation-threshold", s.handleSetConfirmationThreshold).Methods("PUT")
+	secure.HandleFunc("/accounts/settings/timezone", s.handleSetTimezone).Methods("PUT")
 
 	corsRouter := corsMiddleware(router)
 
@@ -52,8 +117,8 @@ func (s *Server) RegisterRoutes() http.Handler {
 func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "http://localhost:3000")
-		w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, PATCH, DELETE")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Idempotency-Key")
 		w.Header().Set("Access-Control-Allow-Credentials", "true")
 
 		if r.Method == "OPTIONS" {
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"personal_budget_app/internal/models"
+)
+
+// applyRules runs the account's categorization rules on a new transaction.
+// A category chosen by the user is kept; rule tags are added to the user's.
+func (s *Server) applyRules(accountID uint, ts *models.Transaction, toCardNumber string) error {
+	rules, err := s.db.GetCategoryRules(accountID)
+	if err != nil {
+		return err
+	}
+
+	categoryID, tags := models.ApplyRules(rules, &models.RuleInput{
+		ToCardNumber: toCardNumber,
+		Amount:       ts.TransactionAmount,
+		Description:  ts.Description,
+		Time:         ts.TransactionTime,
+	})
+
+	if ts.CategoryID == nil {
+		ts.CategoryID = categoryID
+	}
+	ts.Tags = models.NormalizeTags(append(ts.Tags, tags...))
+
+	return nil
+}
+
+// reapplyRules runs the account's rules over all of its past outgoing
+// transfers and expenses. Transactions that already have a category keep it
+// unless overwrite is set; tags are only ever added. With dryRun nothing is
+// saved.
+func (s *Server) reapplyRules(accountID uint, req *models.ReapplyRulesRequest) (*models.ReapplyRulesResult, error) {
+	rules, err := s.db.GetCategoryRules(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.ReapplyRulesResult{DryRun: req.DryRun, Changes: []*models.RuleChange{}}
+
+	err = s.db.EachOutgoingTransaction(accountID, func(batch []*models.Transaction, cardNumbers map[uint]string) error {
+		for _, ts := range batch {
+			result.Checked++
+
+			toCardNumber := ""
+			if ts.ToCardID != nil {
+				toCardNumber = cardNumbers[*ts.ToCardID]
+			}
+
+			categoryID, tags := models.ApplyRules(rules, &models.RuleInput{
+				ToCardNumber: toCardNumber,
+				Amount:       ts.TransactionAmount,
+				Description:  ts.Description,
+				Time:         ts.TransactionTime,
+			})
+
+			change := &models.RuleChange{TransactionID: ts.ID, OldCategoryID: ts.CategoryID, NewCategoryID: ts.CategoryID}
+			if categoryID != nil && (ts.CategoryID == nil || req.Overwrite) && !sameCategory(ts.CategoryID, categoryID) {
+				change.NewCategoryID = categoryID
+			}
+
+			merged := models.NormalizeTags(append(append([]string{}, ts.Tags...), tags...))
+			change.AddedTags = merged[len(models.NormalizeTags(ts.Tags)):]
+
+			if sameCategory(change.OldCategoryID, change.NewCategoryID) && len(change.AddedTags) == 0 {
+				continue
+			}
+			result.Changes = append(result.Changes, change)
+
+			if req.DryRun {
+				continue
+			}
+			if err := s.db.ClassifyTransaction(ts.ID, accountID, change.NewCategoryID, merged); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func sameCategory(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
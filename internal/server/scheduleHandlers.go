@@ -0,0 +1,216 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gorilla/mux"
+	"net/http"
+	"personal_budget_app/internal/database"
+	"personal_budget_app/internal/functionalities"
+	"personal_budget_app/internal/models"
+	"strconv"
+	"time"
+)
+
+func (s *Server) handleCreateSchedule(w http.ResponseWriter, r *http.Request) {
+	user, err := ExtractUserFromToken(r)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: err.Error()})
+		return
+	}
+	if user.UserID == "" {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: "Unauthorized"})
+		return
+	}
+
+	// auth check passed:
+	userID, err := strconv.Atoi(user.UserID)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	req := new(models.CreateScheduledTransferRequest)
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: "E: " + err.Error()})
+		return
+	}
+
+	now := time.Now()
+	startAt := now
+	if req.StartAt != "" {
+		startAt, err = time.Parse(time.RFC3339, req.StartAt)
+		if err != nil {
+			startAt, err = time.Parse("2006-01-02", req.StartAt)
+		}
+		if err != nil {
+			functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: "Invalid start date, use RFC 3339 or 2006-01-02"})
+			return
+		}
+	}
+
+	if req.Frequency == models.FrequencyOnce && !startAt.After(now) {
+		functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: "A one-time transfer must be scheduled in the future"})
+		return
+	}
+
+	if req.Amount.Amount <= 0 {
+		functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: "Amount must be positive"})
+		return
+	}
+
+	// check card belongs
+	doesBelong, err := s.db.CheckCardBelongsToUser(req.FromCardID, uint(userID))
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	if !doesBelong {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: fmt.Sprintf("The card (id=%v) is private and does not belong to this user", req.FromCardID)})
+		return
+	}
+
+	// the amount is in the card's currency unless it says otherwise
+	if req.Amount.Currency == "" {
+		req.Amount.Currency, err = s.db.GetCardCurrency(req.FromCardID)
+		if err != nil {
+			functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+			return
+		}
+	}
+
+	if _, err := s.db.FindCardIDByCardNumber(req.ToCardNumber); err != nil {
+		functionalities.WriteJSON(w, http.StatusNotFound, APIServerError{Error: "Destination card not found"})
+		return
+	}
+
+	st, err := models.NewScheduledTransfer(uint(userID), req, startAt)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: err.Error()})
+		return
+	}
+
+	if err := s.db.CreateScheduledTransfer(st); err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	functionalities.WriteJSON(w, http.StatusOK, st)
+}
+
+func (s *Server) handleGetSchedules(w http.ResponseWriter, r *http.Request) {
+	user, err := ExtractUserFromToken(r)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: err.Error()})
+		return
+	}
+	if user.UserID == "" {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: "Unauthorized"})
+		return
+	}
+
+	// auth check passed:
+	userID, err := strconv.Atoi(user.UserID)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	schedules, err := s.db.GetScheduledTransfers(uint(userID))
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	functionalities.WriteJSON(w, http.StatusOK, schedules)
+}
+
+// handleGetSchedule returns one schedule with its run history, failed runs included.
+func (s *Server) handleGetSchedule(w http.ResponseWriter, r *http.Request) {
+	user, err := ExtractUserFromToken(r)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: err.Error()})
+		return
+	}
+	if user.UserID == "" {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: "Unauthorized"})
+		return
+	}
+
+	// auth check passed:
+	userID, err := strconv.Atoi(user.UserID)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: "invalid schedule id"})
+		return
+	}
+
+	st, err := s.db.GetScheduledTransfer(uint(id), uint(userID))
+	if err != nil {
+		if errors.Is(err, database.ErrScheduleNotFound) {
+			functionalities.WriteJSON(w, http.StatusNotFound, APIServerError{Error: err.Error()})
+			return
+		}
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	functionalities.WriteJSON(w, http.StatusOK, st)
+}
+
+func (s *Server) handlePauseSchedule(w http.ResponseWriter, r *http.Request) {
+	s.setScheduleStatus(w, r, models.SchedulePaused)
+}
+
+func (s *Server) handleResumeSchedule(w http.ResponseWriter, r *http.Request) {
+	s.setScheduleStatus(w, r, models.ScheduleActive)
+}
+
+// handleCancelSchedule stops a schedule for good. Its run history is kept.
+func (s *Server) handleCancelSchedule(w http.ResponseWriter, r *http.Request) {
+	s.setScheduleStatus(w, r, models.ScheduleCancelled)
+}
+
+func (s *Server) setScheduleStatus(w http.ResponseWriter, r *http.Request, status string) {
+	user, err := ExtractUserFromToken(r)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: err.Error()})
+		return
+	}
+	if user.UserID == "" {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: "Unauthorized"})
+		return
+	}
+
+	// auth check passed:
+	userID, err := strconv.Atoi(user.UserID)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: "invalid schedule id"})
+		return
+	}
+
+	st, err := s.db.SetScheduledTransferStatus(uint(id), uint(userID), status)
+	if err != nil {
+		if errors.Is(err, database.ErrScheduleNotFound) {
+			functionalities.WriteJSON(w, http.StatusNotFound, APIServerError{Error: err.Error()})
+			return
+		}
+		functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: err.Error()})
+		return
+	}
+
+	functionalities.WriteJSON(w, http.StatusOK, st)
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"log"
+	"personal_budget_app/internal/models"
+	"time"
+)
+
+const (
+	schedulerBatch = 50
+	// a run still claimed after this long was cut short and is run again
+	scheduledRunTimeout = 10 * time.Minute
+)
+
+// runScheduler wakes up every interval and runs the scheduled transfers that
+// are due. It never returns.
+func (s *Server) runScheduler(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.runDueTransfers(time.Now())
+		s.retryInterruptedTransfers(time.Now())
+		s.expirePendingTransfers(time.Now())
+	}
+}
+
+// runDueTransfers makes every due scheduled transfer.
+func (s *Server) runDueTransfers(now time.Time) {
+	for {
+		runs, err := s.db.ClaimDueScheduledTransfers(now, schedulerBatch)
+		if err != nil {
+			log.Printf("scheduler: claiming due transfers: %v", err)
+			return
+		}
+
+		for _, run := range runs {
+			s.runScheduledTransfer(run)
+		}
+
+		if len(runs) < schedulerBatch {
+			return
+		}
+	}
+}
+
+// retryInterruptedTransfers runs again the claimed runs that never finished.
+func (s *Server) retryInterruptedTransfers(now time.Time) {
+	runs, err := s.db.ClaimInterruptedScheduledTransferRuns(now.Add(-scheduledRunTimeout), now, schedulerBatch)
+	if err != nil {
+		log.Printf("scheduler: claiming interrupted runs: %v", err)
+		return
+	}
+
+	for _, run := range runs {
+		log.Printf("scheduler: retrying interrupted run (id=%v) of scheduled transfer (id=%v)", run.ID, run.ScheduleID)
+		s.runScheduledTransfer(run)
+	}
+}
+
+// runScheduledTransfer makes the transfer of a claimed run through
+// makeTransfer, the same path as POST /api/transaction, and records how it
+// went. The transfer carries the run's id, so it is made at most once.
+func (s *Server) runScheduledTransfer(run *models.ScheduledTransferRun) {
+	st := run.Schedule
+	req := &models.AddTransactionRequest{
+		TransactionAmount: st.Amount,
+		FromCardID:        st.FromCardID,
+		ToCardNumber:      st.ToCardNumber,
+		ScheduledRunID:    &run.ID,
+	}
+
+	run.Status = models.RunSucceeded
+	ts, err := s.makeTransfer(st.AccountID, req, true)
+	if err != nil {
+		run.Status = models.RunFailed
+		run.Error = transferErrorMessage(err)
+		log.Printf("scheduler: scheduled transfer (id=%v) failed: %v", st.ID, err)
+	} else {
+		run.TransactionID = &ts.ID
+	}
+
+	if err := s.db.FinishScheduledTransferRun(run); err != nil {
+		log.Printf("scheduler: recording run of scheduled transfer (id=%v): %v", st.ID, err)
+	}
+}
+
+// expirePendingTransfers releases the holds of pending transfers nobody
+// confirmed in time.
+func (s *Server) expirePendingTransfers(now time.Time) {
+	if _, err := s.db.ExpirePendingTransfers(now); err != nil {
+		log.Printf("scheduler: expiring pending transfers: %v", err)
+	}
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"net/http"
+	"personal_budget_app/internal/functionalities"
+	"personal_budget_app/internal/models"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultSearchLimit = 50
+	maxSearchLimit     = 200
+)
+
+// handleSearchTransactions searches all of the user's cards and entries:
+// ?q=trip almaty matches descriptions, counterparty names, references and
+// tags by word prefix, ?q=4400 1234 also card numbers.
+func (s *Server) handleSearchTransactions(w http.ResponseWriter, r *http.Request) {
+	user, err := ExtractUserFromToken(r)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: err.Error()})
+		return
+	}
+	if user.UserID == "" {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: "Unauthorized"})
+		return
+	}
+
+	// auth check passed:
+	userID, err := strconv.Atoi(user.UserID)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	query := r.URL.Query()
+	search := &models.TransactionSearch{
+		Query: strings.TrimSpace(query.Get("q")),
+		Limit: defaultSearchLimit,
+	}
+	if search.Query == "" {
+		functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: "q is required"})
+		return
+	}
+	if limitString := query.Get("limit"); limitString != "" {
+		search.Limit, err = strconv.Atoi(limitString)
+		if err != nil || search.Limit < 1 || search.Limit > maxSearchLimit {
+			functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: "limit must be between 1 and 200"})
+			return
+		}
+	}
+
+	transactions, err := s.db.SearchTransactions(uint(userID), search)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	functionalities.WriteJSON(w, http.StatusOK, transactions)
+}
+
+// handleGetTags lists the tags the user's transactions carry, most used first.
+func (s *Server) handleGetTags(w http.ResponseWriter, r *http.Request) {
+	user, err := ExtractUserFromToken(r)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: err.Error()})
+		return
+	}
+	if user.UserID == "" {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: "Unauthorized"})
+		return
+	}
+
+	// auth check passed:
+	userID, err := strconv.Atoi(user.UserID)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	tags, err := s.db.GetTags(uint(userID))
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	functionalities.WriteJSON(w, http.StatusOK, tags)
+}
@@ -23,6 +23,7 @@ type Server struct {
 	port int
 	db database.Service
 	sessionStore *sessions.CookieStore
+	pendingWindow time.Duration // how long a pending transfer waits for confirmation
 }
 
 func NewServer() *http.Server {
@@ -34,12 +35,24 @@ func NewServer() *http.Server {
 	sessionKey := []byte("secret") // !!! CONTINUE WORKING WITH sessions
 
 	port, _ := strconv.Atoi(os.Getenv("PORT"))
+
+	pendingWindow := 15 * time.Minute
+	if value := os.Getenv("PENDING_TRANSFER_WINDOW"); value != "" {
+		if pendingWindow, err = time.ParseDuration(value); err != nil || pendingWindow <= 0 {
+			log.Fatalf("Invalid PENDING_TRANSFER_WINDOW %q: %v", value, err)
+		}
+	}
+
 	NewServer := &Server{
 		port: port,
 		db: database.New(),
 		sessionStore: sessions.NewCookieStore(sessionKey),
+		pendingWindow: pendingWindow,
 	}
 
+	// runs scheduled transfers and expires pending ones in the background
+	go NewServer.runScheduler(time.Minute)
+
 	server := &http.Server{
 		Addr:         fmt.Sprintf("0.0.0.0:%d", NewServer.port),
 		Handler:      NewServer.RegisterRoutes(),
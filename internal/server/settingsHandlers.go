@@ -2,12 +2,15 @@ package server
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/gorilla/mux"
 	"net/http"
+	"personal_budget_app/internal/database"
 	"personal_budget_app/internal/functionalities"
 	"personal_budget_app/internal/models"
 	"strconv"
+	"time"
 )
 
 
@@ -200,3 +203,191 @@ func (s *Server) handleUpdatePassword(w http.ResponseWriter, r *http.Request) {
 
 
 
+
+// transfer limits
+func (s *Server) handleGetTransferLimits(w http.ResponseWriter, r *http.Request) {
+	user, err := ExtractUserFromToken(r)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: err.Error()})
+		return
+	}
+	if user.UserID == "" {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: "Unauthorized"})
+		return
+	}
+
+	userId, err := strconv.Atoi(user.UserID)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	status, err := s.transferLimitsStatus(uint(userId), time.Now())
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	functionalities.WriteJSON(w, http.StatusOK, status)
+}
+
+// handleSetTransferLimits lets an admin change an account's limits. Limits
+// left out of the request keep their current value. Moving the limits to
+// another currency converts the account's confirmation threshold with them.
+func (s *Server) handleSetTransferLimits(w http.ResponseWriter, r *http.Request) {
+	accountId, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: "invalid id"})
+		return
+	}
+
+	req := new(models.UpdateTransferLimitsRequest)
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: "invalid request body: " + err.Error()})
+		return
+	}
+
+	exists, err := s.db.AccountExists(uint(accountId))
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+	if !exists {
+		functionalities.WriteJSON(w, http.StatusNotFound, APIServerError{Error: fmt.Sprintf("account with id=%v not found", accountId)})
+		return
+	}
+
+	limits, err := s.db.GetTransferLimits(uint(accountId))
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	for _, change := range []struct {
+		value  *models.Money
+		target *models.Money
+	}{
+		{req.MinAmount, &limits.MinAmount},
+		{req.MaxAmount, &limits.MaxAmount},
+		{req.DailyLimit, &limits.DailyLimit},
+		{req.MonthlyLimit, &limits.MonthlyLimit},
+	} {
+		if change.value == nil {
+			continue
+		}
+		if change.value.Currency == "" {
+			change.value.Currency = change.target.Currency
+		}
+		*change.target = *change.value
+	}
+
+	// the threshold is the account holder's own; when the limits move to
+	// another currency it follows them at the current rate
+	if threshold := limits.ConfirmationThreshold; threshold.Currency != limits.MinAmount.Currency {
+		converted, err := s.db.ConvertAmount(threshold, limits.MinAmount.Currency)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, database.ErrExchangeRateNotFound) {
+				status = http.StatusBadRequest
+			}
+			functionalities.WriteJSON(w, status, APIServerError{Error: err.Error()})
+			return
+		}
+		limits.ConfirmationThreshold = converted
+	}
+
+	if err := s.db.SetTransferLimits(limits); err != nil {
+		functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: err.Error()})
+		return
+	}
+
+	functionalities.WriteJSON(w, http.StatusOK, limits)
+}
+
+// handleSetConfirmationThreshold lets the account holder choose above which
+// amount their transfers wait for confirmation. The amount is taken in the
+// currency of the account's limits unless it names one.
+func (s *Server) handleSetConfirmationThreshold(w http.ResponseWriter, r *http.Request) {
+	user, err := ExtractUserFromToken(r)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: err.Error()})
+		return
+	}
+	if user.UserID == "" {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: "Unauthorized"})
+		return
+	}
+
+	userId, err := strconv.Atoi(user.UserID)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	req := new(models.SetConfirmationThresholdRequest)
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: "invalid request body: " + err.Error()})
+		return
+	}
+
+	limits, err := s.db.GetTransferLimits(uint(userId))
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	if req.Amount.Currency == "" {
+		req.Amount.Currency = limits.ConfirmationThreshold.Currency
+	}
+	limits.ConfirmationThreshold = req.Amount
+
+	if err := s.db.SetTransferLimits(limits); err != nil {
+		functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: err.Error()})
+		return
+	}
+
+	functionalities.WriteJSON(w, http.StatusOK, limits)
+}
+
+// handleSetTimezone sets the timezone reports split months by.
+func (s *Server) handleSetTimezone(w http.ResponseWriter, r *http.Request) {
+	user, err := ExtractUserFromToken(r)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: err.Error()})
+		return
+	}
+	if user.UserID == "" {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: "Unauthorized"})
+		return
+	}
+
+	userId, err := strconv.Atoi(user.UserID)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	req := new(models.SetTimezoneRequest)
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: "invalid request body: " + err.Error()})
+		return
+	}
+
+	// "Local" would mean the server's zone, not the user's
+	if req.Timezone == "" || req.Timezone == "Local" {
+		functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: "timezone is required"})
+		return
+	}
+	loc, err := time.LoadLocation(req.Timezone)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: fmt.Sprintf("unknown timezone %q", req.Timezone)})
+		return
+	}
+
+	if err := s.db.SetTimezone(uint(userId), loc.String()); err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	functionalities.WriteJSON(w, http.StatusOK, map[string]string{"timezone": loc.String()})
+}
@@ -0,0 +1,37 @@
+package server
+
+import (
+	"fmt"
+	"personal_budget_app/internal/database"
+	"personal_budget_app/internal/models"
+)
+
+// cardStatement builds the statement of one of the account's cards over
+// period from its ledger.
+func (s *Server) cardStatement(accountID, cardID uint, period models.ReportPeriod) (*models.CardStatement, error) {
+	cards, err := s.db.FindCards(accountID)
+	if err != nil {
+		return nil, err
+	}
+	var card *models.Card
+	for _, c := range cards {
+		if c.ID == cardID {
+			card = c
+		}
+	}
+	if card == nil {
+		return nil, fmt.Errorf("%w: id=%v", database.ErrCardNotFound, cardID)
+	}
+
+	opening, err := s.db.GetCardBalanceAt(cardID, period.From)
+	if err != nil {
+		return nil, err
+	}
+
+	lines, err := s.db.GetStatementLines(cardID, period.From, period.To)
+	if err != nil {
+		return nil, err
+	}
+
+	return models.NewCardStatement(card, period, opening, lines), nil
+}
@@ -2,12 +2,17 @@ package server
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/gorilla/mux"
 	"net/http"
+	"net/url"
+	"personal_budget_app/internal/database"
 	"personal_budget_app/internal/functionalities"
 	"personal_budget_app/internal/models"
 	"strconv"
+	"strings"
+	"time"
 )
 
 func (s *Server) handleAddTransactionTo(w http.ResponseWriter, r *http.Request) {
@@ -22,9 +27,10 @@ func (s *Server) handleAddTransactionTo(w http.ResponseWriter, r *http.Request)
 	}
 
 	// auth check passed:
-	tsLimits := map[string]float64{
-		"MIN_AMOUNT": 100.0,
-		"MAX_AMOUNT": 100000.0,
+	userID, err := strconv.Atoi(user.UserID)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
 	}
 
 	req := new(models.AddTransactionRequest)
@@ -33,74 +39,96 @@ func (s *Server) handleAddTransactionTo(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// get receiver ID
-	toCardID, err := s.db.FindCardIDByCardNumber(req.ToCardNumber)
+	ts, err := s.makeTransfer(uint(userID), req, false)
 	if err != nil {
-		functionalities.WriteJSON(w, http.StatusNotFound, APIServerError{Error: "Destination card not found"})
+		functionalities.WriteJSON(w, transferErrorStatus(err), APIServerError{Error: transferErrorMessage(err)})
 		return
 	}
 
-	// check card belongs
-	userID, err := strconv.Atoi(user.UserID)
-	if err != nil {
-		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+	if ts.Status == models.TransactionPending {
+		functionalities.WriteJSON(w, http.StatusAccepted, map[string]interface{}{
+			"message":     fmt.Sprintf("Transaction is pending, confirm it before %v", ts.ExpiresAt.Format(time.RFC3339)),
+			"transaction": ts,
+		})
 		return
 	}
 
+	functionalities.WriteJSON(w, http.StatusOK, map[string]string{"message": "Transaction successful"})
+}
 
+func (s *Server) handleConfirmTransaction(w http.ResponseWriter, r *http.Request) {
+	s.settlePendingTransaction(w, r, s.db.ConfirmTransfer)
+}
 
-	doesBelong, err := s.db.CheckCardBelongsToUser(req.FromCardID, uint(userID))
+func (s *Server) handleCancelTransaction(w http.ResponseWriter, r *http.Request) {
+	s.settlePendingTransaction(w, r, s.db.CancelTransfer)
+}
+
+// settlePendingTransaction confirms or cancels a pending transfer with settle.
+// Only the sender can do either.
+func (s *Server) settlePendingTransaction(w http.ResponseWriter, r *http.Request, settle func(id uint) (*models.Transaction, error)) {
+	user, err := ExtractUserFromToken(r)
 	if err != nil {
-		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: err.Error()})
 		return
 	}
-
-	if !doesBelong {
-		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: fmt.Sprintf("The card (id=%v) is private and does not belong to this user", req.FromCardID)})
+	if user.UserID == "" {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: "Unauthorized"})
 		return
 	}
 
-	// check balance
-	if req.TransactionAmount < tsLimits["MIN_AMOUNT"] { // MIN
-		functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: fmt.Sprintf("Minimum transaction amount is %v", tsLimits["MIN_AMOUNT"])})
+	// auth check passed:
+	transactionID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: "invalid transaction id"})
 		return
 	}
 
-	if req.TransactionAmount > tsLimits["MAX_AMOUNT"] { // MAX
-		functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: fmt.Sprintf("Maximum transaction amount is %v", tsLimits["MAX_AMOUNT"])})
+	userID, err := strconv.Atoi(user.UserID)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
 		return
 	}
 
-	hasBalance, err := s.db.CheckCardBalance(req.FromCardID, req.TransactionAmount)
+	ts, err := s.db.GetTransaction(uint(transactionID))
 	if err != nil {
+		if errors.Is(err, database.ErrTransactionNotFound) {
+			functionalities.WriteJSON(w, http.StatusNotFound, APIServerError{Error: err.Error()})
+			return
+		}
 		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
 		return
 	}
-	if !hasBalance {
-		functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: "Insufficient balance"})
-		return
-	}
 
-	// create the transaction
-	ts := models.NewTransaction(req.TransactionAmount, req.FromCardID, toCardID)
-	if err := s.db.AddTransaction(ts); err != nil {
+	doesBelong, err := s.isSender(ts, uint(userID))
+	if err != nil {
 		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
 		return
 	}
 
-	// sender's account (-)
-	if err := s.db.SenderUpdateBalance(req.FromCardID, req.TransactionAmount); err != nil {
-		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+	if !doesBelong {
+		functionalities.WriteJSON(w, http.StatusForbidden, APIServerError{Error: "Only the sender can confirm or cancel a transaction"})
 		return
 	}
 
-	// receiver's account (+)
-	if err := s.db.ReceiverUpdateBalance(toCardID, req.TransactionAmount); err != nil {
+	ts, err = settle(ts.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrTransactionNotFound), errors.Is(err, database.ErrCardNotFound):
+			functionalities.WriteJSON(w, http.StatusNotFound, APIServerError{Error: err.Error()})
+		case errors.Is(err, database.ErrNotPending), errors.Is(err, database.ErrTransferExpired):
+			functionalities.WriteJSON(w, http.StatusConflict, APIServerError{Error: err.Error()})
+		default:
+			functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		}
+		return
+	}
+	if err := s.db.LoadTags(uint(userID), ts); err != nil {
 		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
 		return
 	}
 
-	functionalities.WriteJSON(w, http.StatusOK, map[string]string{"message": "Transaction successful"})
+	functionalities.WriteJSON(w, http.StatusOK, ts)
 }
 
 
@@ -142,17 +170,35 @@ func (s *Server) handleGetTransactions(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// main
-	transactionType := r.URL.Query().Get("type")
+	query := r.URL.Query()
+	transactionType := query.Get("type")
+
+	loc, err := s.accountLocation(uint(userID))
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+	filter, err := parseHistoryFilter(query, loc)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: err.Error()})
+		return
+	}
+
+	page, err := parseHistoryPage(query)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: err.Error()})
+		return
+	}
 
-	var transactions []*models.Transaction
+	var history *models.HistoryPage
 
 	switch transactionType {
 	case "incoming":
-		transactions, err = s.db.GetIncomingTransactions(uint(cardId))
+		history, err = s.db.GetIncomingTransactions(uint(cardId), filter, page)
 	case "outgoing":
-		transactions, err = s.db.GetOutgoingTransactions(uint(cardId))
+		history, err = s.db.GetOutgoingTransactions(uint(cardId), filter, page)
 	default:
-		transactions, err = s.db.GetAllTransactions(uint(cardId))
+		history, err = s.db.GetAllTransactions(uint(cardId), filter, page)
 	}
 
 	if err != nil {
@@ -160,5 +206,389 @@ func (s *Server) handleGetTransactions(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	functionalities.WriteJSON(w, http.StatusOK, transactions)
-}
\ No newline at end of file
+	functionalities.WriteJSON(w, http.StatusOK, history)
+}
+
+const (
+	defaultHistoryLimit = 50
+	maxHistoryLimit     = 200
+)
+
+// parseHistoryFilter reads the filters history requests share. Dates are
+// days in loc, both included; amounts are decimals in the card's currency.
+func parseHistoryFilter(query url.Values, loc *time.Location) (*models.TransactionFilter, error) {
+	filter := &models.TransactionFilter{
+		Kind:                   query.Get("kind"),
+		Description:            query.Get("q"),
+		ExternalReference:      query.Get("reference"),
+		CounterpartyName:       query.Get("counterparty"),
+		Tag:                    strings.ToLower(strings.TrimSpace(query.Get("tag"))),
+		CounterpartyCardNumber: strings.TrimSpace(query.Get("counterpartyCard")),
+	}
+	if categoryString := query.Get("category"); categoryString != "" {
+		categoryId, err := strconv.Atoi(categoryString)
+		if err != nil {
+			return nil, fmt.Errorf("invalid category id")
+		}
+		categoryID := uint(categoryId)
+		filter.CategoryID = &categoryID
+	}
+
+	if from := query.Get("from"); from != "" {
+		start, err := time.ParseInLocation("2006-01-02", from, loc)
+		if err != nil {
+			return nil, fmt.Errorf("invalid from date %q, expected YYYY-MM-DD", from)
+		}
+		filter.From = &start
+	}
+	if to := query.Get("to"); to != "" {
+		end, err := time.ParseInLocation("2006-01-02", to, loc)
+		if err != nil {
+			return nil, fmt.Errorf("invalid to date %q, expected YYYY-MM-DD", to)
+		}
+		end = end.AddDate(0, 0, 1)
+		filter.To = &end
+	}
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		return nil, fmt.Errorf("to date is before from date")
+	}
+
+	for _, bound := range []struct {
+		name string
+		dst  **int64
+	}{{"minAmount", &filter.MinAmount}, {"maxAmount", &filter.MaxAmount}} {
+		value := query.Get(bound.name)
+		if value == "" {
+			continue
+		}
+		amount, err := models.ParseMoney(value, "")
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", bound.name, err)
+		}
+		*bound.dst = &amount.Amount
+	}
+	if filter.MinAmount != nil && filter.MaxAmount != nil && *filter.MinAmount > *filter.MaxAmount {
+		return nil, fmt.Errorf("minAmount is above maxAmount")
+	}
+
+	return filter, nil
+}
+
+// parseHistoryPage reads the sort order, page size and cursor of a history
+// request. A cursor only continues the order it was made for.
+func parseHistoryPage(query url.Values) (*models.HistoryPageRequest, error) {
+	page := &models.HistoryPageRequest{SortBy: models.HistorySortTime, Limit: defaultHistoryLimit}
+
+	switch sortBy := query.Get("sort"); sortBy {
+	case "", models.HistorySortTime:
+	case models.HistorySortAmount:
+		page.SortBy = sortBy
+	default:
+		return nil, fmt.Errorf("unknown sort %q, expected time or amount", sortBy)
+	}
+
+	switch order := query.Get("order"); order {
+	case "", "desc":
+	case "asc":
+		page.Ascending = true
+	default:
+		return nil, fmt.Errorf("unknown order %q, expected asc or desc", order)
+	}
+
+	if limitString := query.Get("limit"); limitString != "" {
+		limit, err := strconv.Atoi(limitString)
+		if err != nil || limit < 1 || limit > maxHistoryLimit {
+			return nil, fmt.Errorf("limit must be between 1 and %d", maxHistoryLimit)
+		}
+		page.Limit = limit
+	}
+
+	if cursorString := query.Get("cursor"); cursorString != "" {
+		cursor, err := models.ParseHistoryCursor(cursorString)
+		if err != nil {
+			return nil, err
+		}
+		if cursor.SortBy != page.SortBy || cursor.Ascending != page.Ascending {
+			return nil, fmt.Errorf("the cursor belongs to a different sort order")
+		}
+		page.After = cursor
+	}
+
+	return page, nil
+}
+
+// handleRefundTransaction sends money back along a transfer. Only the owner
+// of the card that received the money, or an admin, can give it back.
+func (s *Server) handleRefundTransaction(w http.ResponseWriter, r *http.Request) {
+	user, err := ExtractUserFromToken(r)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: err.Error()})
+		return
+	}
+	if user.UserID == "" {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: "Unauthorized"})
+		return
+	}
+
+	// auth check passed:
+	idString := mux.Vars(r)["id"]
+	transactionID, err := strconv.Atoi(idString)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: "invalid transaction id"})
+		return
+	}
+
+	userID, err := strconv.Atoi(user.UserID)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	req := new(models.RefundTransactionRequest)
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: "E: " + err.Error()})
+			return
+		}
+	}
+
+	original, err := s.db.GetTransaction(uint(transactionID))
+	if err != nil {
+		if errors.Is(err, database.ErrTransactionNotFound) {
+			functionalities.WriteJSON(w, http.StatusNotFound, APIServerError{Error: err.Error()})
+			return
+		}
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	// check the money went to this user's card
+	doesBelong, err := s.isRecipient(original, uint(userID))
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	if !doesBelong {
+		isAdmin, err := s.db.IsAdmin(uint(userID))
+		if err != nil {
+			functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+			return
+		}
+		if !isAdmin {
+			functionalities.WriteJSON(w, http.StatusForbidden, APIServerError{Error: "Only the recipient of a transfer can refund it"})
+			return
+		}
+	}
+
+	refund, err := s.db.RefundTransaction(original.ID, req.Amount)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrInsufficientFunds):
+			functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: "Insufficient balance"})
+		case errors.Is(err, database.ErrTransactionNotFound), errors.Is(err, database.ErrCardNotFound):
+			functionalities.WriteJSON(w, http.StatusNotFound, APIServerError{Error: err.Error()})
+		case errors.Is(err, database.ErrNotRefundable), errors.Is(err, database.ErrRefundExceedsOriginal),
+			errors.Is(err, database.ErrInvalidRefundAmount), errors.Is(err, database.ErrCurrencyMismatch):
+			functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: err.Error()})
+		default:
+			functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		}
+		return
+	}
+
+	functionalities.WriteJSON(w, http.StatusOK, refund)
+}
+
+
+// handleUpdateTransaction edits the description, category and tags of a
+// transaction. The description and category are the sender's notes, so only
+// the sender can edit them; each side keeps its own tags.
+func (s *Server) handleUpdateTransaction(w http.ResponseWriter, r *http.Request) {
+	user, err := ExtractUserFromToken(r)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: err.Error()})
+		return
+	}
+	if user.UserID == "" {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: "Unauthorized"})
+		return
+	}
+
+	// auth check passed:
+	transactionID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: "invalid transaction id"})
+		return
+	}
+
+	userID, err := strconv.Atoi(user.UserID)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	req := new(models.UpdateTransactionRequest)
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: "invalid request body: " + err.Error()})
+		return
+	}
+
+	ts, err := s.db.GetTransaction(uint(transactionID))
+	if err != nil {
+		if errors.Is(err, database.ErrTransactionNotFound) {
+			functionalities.WriteJSON(w, http.StatusNotFound, APIServerError{Error: err.Error()})
+			return
+		}
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	isSender, err := s.isSender(ts, uint(userID))
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	if !isSender {
+		isRecipient, err := s.isRecipient(ts, uint(userID))
+		if err != nil {
+			functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+			return
+		}
+		if !isRecipient {
+			functionalities.WriteJSON(w, http.StatusForbidden, APIServerError{Error: "Only the sender or the recipient can edit a transaction"})
+			return
+		}
+		if req.Description != nil || req.CategoryID != nil {
+			functionalities.WriteJSON(w, http.StatusForbidden, APIServerError{Error: "Only the sender can edit the description and category"})
+			return
+		}
+	}
+
+	if req.CategoryID != nil && *req.CategoryID != 0 {
+		visible, err := s.db.CategoryVisibleTo(*req.CategoryID, uint(userID))
+		if err != nil {
+			functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+			return
+		}
+		if !visible {
+			functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: fmt.Sprintf("Category (id=%v) not found", *req.CategoryID)})
+			return
+		}
+	}
+
+	ts, err = s.db.UpdateTransactionDetails(ts.ID, uint(userID), req)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	functionalities.WriteJSON(w, http.StatusOK, ts)
+}
+
+// handleSplitTransaction spreads a transaction over several categories. Like
+// the category itself, the split is the sender's to make.
+func (s *Server) handleSplitTransaction(w http.ResponseWriter, r *http.Request) {
+	user, err := ExtractUserFromToken(r)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: err.Error()})
+		return
+	}
+	if user.UserID == "" {
+		functionalities.WriteJSON(w, http.StatusUnauthorized, APIServerError{Error: "Unauthorized"})
+		return
+	}
+
+	// auth check passed:
+	transactionID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: "invalid transaction id"})
+		return
+	}
+
+	userID, err := strconv.Atoi(user.UserID)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	req := new(models.SplitTransactionRequest)
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: "invalid request body: " + err.Error()})
+		return
+	}
+
+	ts, err := s.db.GetTransaction(uint(transactionID))
+	if err != nil {
+		if errors.Is(err, database.ErrTransactionNotFound) {
+			functionalities.WriteJSON(w, http.StatusNotFound, APIServerError{Error: err.Error()})
+			return
+		}
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	doesBelong, err := s.isSender(ts, uint(userID))
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	if !doesBelong {
+		functionalities.WriteJSON(w, http.StatusForbidden, APIServerError{Error: "Only the sender can split a transaction"})
+		return
+	}
+
+	splits, err := models.NewTransactionSplits(ts, req)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: err.Error()})
+		return
+	}
+
+	for _, split := range splits {
+		if split.CategoryID == nil {
+			continue
+		}
+		visible, err := s.db.CategoryVisibleTo(*split.CategoryID, uint(userID))
+		if err != nil {
+			functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+			return
+		}
+		if !visible {
+			functionalities.WriteJSON(w, http.StatusBadRequest, APIServerError{Error: fmt.Sprintf("Category (id=%v) not found", *split.CategoryID)})
+			return
+		}
+	}
+
+	ts, err = s.db.SplitTransaction(ts.ID, splits)
+	if err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+	if err := s.db.LoadTags(uint(userID), ts); err != nil {
+		functionalities.WriteJSON(w, http.StatusInternalServerError, APIServerError{Error: err.Error()})
+		return
+	}
+
+	functionalities.WriteJSON(w, http.StatusOK, ts)
+}
+
+// isSender reports whether the account sent ts, or entered it by hand.
+func (s *Server) isSender(ts *models.Transaction, accountID uint) (bool, error) {
+	if ts.IsManual() {
+		return ts.AccountID != nil && *ts.AccountID == accountID, nil
+	}
+	if ts.FromCardID == nil {
+		return false, nil
+	}
+	return s.db.CheckCardBelongsToUser(*ts.FromCardID, accountID)
+}
+
+// isRecipient reports whether ts paid into one of the account's cards.
+func (s *Server) isRecipient(ts *models.Transaction, accountID uint) (bool, error) {
+	if ts.ToCardID == nil {
+		return false, nil
+	}
+	return s.db.CheckCardBelongsToUser(*ts.ToCardID, accountID)
+}
@@ -0,0 +1,193 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"personal_budget_app/internal/database"
+	"personal_budget_app/internal/models"
+	"time"
+)
+
+// transferError is a rejected transfer together with the HTTP status the API
+// reports it with.
+type transferError struct {
+	status int
+	err    error
+}
+
+func (e *transferError) Error() string {
+	return e.err.Error()
+}
+
+func (e *transferError) Unwrap() error {
+	return e.err
+}
+
+func rejectTransfer(status int, format string, args ...interface{}) error {
+	return &transferError{status: status, err: fmt.Errorf(format, args...)}
+}
+
+// transferErrorStatus picks the HTTP status for an error returned by makeTransfer.
+func transferErrorStatus(err error) int {
+	var rejected *transferError
+	var overLimit *database.TransferLimitError
+	switch {
+	case errors.As(err, &rejected):
+		return rejected.status
+	case errors.As(err, &overLimit),
+		errors.Is(err, database.ErrInsufficientFunds),
+		errors.Is(err, database.ErrCurrencyMismatch),
+		errors.Is(err, database.ErrExchangeRateNotFound):
+		return http.StatusBadRequest
+	case errors.Is(err, database.ErrCardNotFound):
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+// transferErrorMessage is the text shown to the client for a failed transfer.
+func transferErrorMessage(err error) string {
+	if errors.Is(err, database.ErrInsufficientFunds) {
+		return "Insufficient balance"
+	}
+	return err.Error()
+}
+
+// makeTransfer runs a card-to-card transfer on behalf of accountID with every
+// check the API applies. Both POST /api/transaction and the scheduler go
+// through here so they cannot drift apart. Transfers above the account's
+// confirmation threshold are only held, as pending, unless preApproved is set
+// (the scheduler's transfers were approved when the schedule was created).
+func (s *Server) makeTransfer(accountID uint, req *models.AddTransactionRequest, preApproved bool) (*models.Transaction, error) {
+	// get receiver ID
+	toCardID, err := s.db.FindCardIDByCardNumber(req.ToCardNumber)
+	if err != nil {
+		return nil, rejectTransfer(http.StatusNotFound, "Destination card not found")
+	}
+
+	// check card belongs
+	doesBelong, err := s.db.CheckCardBelongsToUser(req.FromCardID, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !doesBelong {
+		return nil, rejectTransfer(http.StatusInternalServerError, "The card (id=%v) is private and does not belong to this user", req.FromCardID)
+	}
+
+	// the amount is in the sender card's currency unless it says otherwise
+	if req.TransactionAmount.Currency == "" {
+		currency, err := s.db.GetCardCurrency(req.FromCardID)
+		if err != nil {
+			return nil, err
+		}
+		req.TransactionAmount.Currency = currency
+	}
+
+	if req.CategoryID != nil {
+		visible, err := s.db.CategoryVisibleTo(*req.CategoryID, accountID)
+		if err != nil {
+			return nil, err
+		}
+		if !visible {
+			return nil, rejectTransfer(http.StatusBadRequest, "Category (id=%v) not found", *req.CategoryID)
+		}
+	}
+
+	// check limits; the daily and monthly ones are checked as the money moves
+	quota, needsConfirmation, err := s.checkTransferLimits(accountID, req.TransactionAmount)
+	if err != nil {
+		return nil, err
+	}
+
+	// create the transaction and move the money in one go
+	ts := models.NewTransaction(req.TransactionAmount, req.FromCardID, toCardID)
+	ts.Description = req.Description
+	ts.CategoryID = req.CategoryID
+	ts.ExternalReference = req.ExternalReference
+	ts.CounterpartyName = req.CounterpartyName
+	ts.Tags = models.NormalizeTags(req.Tags)
+	ts.ScheduledRunID = req.ScheduledRunID
+
+	// the account's rules fill in what the request left out
+	if err := s.applyRules(accountID, ts, req.ToCardNumber); err != nil {
+		return nil, err
+	}
+
+	if needsConfirmation && !preApproved {
+		if err := s.db.HoldFunds(ts, time.Now().Add(s.pendingWindow), quota); err != nil {
+			return nil, err
+		}
+		return ts, nil
+	}
+
+	if err := s.db.TransferFunds(ts, quota); err != nil {
+		return nil, err
+	}
+
+	return ts, nil
+}
+
+// checkTransferLimits applies the account's per-transfer minimum and maximum
+// to every transfer and reports whether the amount is above the account's
+// confirmation threshold. The daily and monthly totals only apply to money
+// sent to cards the account does not own, and depend on every other transfer
+// in flight, so they are returned as a quota for TransferFunds and HoldFunds
+// to check with the account locked.
+func (s *Server) checkTransferLimits(accountID uint, amount models.Money) (*models.TransferQuota, bool, error) {
+	limits, err := s.db.GetTransferLimits(accountID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	converted, err := s.db.ConvertAmount(amount, limits.MinAmount.Currency)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if converted.Cmp(limits.MinAmount) < 0 { // MIN
+		return nil, false, rejectTransfer(http.StatusBadRequest, "Minimum transaction amount is %v", limits.MinAmount)
+	}
+
+	if converted.Cmp(limits.MaxAmount) > 0 { // MAX
+		return nil, false, rejectTransfer(http.StatusBadRequest, "Maximum transaction amount is %v", limits.MaxAmount)
+	}
+
+	loc, err := s.accountLocation(accountID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return models.NewTransferQuota(limits, time.Now().In(loc)), limits.NeedsConfirmation(converted), nil
+}
+
+// transferLimitsStatus reports the account's limits and how much of the
+// current day and month is used up, in the limits' currency. Days and months
+// start at midnight in the account's timezone.
+func (s *Server) transferLimitsStatus(accountID uint, now time.Time) (*models.TransferLimitsStatus, error) {
+	limits, err := s.db.GetTransferLimits(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	loc, err := s.accountLocation(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	currency := limits.DailyLimit.Currency
+	quota := models.NewTransferQuota(limits, now.In(loc))
+
+	usedToday, err := s.db.GetTransferUsage(accountID, quota.StartOfDay, currency)
+	if err != nil {
+		return nil, err
+	}
+
+	usedThisMonth, err := s.db.GetTransferUsage(accountID, quota.StartOfMonth, currency)
+	if err != nil {
+		return nil, err
+	}
+
+	return models.NewTransferLimitsStatus(limits, usedToday, usedThisMonth), nil
+}